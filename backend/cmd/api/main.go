@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -10,15 +11,21 @@ import (
 	"time"
 
 	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
 	"github.com/rankq/backend/internal/infrastructure/cache"
 	"github.com/rankq/backend/internal/infrastructure/database"
 	"github.com/rankq/backend/internal/interface/http/handler"
+	"github.com/rankq/backend/internal/interface/http/middleware"
 	"github.com/rankq/backend/internal/interface/http/router"
+	"github.com/rankq/backend/migrations"
 	"github.com/rankq/backend/pkg/config"
 )
 
 func main() {
-	cfg, err := config.Load()
+	configPath := flag.String("config", os.Getenv(config.ConfigFileEnv), "path to a YAML or TOML config file")
+	flag.Parse()
+
+	cfg, err := config.LoadFile(*configPath)
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
@@ -29,6 +36,25 @@ func main() {
 	}
 	defer db.Close()
 
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+
+	if cfg.Database.AutoMigrate {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("failed to apply migrations: %v", err)
+		}
+		for _, m := range applied {
+			log.Printf("applied migration %04d_%s", m.Version, m.Name)
+		}
+	}
+
+	if err := migrator.CheckCurrent(context.Background()); err != nil {
+		log.Fatalf("refusing to start: %v (run `rankq migrate up` or set DB_AUTO_MIGRATE=true)", err)
+	}
+
 	redisClient, err := cache.NewRedisClient(cfg.Redis)
 	if err != nil {
 		log.Fatalf("failed to connect to redis: %v", err)
@@ -37,23 +63,156 @@ func main() {
 
 	userRepo := database.NewUserRepository(db)
 	scoreRepo := database.NewScoreRepository(db)
-	leaderboardRepo := cache.NewLeaderboardRepository(redisClient)
+	friendshipRepo := database.NewFriendshipRepository(db)
+	teamRepo := database.NewTeamRepository(db)
+	historyRepo := database.NewScoreHistoryRepository(db)
+	anomalyRepo := database.NewAnomalyRepository(db)
+	webhookRepo := database.NewWebhookRepository(db)
+	importRepo := database.NewImportRepository(db)
+	simulationRunRepo := database.NewSimulationRunRepository(db)
+
+	teamAggregate := entity.TeamAggregate{Mode: cfg.Teams.Aggregate, K: cfg.Teams.TopK}
+	leaderboardRepo := cache.NewLeaderboardRepository(redisClient, teamAggregate, int64(cfg.Events.MaxLen))
+	teamBoardRepo := cache.NewTeamLeaderboardRepository(redisClient, teamAggregate)
+	usernameIndex := cache.NewUsernameIndex(redisClient)
+
+	instanceID := service.InstanceID(cfg.Cluster.NodeID)
+	leaseStore := cache.NewLeaseStore(redisClient)
+	jobCluster := service.JobCluster{
+		Leases:     leaseStore,
+		State:      cache.NewJobStateStore(redisClient),
+		InstanceID: instanceID,
+		LeaseTTL:   cfg.Cluster.LeaseTTL.Duration,
+	}
+
+	ratings := entity.RatingRange{Min: cfg.Rating.Min, Max: cfg.Rating.Max, Default: cfg.Rating.Default}
+	simulationLimits := service.SimulationLimits{
+		MinInterval:           cfg.Simulation.MinInterval.Duration,
+		DefaultInterval:       cfg.Simulation.DefaultInterval.Duration,
+		DefaultUpdatesPerTick: cfg.Simulation.DefaultUpdatesPerTick,
+		MaxUpdatesPerTick:     cfg.Simulation.MaxUpdatesPerTick,
+	}
+
+	userService := service.NewUserService(userRepo, scoreRepo, leaderboardRepo, teamRepo, teamBoardRepo, usernameIndex, ratings)
+	var anomalyRules []service.AnomalyRule
+	if cfg.Anomaly.MaxDelta > 0 {
+		anomalyRules = append(anomalyRules, service.NewMaxDeltaRule(cfg.Anomaly.MaxDelta, cfg.Anomaly.MaxDeltaAction))
+	}
+	if cfg.Anomaly.MaxUpdatesPerMinute > 0 {
+		anomalyRules = append(anomalyRules, service.NewUpdateRateRule(
+			cache.NewRateCounter(redisClient), cfg.Anomaly.MaxUpdatesPerMinute, time.Minute, cfg.Anomaly.UpdateRateAction,
+		))
+	}
+	if cfg.Anomaly.ZScoreThreshold > 0 {
+		anomalyRules = append(anomalyRules, service.NewZScoreRule(
+			historyRepo, cfg.Anomaly.ZScoreThreshold, cfg.Anomaly.ZScoreWindow, cfg.Anomaly.ZScoreMinSamples, cfg.Anomaly.ZScoreAction,
+		))
+	}
+	detector := service.NewAnomalyDetector(anomalyRules...)
 
-	userService := service.NewUserService(userRepo, scoreRepo, leaderboardRepo)
-	leaderboardService := service.NewLeaderboardService(userRepo, scoreRepo, leaderboardRepo)
-	simulationService := service.NewSimulationService(leaderboardRepo, scoreRepo)
+	rebuildOptions := service.RebuildOptions{
+		BatchSize: cfg.Rebuild.BatchSize,
+		LockTTL:   cfg.Rebuild.LockTTL.Duration,
+	}
+	leaderboardService := service.NewLeaderboardService(userRepo, scoreRepo, leaderboardRepo, usernameIndex, historyRepo, anomalyRepo, detector, ratings, rebuildOptions)
+	webhookService := service.NewWebhookService(webhookRepo, leaderboardRepo, service.WebhookOptions{
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		Backoff:      cfg.Webhooks.Backoff.Duration,
+		MaxBackoff:   cfg.Webhooks.MaxBackoff.Duration,
+		Timeout:      cfg.Webhooks.Timeout.Duration,
+		PollInterval: cfg.Webhooks.PollInterval.Duration,
+		Workers:      cfg.Webhooks.Workers,
+	})
+	leaderboardService.AddListener(webhookService)
+	simulationService := service.NewSimulationService(userRepo, leaderboardRepo, usernameIndex, scoreRepo, simulationRunRepo, ratings, simulationLimits, cfg.Simulation.RecordDir, service.SimulationCluster{
+		Leases:     leaseStore,
+		State:      cache.NewSimulationStateStore(redisClient),
+		InstanceID: instanceID,
+		LeaseTTL:   cfg.Cluster.LeaseTTL.Duration,
+	})
+	friendService := service.NewFriendService(userRepo, friendshipRepo, leaderboardRepo)
+	teamService := service.NewTeamService(teamRepo, userRepo, leaderboardRepo, teamBoardRepo, teamAggregate)
+	moderationService := service.NewModerationService(anomalyRepo, leaderboardService)
+	decayService := service.NewDecayService(scoreRepo, leaderboardRepo, historyRepo, ratings, entity.DecayPolicy{
+		Mode:          cfg.Decay.Mode,
+		Amount:        cfg.Decay.Amount,
+		Floor:         cfg.Decay.Floor,
+		InactiveAfter: cfg.Decay.InactiveAfter.Duration,
+	}, service.DecayOptions{
+		BatchSize: cfg.Decay.BatchSize,
+	}, jobCluster)
+	importService := service.NewImportService(importRepo, userRepo, scoreRepo, leaderboardRepo, usernameIndex, ratings, rebuildOptions)
+	reconcileService := service.NewReconcileService(scoreRepo, leaderboardRepo, service.ReconcileOptions{
+		BatchSize:   cfg.Reconcile.BatchSize,
+		GracePeriod: cfg.Reconcile.GracePeriod.Duration,
+	}, jobCluster)
 
 	userHandler := handler.NewUserHandler(userService)
 	leaderboardHandler := handler.NewLeaderboardHandler(leaderboardService)
 	simulationHandler := handler.NewSimulationHandler(simulationService)
-
-	r := router.NewRouter(userHandler, leaderboardHandler, simulationHandler)
-	engine := r.Setup(cfg.Server.Mode)
+	reconcileHandler := handler.NewReconcileHandler(reconcileService)
+	friendHandler := handler.NewFriendHandler(friendService)
+	teamHandler := handler.NewTeamHandler(teamService)
+	decayHandler := handler.NewDecayHandler(decayService)
+	moderationHandler := handler.NewModerationHandler(moderationService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	importHandler := handler.NewImportHandler(importService)
+	eventHandler := handler.NewEventHandler(service.NewEventService(cache.NewEventStream(redisClient), service.EventOptions{
+		ClaimIdle: cfg.Events.ClaimIdle.Duration,
+		MaxBlock:  cfg.Events.MaxBlock.Duration,
+	}))
+
+	r := router.NewRouter(
+		userHandler, leaderboardHandler, simulationHandler, reconcileHandler,
+		friendHandler, teamHandler, decayHandler, moderationHandler, webhookHandler,
+		eventHandler, importHandler,
+	)
+	idempotency := middleware.Idempotency(
+		cache.NewIdempotencyStore(redisClient), cfg.Idempotency.TTL.Duration, cfg.Idempotency.LockTTL.Duration,
+	)
+	engine := r.Setup(cfg.Server.Mode, cfg.Server.CORSOrigins, cfg.Server.APIKeys, idempotency)
 
 	srv := &http.Server{
-		Addr:    ":" + cfg.Server.Port,
-		Handler: engine,
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      engine,
+		ReadTimeout:  cfg.Server.ReadTimeout.Duration,
+		WriteTimeout: cfg.Server.WriteTimeout.Duration,
+		IdleTimeout:  cfg.Server.IdleTimeout.Duration,
+	}
+
+	if rebuilt, err := leaderboardService.RebuildIfEmpty(context.Background()); err != nil {
+		log.Printf("failed to load empty leaderboard from postgres: %v", err)
+	} else if rebuilt {
+		log.Println("leaderboard was empty, loaded from postgres")
+	}
+
+	if indexed, err := userService.IndexUsernamesIfEmpty(context.Background()); err != nil {
+		log.Printf("failed to index usernames: %v", err)
+	} else if indexed {
+		log.Println("username index was empty, loaded from postgres")
+	}
+
+	if synced, err := teamService.SyncIfStale(context.Background()); err != nil {
+		log.Printf("failed to sync team leaderboard: %v", err)
+	} else if synced {
+		log.Println("team leaderboard synced from postgres")
+	}
+
+	// Scheduled jobs run on one instance only: the leader.
+	leader := service.NewLeaderElector(leaseStore, "jobs", instanceID, cfg.Cluster.LeaseTTL.Duration)
+	if cfg.Reconcile.Interval.Duration > 0 {
+		leader.Add("reconcile", func() {
+			reconcileService.StartSchedule(cfg.Reconcile.Interval.Duration, cfg.Reconcile.Repair)
+		}, reconcileService.Stop)
+	}
+	if cfg.Decay.Interval.Duration > 0 {
+		leader.Add("decay", func() {
+			decayService.StartSchedule(cfg.Decay.Interval.Duration, cfg.Decay.DryRun)
+		}, decayService.Stop)
 	}
+	log.Printf("instance %s", instanceID)
+	leader.Start()
+	webhookService.Start()
 
 	go func() {
 		log.Printf("server starting on port %s", cfg.Server.Port)
@@ -69,8 +228,10 @@ func main() {
 	log.Println("shutting down server...")
 
 	simulationService.Stop()
+	leader.Stop()
+	webhookService.Stop()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
@@ -0,0 +1,176 @@
+// Command loadtest drives a mix of leaderboard reads, rank lookups, searches
+// and score writes against a running API and reports latency percentiles,
+// error rates and throughput.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rankq/backend/pkg/config"
+)
+
+type options struct {
+	baseURL     string
+	rps         float64
+	concurrency int
+	duration    time.Duration
+	rampUp      time.Duration
+	timeout     time.Duration
+	mix         mix
+	users       int
+	createUsers int
+	pageSize    int
+	maxPage     int
+	maxDelta    int
+	minRating   int
+	maxRating   int
+	seed        int64
+	progress    time.Duration
+}
+
+func main() {
+	var opts options
+	defaults := config.Default()
+	var mixSpec, format, out string
+	flag.StringVar(&opts.baseURL, "url", "http://localhost:8080", "base URL of the API")
+	flag.Float64Var(&opts.rps, "rps", 100, "target requests per second (0 sends as fast as the workers allow)")
+	flag.IntVar(&opts.concurrency, "c", 50, "number of concurrent workers")
+	flag.DurationVar(&opts.duration, "d", 30*time.Second, "test duration, including the ramp-up")
+	flag.DurationVar(&opts.rampUp, "ramp-up", 0, "time to raise the load linearly from zero to the target")
+	flag.DurationVar(&opts.timeout, "timeout", 10*time.Second, "per-request timeout")
+	flag.StringVar(&mixSpec, "mix", defaultMix, "weighted operation mix (leaderboard, rank, search, write)")
+	flag.IntVar(&opts.users, "users", 1000, "number of leaderboard users to sample as targets for rank lookups, searches and writes")
+	flag.IntVar(&opts.createUsers, "create-users", 0, "create this many users before the test starts")
+	flag.IntVar(&opts.pageSize, "page-size", 20, "leaderboard page size")
+	flag.IntVar(&opts.maxPage, "max-page", 50, "highest leaderboard page to read")
+	flag.IntVar(&opts.maxDelta, "max-delta", 50, "largest rating change of a score write")
+	flag.IntVar(&opts.minRating, "min-rating", defaults.Rating.Min, "lowest rating a score write sends")
+	flag.IntVar(&opts.maxRating, "max-rating", defaults.Rating.Max, "highest rating a score write sends")
+	flag.Int64Var(&opts.seed, "seed", 0, "random seed for the operation sequence (default: time based)")
+	flag.DurationVar(&opts.progress, "progress", 5*time.Second, "interval of progress lines on stderr (0 disables them)")
+	flag.StringVar(&format, "format", "text", "report format: text or json")
+	flag.StringVar(&out, "o", "-", `report file ("-" for stdout)`)
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("loadtest: ")
+
+	var err error
+	if opts.mix, err = parseMix(mixSpec); err != nil {
+		log.Fatal(err)
+	}
+	if err := opts.validate(); err != nil {
+		log.Fatal(err)
+	}
+	if format != "text" && format != "json" {
+		log.Fatalf("format must be text or json")
+	}
+	opts.baseURL = strings.TrimRight(opts.baseURL, "/")
+	if opts.seed == 0 {
+		opts.seed = time.Now().UnixNano()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := &http.Client{
+		Timeout: opts.timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        opts.concurrency,
+			MaxIdleConnsPerHost: opts.concurrency,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	api := &apiClient{http: client, baseURL: opts.baseURL}
+
+	if err := api.health(ctx); err != nil {
+		log.Fatalf("server at %s is not healthy: %v", opts.baseURL, err)
+	}
+
+	rng := rand.New(rand.NewSource(opts.seed))
+	if opts.createUsers > 0 {
+		log.Printf("creating %d users", opts.createUsers)
+		if err := api.createUsers(ctx, opts.createUsers, rng); err != nil {
+			log.Fatalf("failed to create users: %v", err)
+		}
+	}
+
+	sample, err := api.sampleUsers(ctx, opts.users)
+	if err != nil {
+		log.Fatalf("failed to sample users: %v", err)
+	}
+	if len(sample.targets) == 0 && opts.mix.needsUsers() {
+		log.Fatalf("the leaderboard is empty; seed it or pass -create-users")
+	}
+	log.Printf("sampled %d of %d users; running for %v at %s with %d workers", len(sample.targets), sample.total, opts.duration, rateLabel(opts.rps), opts.concurrency)
+
+	report := run(ctx, api, opts, newWorkload(opts, sample))
+
+	w := io.Writer(os.Stdout)
+	if out != "-" {
+		file, err := os.Create(out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.writeText(w)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (o *options) validate() error {
+	switch {
+	case o.rps < 0:
+		return fmt.Errorf("rps must not be negative")
+	case o.concurrency < 1:
+		return fmt.Errorf("c must be at least 1")
+	case o.duration <= 0:
+		return fmt.Errorf("d must be positive")
+	case o.rampUp < 0 || o.rampUp > o.duration:
+		return fmt.Errorf("ramp-up must be between 0 and the duration")
+	case o.timeout <= 0:
+		return fmt.Errorf("timeout must be positive")
+	case o.users < 1:
+		return fmt.Errorf("users must be at least 1")
+	case o.createUsers < 0:
+		return fmt.Errorf("create-users must not be negative")
+	case o.pageSize < 1 || o.pageSize > 100:
+		return fmt.Errorf("page-size must be between 1 and 100")
+	case o.maxPage < 1:
+		return fmt.Errorf("max-page must be at least 1")
+	case o.maxDelta < 0:
+		return fmt.Errorf("max-delta must not be negative")
+	case o.minRating > o.maxRating:
+		return fmt.Errorf("min-rating must not exceed max-rating")
+	}
+	return nil
+}
+
+func rateLabel(rps float64) string {
+	if rps == 0 {
+		return "an unlimited rate"
+	}
+	return fmt.Sprintf("%g req/s", rps)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// opStats collects the outcome of every request of one operation.
+type opStats struct {
+	requests        int64
+	steadyRequests  int64
+	errors          int64
+	transportErrors int64
+	statusCodes     map[int]int64
+	latencies       []time.Duration
+}
+
+type stats struct {
+	ops [numOps]*opStats
+}
+
+func newStats() *stats {
+	s := &stats{}
+	for i := range s.ops {
+		s.ops[i] = &opStats{statusCodes: make(map[int]int64)}
+	}
+	return s
+}
+
+// record adds one request and reports whether it failed. Any response other
+// than 2xx counts as an error.
+func (s *stats) record(o op, status int, err error, latency time.Duration, steady bool) bool {
+	st := s.ops[o]
+	st.requests++
+	if steady {
+		st.steadyRequests++
+	}
+	st.latencies = append(st.latencies, latency)
+
+	switch {
+	case err != nil && status == 0:
+		st.transportErrors++
+	case err == nil && status >= 200 && status < 300:
+		st.statusCodes[status]++
+		return false
+	default:
+		st.statusCodes[status]++
+	}
+	st.errors++
+	return true
+}
+
+func (s *stats) merge(other *stats) {
+	for i, st := range s.ops {
+		o := other.ops[i]
+		st.requests += o.requests
+		st.steadyRequests += o.steadyRequests
+		st.errors += o.errors
+		st.transportErrors += o.transportErrors
+		for code, n := range o.statusCodes {
+			st.statusCodes[code] += n
+		}
+		st.latencies = append(st.latencies, o.latencies...)
+	}
+}
+
+// latency summarizes a latency distribution in milliseconds.
+type latency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+	Max  float64 `json:"max"`
+}
+
+func summarize(latencies []time.Duration) latency {
+	if len(latencies) == 0 {
+		return latency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	// Nearest-rank percentiles.
+	at := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(latencies)))) - 1
+		return ms(latencies[max(0, i)])
+	}
+	return latency{
+		Mean: ms(sum / time.Duration(len(latencies))),
+		P50:  at(0.50),
+		P90:  at(0.90),
+		P95:  at(0.95),
+		P99:  at(0.99),
+		P999: at(0.999),
+		Max:  ms(latencies[len(latencies)-1]),
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+type result struct {
+	Requests        int64            `json:"requests"`
+	Errors          int64            `json:"errors"`
+	ErrorRate       float64          `json:"error_rate"`
+	Throughput      float64          `json:"throughput_rps"`
+	SteadyRate      float64          `json:"steady_throughput_rps"`
+	TransportErrors int64            `json:"transport_errors"`
+	StatusCodes     map[string]int64 `json:"status_codes"`
+	LatencyMs       latency          `json:"latency_ms"`
+}
+
+type report struct {
+	URL         string            `json:"url"`
+	Seed        int64             `json:"seed"`
+	Mix         map[string]int    `json:"mix"`
+	TargetRPS   float64           `json:"target_rps"`
+	Concurrency int               `json:"concurrency"`
+	Duration    float64           `json:"duration_s"`
+	RampUp      float64           `json:"ramp_up_s"`
+	Elapsed     float64           `json:"elapsed_s"`
+	Missed      int64             `json:"missed"`
+	Total       result            `json:"total"`
+	Operations  map[string]result `json:"operations"`
+}
+
+func newReport(opts options, s *stats, elapsed time.Duration, missed int64) *report {
+	r := &report{
+		URL:         opts.baseURL,
+		Seed:        opts.seed,
+		Mix:         make(map[string]int),
+		TargetRPS:   opts.rps,
+		Concurrency: opts.concurrency,
+		Duration:    opts.duration.Seconds(),
+		RampUp:      opts.rampUp.Seconds(),
+		Elapsed:     math.Round(elapsed.Seconds()*1000) / 1000,
+		Missed:      missed,
+		Operations:  make(map[string]result),
+	}
+
+	// Steady-state throughput leaves out the ramp-up, and the time spent
+	// draining requests after the deadline.
+	steadyWindow := min(elapsed, opts.duration) - opts.rampUp
+
+	total := &opStats{statusCodes: make(map[int]int64)}
+	for o, st := range s.ops {
+		if opts.mix.weights[o] == 0 {
+			continue
+		}
+		r.Mix[op(o).String()] = opts.mix.weights[o]
+		r.Operations[op(o).String()] = newResult(st, elapsed, steadyWindow)
+
+		total.requests += st.requests
+		total.steadyRequests += st.steadyRequests
+		total.errors += st.errors
+		total.transportErrors += st.transportErrors
+		for code, n := range st.statusCodes {
+			total.statusCodes[code] += n
+		}
+		total.latencies = append(total.latencies, st.latencies...)
+	}
+	r.Total = newResult(total, elapsed, steadyWindow)
+	return r
+}
+
+func newResult(st *opStats, elapsed, steadyWindow time.Duration) result {
+	res := result{
+		Requests:        st.requests,
+		Errors:          st.errors,
+		TransportErrors: st.transportErrors,
+		StatusCodes:     make(map[string]int64),
+		LatencyMs:       summarize(st.latencies),
+	}
+	if st.requests > 0 {
+		res.ErrorRate = float64(st.errors) / float64(st.requests)
+	}
+	if elapsed > 0 {
+		res.Throughput = round1(float64(st.requests) / elapsed.Seconds())
+	}
+	if steadyWindow > 0 {
+		res.SteadyRate = round1(float64(st.steadyRequests) / steadyWindow.Seconds())
+	}
+	for code, n := range st.statusCodes {
+		res.StatusCodes[strconv.Itoa(code)] = n
+	}
+	return res
+}
+
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
+
+func (r *report) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "target       %s\n", r.URL)
+	fmt.Fprintf(w, "load         %s, %d workers, %gs (ramp-up %gs), seed %d\n", rateLabel(r.TargetRPS), r.Concurrency, r.Duration, r.RampUp, r.Seed)
+	fmt.Fprintf(w, "elapsed      %.3fs\n", r.Elapsed)
+	fmt.Fprintf(w, "requests     %d (%.1f req/s, %.1f req/s after ramp-up)\n", r.Total.Requests, r.Total.Throughput, r.Total.SteadyRate)
+	fmt.Fprintf(w, "errors       %d (%.2f%%), %d transport errors\n", r.Total.Errors, 100*r.Total.ErrorRate, r.Total.TransportErrors)
+	if r.TargetRPS > 0 {
+		fmt.Fprintf(w, "missed       %d (every worker was busy when these were due)\n", r.Missed)
+	}
+	fmt.Fprintf(w, "status codes %s\n\n", formatCodes(r.Total.StatusCodes))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\trequests\treq/s\terrors\tmean\tp50\tp90\tp95\tp99\tp99.9\tmax\t")
+	row := func(name string, res result) {
+		l := res.LatencyMs
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.2f%%\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			name, res.Requests, res.Throughput, 100*res.ErrorRate, l.Mean, l.P50, l.P90, l.P95, l.P99, l.P999, l.Max)
+	}
+	for o := op(0); o < numOps; o++ {
+		if res, ok := r.Operations[o.String()]; ok {
+			row(o.String(), res)
+		}
+	}
+	row("total", r.Total)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, "\nlatencies in milliseconds")
+	return err
+}
+
+func formatCodes(codes map[string]int64) string {
+	if len(codes) == 0 {
+		return "none"
+	}
+	keys := make([]string, 0, len(codes))
+	for code := range codes {
+		keys = append(keys, code)
+	}
+	sort.Strings(keys)
+	out := ""
+	for i, code := range keys {
+		if i > 0 {
+			out += ", "
+		}
+		out += fmt.Sprintf("%s: %d", code, codes[code])
+	}
+	return out
+}
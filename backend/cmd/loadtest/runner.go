@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dispatchTick is how often the dispatcher hands out the requests that have
+// come due under the target rate.
+const dispatchTick = 5 * time.Millisecond
+
+// counters are shared by the workers for progress lines.
+type counters struct {
+	requests atomic.Int64
+	errors   atomic.Int64
+	missed   atomic.Int64
+}
+
+// run drives the workload for opts.duration or until ctx is cancelled.
+// Requests still in flight at the end are allowed to finish.
+func run(ctx context.Context, api *apiClient, opts options, wl *workload) *report {
+	start := time.Now()
+	end := start.Add(opts.duration)
+	runCtx, cancel := context.WithDeadline(ctx, end)
+	defer cancel()
+
+	var live counters
+	var tokens chan struct{}
+	if opts.rps > 0 {
+		tokens = make(chan struct{}, opts.concurrency)
+		go dispatch(runCtx, tokens, opts, start, &live)
+	}
+
+	workers := make([]*stats, opts.concurrency)
+	var wg sync.WaitGroup
+	for i := range workers {
+		workers[i] = newStats()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := &worker{
+				api:      api,
+				wl:       wl,
+				rng:      rand.New(rand.NewSource(opts.seed + int64(i))),
+				stats:    workers[i],
+				live:     &live,
+				steadyAt: start.Add(opts.rampUp),
+			}
+			if tokens != nil {
+				w.paced(ctx, tokens)
+				return
+			}
+			// Without a target rate, ramping up means starting the workers
+			// one after another.
+			delay := time.Duration(int64(opts.rampUp) * int64(i) / int64(opts.concurrency))
+			w.unpaced(ctx, runCtx, start.Add(delay))
+		}(i)
+	}
+
+	progressDone := make(chan struct{})
+	if opts.progress > 0 {
+		go reportProgress(runCtx, opts.progress, start, &live, progressDone)
+	} else {
+		close(progressDone)
+	}
+
+	wg.Wait()
+	cancel()
+	<-progressDone
+
+	total := newStats()
+	for _, s := range workers {
+		total.merge(s)
+	}
+	return newReport(opts, total, time.Since(start), live.missed.Load())
+}
+
+// dispatch sends one token per request that is due, raising the rate
+// linearly during the ramp-up. Tokens that find every worker busy and the
+// buffer full are counted as missed rather than queued, so a saturated
+// server shows up as missed requests instead of hidden queueing delay.
+func dispatch(ctx context.Context, tokens chan<- struct{}, opts options, start time.Time, live *counters) {
+	defer close(tokens)
+
+	ticker := time.NewTicker(dispatchTick)
+	defer ticker.Stop()
+
+	var sent int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			due := int64(scheduled(now.Sub(start), opts.rps, opts.rampUp))
+			for sent < due {
+				select {
+				case tokens <- struct{}{}:
+					sent++
+				default:
+					live.missed.Add(due - sent)
+					sent = due
+				}
+			}
+		}
+	}
+}
+
+// scheduled is the number of requests due after elapsed: the integral of a
+// rate that grows linearly from zero to rps over rampUp and then stays.
+func scheduled(elapsed time.Duration, rps float64, rampUp time.Duration) float64 {
+	t := elapsed.Seconds()
+	r := rampUp.Seconds()
+	if t < r {
+		return rps * t * t / (2 * r)
+	}
+	return rps*r/2 + rps*(t-r)
+}
+
+type worker struct {
+	api      *apiClient
+	wl       *workload
+	rng      *rand.Rand
+	stats    *stats
+	live     *counters
+	steadyAt time.Time
+}
+
+func (w *worker) paced(ctx context.Context, tokens <-chan struct{}) {
+	for range tokens {
+		w.send(ctx)
+	}
+}
+
+func (w *worker) unpaced(ctx, runCtx context.Context, startAt time.Time) {
+	select {
+	case <-runCtx.Done():
+		return
+	case <-time.After(time.Until(startAt)):
+	}
+	for runCtx.Err() == nil {
+		w.send(ctx)
+	}
+}
+
+// send issues one request under ctx, which is only cancelled on interrupt,
+// so requests started before the deadline still complete.
+func (w *worker) send(ctx context.Context) {
+	req := w.wl.next(w.rng)
+	began := time.Now()
+	status, err := w.api.do(ctx, req.method, req.path, req.body)
+	finished := time.Now()
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+
+	failed := w.stats.record(req.op, status, err, finished.Sub(began), !finished.Before(w.steadyAt))
+	w.live.requests.Add(1)
+	if failed {
+		w.live.errors.Add(1)
+	}
+}
+
+func reportProgress(ctx context.Context, interval time.Duration, start time.Time, live *counters, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			requests := live.requests.Load()
+			log.Printf("%5.0fs  %8d requests  %8.1f req/s  %6d errors  %6d missed",
+				now.Sub(start).Seconds(), requests, float64(requests-last)/interval.Seconds(),
+				live.errors.Load(), live.missed.Load())
+			last = requests
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type op int
+
+const (
+	opLeaderboard op = iota
+	opRank
+	opSearch
+	opWrite
+	numOps
+)
+
+var opNames = [numOps]string{"leaderboard", "rank", "search", "write"}
+
+const defaultMix = "leaderboard=60,rank=20,search=10,write=10"
+
+func (o op) String() string {
+	return opNames[o]
+}
+
+// mix holds the relative weight of each operation.
+type mix struct {
+	weights [numOps]int
+	total   int
+}
+
+// parseMix reads a list like "leaderboard=60,write=40". Operations left out
+// are not run.
+func parseMix(spec string) (mix, error) {
+	var m mix
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return m, fmt.Errorf("mix entry %q must look like name=weight", part)
+		}
+		o := op(-1)
+		for i, n := range opNames {
+			if n == strings.TrimSpace(name) {
+				o = op(i)
+			}
+		}
+		if o < 0 {
+			return m, fmt.Errorf("unknown operation %q in mix, want one of %s", name, strings.Join(opNames[:], ", "))
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || weight < 0 {
+			return m, fmt.Errorf("weight of %s must be a non-negative integer", name)
+		}
+		m.weights[o] = weight
+	}
+	for _, w := range m.weights {
+		m.total += w
+	}
+	if m.total == 0 {
+		return m, fmt.Errorf("mix must give at least one operation a positive weight")
+	}
+	return m, nil
+}
+
+func (m mix) pick(rng *rand.Rand) op {
+	n := rng.Intn(m.total)
+	for o, w := range m.weights {
+		if n < w {
+			return op(o)
+		}
+		n -= w
+	}
+	return opLeaderboard
+}
+
+func (m mix) needsUsers() bool {
+	return m.weights[opRank]+m.weights[opSearch]+m.weights[opWrite] > 0
+}
+
+// target is a sampled leaderboard user.
+type target struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+}
+
+type request struct {
+	op     op
+	method string
+	path   string
+	body   []byte
+}
+
+type workload struct {
+	mix       mix
+	targets   []target
+	pageSize  int
+	pages     int
+	maxDelta  int
+	minRating int
+	maxRating int
+}
+
+func newWorkload(opts options, sample userSample) *workload {
+	return &workload{
+		mix:       opts.mix,
+		targets:   sample.targets,
+		pageSize:  opts.pageSize,
+		pages:     max(1, min(opts.maxPage, (sample.total+opts.pageSize-1)/opts.pageSize)),
+		maxDelta:  opts.maxDelta,
+		minRating: opts.minRating,
+		maxRating: opts.maxRating,
+	}
+}
+
+func (w *workload) next(rng *rand.Rand) request {
+	o := w.mix.pick(rng)
+	if o == opLeaderboard {
+		page := 1 + rng.Intn(w.pages)
+		return request{op: o, method: http.MethodGet, path: fmt.Sprintf("/api/v1/leaderboard?page=%d&page_size=%d", page, w.pageSize)}
+	}
+
+	t := w.targets[rng.Intn(len(w.targets))]
+	switch o {
+	case opRank:
+		return request{op: o, method: http.MethodGet, path: "/api/v1/leaderboard/user/" + t.UserID}
+	case opSearch:
+		return request{op: o, method: http.MethodGet, path: "/api/v1/leaderboard/search?q=" + url.QueryEscape(searchPrefix(t.Username, rng))}
+	default:
+		// Small moves around the sampled rating look like real play and
+		// stay clear of anomaly rules that flag large jumps.
+		rating := t.Rating + rng.Intn(2*w.maxDelta+1) - w.maxDelta
+		rating = max(w.minRating, min(w.maxRating, rating))
+		body, _ := json.Marshal(map[string]int{"rating": rating})
+		return request{op: o, method: http.MethodPut, path: "/api/v1/leaderboard/user/" + t.UserID + "/score", body: body}
+	}
+}
+
+// searchPrefix returns the first 3 to 6 characters of a username.
+func searchPrefix(username string, rng *rand.Rand) string {
+	n := 3 + rng.Intn(4)
+	if utf8.RuneCountInString(username) <= n {
+		return username
+	}
+	return string([]rune(username)[:n])
+}
+
+type apiClient struct {
+	http    *http.Client
+	baseURL string
+}
+
+// do sends a request and drains the response so the connection is reused.
+func (c *apiClient) do(ctx context.Context, method, path string, body []byte) (int, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+func (c *apiClient) getJSON(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *apiClient) health(ctx context.Context) error {
+	var body struct {
+		Status string `json:"status"`
+	}
+	return c.getJSON(ctx, "/api/v1/health", &body)
+}
+
+// userSample is a set of users spread over the whole leaderboard, and the
+// leaderboard's size.
+type userSample struct {
+	targets []target
+	total   int
+}
+
+// sampleUsers reads up to n users from evenly spaced leaderboard pages, so
+// writes and lookups hit the top, the middle and the tail alike.
+func (c *apiClient) sampleUsers(ctx context.Context, n int) (userSample, error) {
+	const pageSize = 100
+
+	var page struct {
+		Data []target `json:"data"`
+		Meta struct {
+			Total      int `json:"total"`
+			TotalPages int `json:"total_pages"`
+		} `json:"meta"`
+	}
+	read := func(p int) error {
+		page.Data = nil
+		return c.getJSON(ctx, fmt.Sprintf("/api/v1/leaderboard?page=%d&page_size=%d", p, pageSize), &page)
+	}
+
+	if err := read(1); err != nil {
+		return userSample{}, err
+	}
+	sample := userSample{targets: page.Data, total: page.Meta.Total}
+	pages := page.Meta.TotalPages
+	wanted := min(pages, (n+pageSize-1)/pageSize)
+	for i := 1; i < wanted; i++ {
+		if err := read(1 + i*pages/wanted); err != nil {
+			return userSample{}, err
+		}
+		sample.targets = append(sample.targets, page.Data...)
+	}
+	if len(sample.targets) > n {
+		sample.targets = sample.targets[:n]
+	}
+	return sample, nil
+}
+
+// createUsers creates n users with names unique to this run, a few at a
+// time.
+func (c *apiClient) createUsers(ctx context.Context, n int, rng *rand.Rand) error {
+	const workers = 16
+	prefix := fmt.Sprintf("loadtest_%06x", rng.Intn(1<<24))
+
+	next := make(chan int)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				body, _ := json.Marshal(map[string]string{"username": fmt.Sprintf("%s_%d", prefix, i)})
+				status, err := c.do(ctx, http.MethodPost, "/api/v1/users", body)
+				if err == nil && status != http.StatusCreated && status != http.StatusConflict {
+					err = fmt.Errorf("POST /api/v1/users: status %d", status)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+send:
+	for i := range n {
+		select {
+		case next <- i:
+		case err = <-errs:
+			break send
+		}
+	}
+	close(next)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	select {
+	case err = <-errs:
+	default:
+	}
+	return err
+}
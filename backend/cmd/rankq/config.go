@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rankq/backend/pkg/config"
+)
+
+func runConfig(args []string) error {
+	if len(args) < 1 || args[0] != "print" {
+		return fmt.Errorf("usage: rankq config print [-config path] [-format yaml|toml] [-show-secrets]")
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	path := configFlag(fs)
+	format := fs.String("format", "yaml", "output format: yaml or toml")
+	showSecrets := fs.Bool("show-secrets", false, "print passwords instead of masking them")
+	fs.Parse(args[1:])
+
+	cfg, err := config.LoadFile(*path)
+	if err != nil {
+		return err
+	}
+	if !*showSecrets {
+		cfg = cfg.Redacted()
+	}
+
+	out, err := cfg.Encode(*format)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// configFlag adds the -config flag that every command reading the
+// configuration accepts.
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", os.Getenv(config.ConfigFileEnv), "path to a YAML or TOML config file")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/infrastructure/database"
+	"github.com/rankq/backend/pkg/config"
+)
+
+// runExport dumps the standings from Postgres, the durable copy of every
+// score, inside a single snapshot so the file is consistent even while
+// scores keep changing.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := configFlag(fs)
+	format := fs.String("format", service.ExportCSV, "output format: csv, ndjson or json")
+	out := fs.String("o", "", `output file (default leaderboard-<timestamp>.<format>, "-" for stdout)`)
+	batchSize := fs.Int("batch-size", 5000, "rows read per query")
+	fs.Parse(args)
+
+	if *batchSize < 1 {
+		return fmt.Errorf("batch-size must be at least 1")
+	}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := database.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	now := time.Now().UTC()
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("leaderboard-%s.%s", now.Format("20060102-150405"), *format)
+	}
+
+	// Write to a temporary file and rename it into place, so a failed
+	// export never leaves a truncated file behind.
+	file := os.Stdout
+	if path != "-" {
+		file, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+	}
+
+	w, err := service.NewExportWriter(file, *format, now)
+	if err != nil {
+		return err
+	}
+
+	scoreRepo := database.NewScoreRepository(db)
+	err = scoreRepo.StreamStandings(context.Background(), *batchSize, func(batch []entity.ExportEntry) error {
+		return w.Write(batch)
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if path == "-" {
+		return nil
+	}
+	// CreateTemp makes the file private; give it normal data file permissions.
+	if err := file.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d entries to %s\n", w.Count(), path)
+	return nil
+}
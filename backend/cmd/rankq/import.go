@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/infrastructure/cache"
+	"github.com/rankq/backend/internal/infrastructure/database"
+	"github.com/rankq/backend/pkg/config"
+)
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := configFlag(fs)
+	format := fs.String("format", "", "input format: csv or ndjson (default from the file extension)")
+	policy := fs.String("on-duplicate", entity.ImportSkip, "what to do with existing usernames: skip, overwrite or fail")
+	dryRun := fs.Bool("dry-run", false, "validate and report without changing anything")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: rankq import [-config path] [-format csv|ndjson] [-on-duplicate skip|overwrite|fail] [-dry-run] <file|->")
+	}
+	path := fs.Arg(0)
+
+	if *format == "" {
+		*format = service.ExportCSV
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ndjson", ".jsonl":
+			*format = service.ExportNDJSON
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := database.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	redisClient, err := cache.NewRedisClient(cfg.Redis)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	teamAggregate := entity.TeamAggregate{Mode: cfg.Teams.Aggregate, K: cfg.Teams.TopK}
+	importService := service.NewImportService(
+		database.NewImportRepository(db),
+		database.NewUserRepository(db),
+		database.NewScoreRepository(db),
+		cache.NewLeaderboardRepository(redisClient, teamAggregate, int64(cfg.Events.MaxLen)),
+		cache.NewUsernameIndex(redisClient),
+		entity.RatingRange{Min: cfg.Rating.Min, Max: cfg.Rating.Max, Default: cfg.Rating.Default},
+		service.RebuildOptions{BatchSize: cfg.Rebuild.BatchSize, LockTTL: cfg.Rebuild.LockTTL.Duration},
+	)
+
+	report, err := importService.Import(context.Background(), in, service.ImportOptions{
+		Format: *format,
+		Policy: *policy,
+		DryRun: *dryRun,
+	})
+	if report != nil {
+		printImportReport(report)
+	}
+	return err
+}
+
+func printImportReport(report *entity.ImportReport) {
+	if report.DryRun {
+		fmt.Println("dry run, nothing was changed")
+	}
+	fmt.Printf("rows:     %d\n", report.Rows)
+	fmt.Printf("created:  %d\n", report.Created)
+	fmt.Printf("updated:  %d\n", report.Updated)
+	fmt.Printf("skipped:  %d\n", report.Skipped)
+	fmt.Printf("rejected: %d\n", report.Rejected)
+
+	for _, e := range report.Errors {
+		if e.Username != "" {
+			fmt.Printf("line %d (%s): %s\n", e.Line, e.Username, e.Message)
+		} else {
+			fmt.Printf("line %d: %s\n", e.Line, e.Message)
+		}
+	}
+	if listed := len(report.Errors); listed < report.Skipped+report.Rejected && listed == entity.MaxImportErrors {
+		fmt.Printf("(only the first %d problems are listed)\n", listed)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/infrastructure/cache"
+	"github.com/rankq/backend/internal/infrastructure/database"
+	"github.com/rankq/backend/pkg/config"
+)
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := configFlag(fs)
+	speed := fs.Float64("speed", 1, "playback speed relative to the recorded interval; 0 replays without pauses")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *speed < 0 {
+		return fmt.Errorf("usage: rankq replay [-config path] [-speed 1] <record file>")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cfg, err := config.LoadFile(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := database.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	redisClient, err := cache.NewRedisClient(cfg.Redis)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	teamAggregate := entity.TeamAggregate{Mode: cfg.Teams.Aggregate, K: cfg.Teams.TopK}
+	simulationService := service.NewSimulationService(
+		database.NewUserRepository(db),
+		cache.NewLeaderboardRepository(redisClient, teamAggregate, int64(cfg.Events.MaxLen)),
+		cache.NewUsernameIndex(redisClient),
+		database.NewScoreRepository(db),
+		database.NewSimulationRunRepository(db),
+		entity.RatingRange{Min: cfg.Rating.Min, Max: cfg.Rating.Max, Default: cfg.Rating.Default},
+		service.SimulationLimits{},
+		"",
+		service.SimulationCluster{},
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := simulationService.Replay(ctx, file, service.ReplayOptions{Speed: *speed})
+	if report != nil {
+		if report.Run != nil {
+			fmt.Printf("run:           %d (%s, seed %d)\n", report.Run.ID, report.Run.Scenario, report.Run.Seed)
+		}
+		fmt.Printf("ticks:         %d\n", report.Ticks)
+		fmt.Printf("updates:       %d\n", report.Updates)
+		fmt.Printf("users created: %d\n", report.UsersCreated)
+		fmt.Printf("errors:        %d\n", report.Errors)
+	}
+	return err
+}
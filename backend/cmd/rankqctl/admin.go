@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+var rebuildCommand = command{name: "rebuild", usage: "[-y]", description: "rebuild the Redis leaderboard from Postgres", run: rebuild}
+
+var reconcileCommands = []command{
+	{name: "start", usage: "[-repair] [-wait]", description: "start a consistency check", run: reconcileStart},
+	{name: "status", usage: "", description: "show whether a check is running and the last report", run: reconcileStatus},
+}
+
+func rebuild(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("rebuild", flag.ContinueOnError)
+	yes := fs.Bool("y", false, "do not ask for confirmation")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+	if !*yes && !e.confirm("Rebuild the leaderboard of "+e.client.baseURL+" from Postgres?") {
+		return fmt.Errorf("aborted")
+	}
+
+	// A rebuild of a large board can outlast the request timeout.
+	c := *e.client
+	c.http = &http.Client{}
+	var resp struct {
+		Message string `json:"message"`
+	}
+	if _, err := c.call(ctx, http.MethodPost, "/leaderboard/rebuild", nil, nil, &resp); err != nil {
+		return err
+	}
+	return e.message("%s", resp.Message)
+}
+
+// reconcileState is the body of GET /admin/reconcile.
+type reconcileState struct {
+	Running bool                    `json:"running"`
+	Report  *entity.ReconcileReport `json:"data"`
+}
+
+func reconcileStart(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("reconcile start", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "fix the discrepancies found")
+	wait := fs.Bool("wait", false, "wait for the check to finish and print its report")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	query := url.Values{"repair": {strconv.FormatBool(*repair)}}
+	if _, err := e.client.call(ctx, http.MethodPost, "/admin/reconcile", query, nil, nil); err != nil {
+		return err
+	}
+	if !*wait {
+		return e.message("reconciliation started (repair=%v)", *repair)
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		var state reconcileState
+		if _, err := e.client.call(ctx, http.MethodGet, "/admin/reconcile", nil, nil, &state); err != nil {
+			return err
+		}
+		if !state.Running {
+			return printReconcile(e, state)
+		}
+	}
+}
+
+func reconcileStatus(ctx context.Context, e *env, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	var state reconcileState
+	if _, err := e.client.call(ctx, http.MethodGet, "/admin/reconcile", nil, nil, &state); err != nil {
+		return err
+	}
+	return printReconcile(e, state)
+}
+
+func printReconcile(e *env, state reconcileState) error {
+	if e.json {
+		return e.printJSON(state)
+	}
+	pairs := [][2]string{{"running", yesNo(state.Running)}}
+	r := state.Report
+	if r == nil {
+		pairs = append(pairs, [2]string{"last report", "none"})
+		return e.fields(pairs)
+	}
+	pairs = append(pairs,
+		[2]string{"started", formatTime(r.StartedAt)},
+		[2]string{"finished", formatTime(r.FinishedAt)},
+		[2]string{"repair", yesNo(r.Repair)},
+		[2]string{"postgres users", strconv.FormatInt(r.PostgresUsers, 10)},
+		[2]string{"redis users", strconv.FormatInt(r.RedisUsers, 10)},
+		[2]string{"missing", strconv.Itoa(r.Missing)},
+		[2]string{"extra", strconv.Itoa(r.Extra)},
+		[2]string{"mismatched", strconv.Itoa(r.Mismatched)},
+		[2]string{"bad counts", strconv.Itoa(r.BadCounts)},
+		[2]string{"repaired", strconv.Itoa(r.Repaired)},
+	)
+	if r.Error != "" {
+		pairs = append(pairs, [2]string{"error", r.Error})
+	}
+	return e.fields(pairs)
+}
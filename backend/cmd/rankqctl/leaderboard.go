@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+var leaderboardCommands = []command{
+	{name: "page", usage: "[-page N] [-size N]", description: "show a page of the leaderboard", run: leaderboardPage},
+	{name: "around", usage: "<user-id> [-n N]", description: "show the users ranked around a user", run: leaderboardAround},
+	{name: "rank", usage: "<user-id>", description: "show a user's rating and rank", run: leaderboardRank},
+	{name: "export", usage: "[-format csv|ndjson] [-f file]", description: "download the full leaderboard", run: leaderboardExport},
+}
+
+// pageMeta is the meta block of paginated responses.
+type pageMeta struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"total_pages"`
+}
+
+func leaderboardPage(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("leaderboard page", flag.ContinueOnError)
+	page := fs.Int("page", 1, "page number")
+	size := fs.Int("size", 20, "page size (at most 100)")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	var resp struct {
+		Data []entity.LeaderboardEntry `json:"data"`
+		Meta pageMeta                  `json:"meta"`
+	}
+	query := url.Values{"page": {strconv.Itoa(*page)}, "page_size": {strconv.Itoa(*size)}}
+	if _, err := e.client.call(ctx, http.MethodGet, "/leaderboard", query, nil, &resp); err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(resp)
+	}
+
+	if err := printEntries(e, resp.Data, ""); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.out, "\npage %d of %d, %d users\n", resp.Meta.Page, resp.Meta.TotalPages, resp.Meta.Total)
+	return err
+}
+
+func leaderboardAround(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("leaderboard around", flag.ContinueOnError)
+	n := fs.Int("n", 5, "number of users on each side (at most 50)")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := parseUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Data []entity.LeaderboardEntry `json:"data"`
+	}
+	query := url.Values{"n": {strconv.Itoa(*n)}}
+	if _, err := e.client.call(ctx, http.MethodGet, "/leaderboard/user/"+id+"/around", query, nil, &resp); err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(resp.Data)
+	}
+	return printEntries(e, resp.Data, id)
+}
+
+func leaderboardRank(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := parseUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Data entity.SearchResult `json:"data"`
+	}
+	if _, err := e.client.call(ctx, http.MethodGet, "/leaderboard/user/"+id, nil, nil, &resp); err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(resp.Data)
+	}
+	pairs := [][2]string{
+		{"username", resp.Data.Username},
+		{"rating", strconv.Itoa(resp.Data.Rating)},
+		{"rank", strconv.FormatInt(resp.Data.Rank, 10)},
+	}
+	if resp.Data.Version != nil {
+		pairs = append(pairs, [2]string{"version", strconv.FormatInt(*resp.Data.Version, 10)})
+	}
+	return e.fields(pairs)
+}
+
+func leaderboardExport(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("leaderboard export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "file format: csv or ndjson")
+	path := fs.String("f", "-", `output file ("-" for stdout)`)
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	// Exports can take longer than the request timeout.
+	c := *e.client
+	c.http = &http.Client{}
+	body, err := c.stream(ctx, "/leaderboard/export", url.Values{"format": {*format}})
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if *path == "-" {
+		_, err := io.Copy(e.out, body)
+		return err
+	}
+
+	// Write to a temporary file and rename it into place, so a failed
+	// download never leaves a truncated file behind.
+	file, err := os.CreateTemp(filepath.Dir(*path), "."+filepath.Base(*path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	n, err := io.Copy(file, body)
+	if err != nil {
+		return err
+	}
+	if err := file.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), *path); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d bytes to %s\n", n, *path)
+	return nil
+}
+
+// printEntries prints leaderboard rows, marking the row of highlight.
+func printEntries(e *env, entries []entity.LeaderboardEntry, highlight string) error {
+	t := e.table("", "RANK", "USERNAME", "RATING", "USER ID")
+	for _, entry := range entries {
+		t.row(mark(entry.UserID == highlight), entry.Rank, entry.Username, entry.Rating, entry.UserID)
+	}
+	return t.flush()
+}
@@ -0,0 +1,290 @@
+// Command rankqctl operates a RankQ deployment through its HTTP API.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+var errUsage = errors.New("invalid arguments")
+
+// helpError asks for a command's usage together with its flags.
+type helpError struct {
+	flags *flag.FlagSet
+}
+
+func (helpError) Error() string { return "help requested" }
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(ctx context.Context, e *env, args []string) error
+}
+
+// group is a top-level command. It either runs directly or dispatches to
+// its subcommands.
+type group struct {
+	name        string
+	description string
+	commands    []command
+	run         *command
+}
+
+var groups = []group{
+	{name: "users", description: "create, inspect, search and delete users", commands: userCommands},
+	{name: "scores", description: "set scores one at a time or from a file", commands: scoreCommands},
+	{name: "leaderboard", description: "read pages, neighbours, ranks and exports", commands: leaderboardCommands},
+	{name: "rebuild", description: "rebuild the Redis leaderboard from Postgres", run: &rebuildCommand},
+	{name: "reconcile", description: "check and repair Redis against Postgres", commands: reconcileCommands},
+	{name: "simulation", description: "start, stop and watch simulations", commands: simulationCommands},
+	{name: "profile", description: "manage connection profiles", commands: profileCommands},
+}
+
+// env is what every command runs with.
+type env struct {
+	profiles *profiles
+	client   *client
+	json     bool
+	out      io.Writer
+	in       io.Reader
+}
+
+func main() {
+	fs := flag.NewFlagSet("rankqctl", flag.ExitOnError)
+	profileName := fs.String("profile", os.Getenv("RANKQ_PROFILE"), "profile to use (default: the current profile)")
+	baseURL := fs.String("url", os.Getenv("RANKQ_URL"), "base URL of the API, overriding the profile")
+	apiKey := fs.String("api-key", os.Getenv("RANKQ_API_KEY"), "API key, overriding the profile")
+	output := fs.String("o", "table", "output format: table or json")
+	timeout := fs.Duration("timeout", 30*time.Second, "per-request timeout (0 for none)")
+	fs.Usage = func() { usage(fs) }
+	fs.Parse(os.Args[1:])
+
+	if fs.NArg() < 1 {
+		usage(fs)
+		os.Exit(2)
+	}
+	if *output != "table" && *output != "json" {
+		fatalf("-o must be table or json")
+	}
+
+	name := fs.Arg(0)
+	var g *group
+	for i := range groups {
+		if groups[i].name == name {
+			g = &groups[i]
+		}
+	}
+	if g == nil {
+		fmt.Fprintf(os.Stderr, "rankqctl: unknown command %q\n\n", name)
+		usage(fs)
+		os.Exit(2)
+	}
+
+	cmd, args := g.run, fs.Args()[1:]
+	if cmd == nil {
+		if len(args) == 0 {
+			groupUsage(g)
+			os.Exit(2)
+		}
+		for i := range g.commands {
+			if g.commands[i].name == args[0] {
+				cmd = &g.commands[i]
+			}
+		}
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "rankqctl %s: unknown command %q\n\n", g.name, args[0])
+			groupUsage(g)
+			os.Exit(2)
+		}
+		args = args[1:]
+	}
+
+	profs, err := loadProfiles()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	_, prof, err := profs.resolve(*profileName)
+	if err != nil && g.name != "profile" {
+		fatalf("%v", err)
+	}
+	if *baseURL != "" {
+		prof.URL = *baseURL
+	}
+	if *apiKey != "" {
+		prof.APIKey = *apiKey
+	}
+
+	e := &env{
+		profiles: profs,
+		client: &client{
+			http:    &http.Client{Timeout: *timeout},
+			baseURL: strings.TrimRight(prof.URL, "/"),
+			apiKey:  prof.APIKey,
+		},
+		json: *output == "json",
+		out:  os.Stdout,
+		in:   os.Stdin,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd.run(ctx, e, args); err != nil {
+		title := g.name
+		if g.run == nil {
+			title += " " + cmd.name
+		}
+		var help helpError
+		if err == errUsage || errors.As(err, &help) {
+			fmt.Fprintf(os.Stderr, "usage: rankqctl %s %s\n", title, cmd.usage)
+			if help.flags != nil {
+				fmt.Fprintln(os.Stderr)
+				help.flags.SetOutput(os.Stderr)
+				help.flags.PrintDefaults()
+			}
+			os.Exit(2)
+		}
+		fatalf("%s: %v", title, err)
+	}
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "rankqctl: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+func usage(fs *flag.FlagSet) {
+	fmt.Fprintln(os.Stderr, "usage: rankqctl [flags] <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, g := range groups {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", g.name, g.description)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "flags:")
+	fs.PrintDefaults()
+}
+
+func groupUsage(g *group) {
+	fmt.Fprintf(os.Stderr, "usage: rankqctl %s <command> [arguments]\n\n", g.name)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range g.commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %-40s %s\n", cmd.name, cmd.usage, cmd.description)
+	}
+}
+
+// parseFlags parses args with flags allowed before, between and after the
+// positional arguments, which it returns. Everything after "--" is
+// positional, such as a negative number.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		before := args
+		if err := fs.Parse(args); err == flag.ErrHelp {
+			return nil, helpError{fs}
+		} else if err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if n := len(before) - len(args); n > 0 && before[n-1] == "--" {
+			return append(positional, args...), nil
+		}
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func (e *env) printJSON(v any) error {
+	enc := json.NewEncoder(e.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// message prints the outcome of a command without data of its own.
+func (e *env) message(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if e.json {
+		return e.printJSON(map[string]string{"message": msg})
+	}
+	_, err := fmt.Fprintln(e.out, msg)
+	return err
+}
+
+// fields prints name/value pairs, one per line.
+func (e *env) fields(pairs [][2]string) error {
+	tw := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	for _, p := range pairs {
+		fmt.Fprintf(tw, "%s:\t%s\n", p[0], p[1])
+	}
+	return tw.Flush()
+}
+
+type table struct {
+	tw *tabwriter.Writer
+}
+
+func (e *env) table(header ...string) *table {
+	t := &table{tw: tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)}
+	fmt.Fprintln(t.tw, strings.Join(header, "\t"))
+	return t
+}
+
+func (t *table) row(cells ...any) {
+	for i, cell := range cells {
+		if i > 0 {
+			fmt.Fprint(t.tw, "\t")
+		}
+		fmt.Fprint(t.tw, cell)
+	}
+	fmt.Fprintln(t.tw)
+}
+
+func (t *table) flush() error {
+	return t.tw.Flush()
+}
+
+// confirm asks a yes/no question on the terminal.
+func (e *env) confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(e.in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func mark(b bool) string {
+	if b {
+		return "*"
+	}
+	return ""
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
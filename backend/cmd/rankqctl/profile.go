@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	configFileEnv = "RANKQCTL_CONFIG"
+	defaultURL    = "http://localhost:8080"
+)
+
+// profile is one deployment rankqctl can talk to.
+type profile struct {
+	URL    string `yaml:"url"`
+	APIKey string `yaml:"api_key,omitempty"`
+}
+
+// profiles is the rankqctl config file.
+type profiles struct {
+	Current  string             `yaml:"current,omitempty"`
+	Profiles map[string]profile `yaml:"profiles"`
+
+	path string
+}
+
+// profilesPath is $RANKQCTL_CONFIG, or rankq/rankqctl.yaml in the user's
+// config directory.
+func profilesPath() (string, error) {
+	if path := os.Getenv(configFileEnv); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "rankq", "rankqctl.yaml"), nil
+}
+
+// loadProfiles reads the config file. A missing file is an empty one.
+func loadProfiles() (*profiles, error) {
+	path, err := profilesPath()
+	if err != nil {
+		return nil, err
+	}
+	p := &profiles{Profiles: map[string]profile{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Profiles == nil {
+		p.Profiles = map[string]profile{}
+	}
+	return p, nil
+}
+
+// save writes the file readable by the owner only, as it holds API keys.
+func (p *profiles) save() error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(p.path, data, 0o600)
+}
+
+func (p *profiles) names() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve picks the profile to use: name if set, else the current one.
+// Without either it falls back to a local server.
+func (p *profiles) resolve(name string) (string, profile, error) {
+	if name == "" {
+		name = p.Current
+	}
+	if name == "" {
+		return "", profile{URL: defaultURL}, nil
+	}
+	prof, ok := p.Profiles[name]
+	if !ok {
+		return "", profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return name, prof, nil
+}
+
+var profileCommands = []command{
+	{name: "list", usage: "", description: "list profiles", run: profileList},
+	{name: "show", usage: "[name]", description: "show a profile (default: the current one)", run: profileShow},
+	{name: "set", usage: "<name> [-url URL] [-api-key KEY|-]", description: "create or update a profile", run: profileSet},
+	{name: "use", usage: "<name>", description: "make a profile the current one", run: profileUse},
+	{name: "delete", usage: "<name>", description: "delete a profile", run: profileDelete},
+}
+
+func profileList(ctx context.Context, e *env, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	type row struct {
+		Name    string `json:"name"`
+		URL     string `json:"url"`
+		Current bool   `json:"current"`
+		APIKey  bool   `json:"api_key"`
+	}
+	rows := []row{}
+	for _, name := range e.profiles.names() {
+		prof := e.profiles.Profiles[name]
+		rows = append(rows, row{Name: name, URL: prof.URL, Current: name == e.profiles.Current, APIKey: prof.APIKey != ""})
+	}
+	if e.json {
+		return e.printJSON(rows)
+	}
+
+	t := e.table("CURRENT", "NAME", "URL", "API KEY")
+	for _, r := range rows {
+		t.row(mark(r.Current), r.Name, r.URL, yesNo(r.APIKey))
+	}
+	return t.flush()
+}
+
+func profileShow(ctx context.Context, e *env, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	}
+	name, prof, err := e.profiles.resolve(name)
+	if err != nil {
+		return err
+	}
+	if prof.APIKey != "" {
+		prof.APIKey = "********"
+	}
+	if e.json {
+		return e.printJSON(map[string]string{"name": name, "url": prof.URL, "api_key": prof.APIKey})
+	}
+	return e.fields([][2]string{{"name", name}, {"url", prof.URL}, {"api key", prof.APIKey}})
+}
+
+func profileSet(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("profile set", flag.ContinueOnError)
+	url := fs.String("url", "", "base URL of the API")
+	apiKey := fs.String("api-key", "", `API key ("-" removes it)`)
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+	name := args[0]
+
+	prof, exists := e.profiles.Profiles[name]
+	if *url != "" {
+		prof.URL = *url
+	}
+	switch *apiKey {
+	case "":
+	case "-":
+		prof.APIKey = ""
+	default:
+		prof.APIKey = *apiKey
+	}
+	if prof.URL == "" {
+		return fmt.Errorf("a new profile needs -url")
+	}
+	e.profiles.Profiles[name] = prof
+	if e.profiles.Current == "" {
+		e.profiles.Current = name
+	}
+	if err := e.profiles.save(); err != nil {
+		return err
+	}
+
+	if exists {
+		return e.message("profile %s updated", name)
+	}
+	return e.message("profile %s created", name)
+}
+
+func profileUse(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if _, ok := e.profiles.Profiles[args[0]]; !ok {
+		return fmt.Errorf("unknown profile %q", args[0])
+	}
+	e.profiles.Current = args[0]
+	if err := e.profiles.save(); err != nil {
+		return err
+	}
+	return e.message("using profile %s", args[0])
+}
+
+func profileDelete(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if _, ok := e.profiles.Profiles[args[0]]; !ok {
+		return fmt.Errorf("unknown profile %q", args[0])
+	}
+	delete(e.profiles.Profiles, args[0])
+	if e.profiles.Current == args[0] {
+		e.profiles.Current = ""
+	}
+	if err := e.profiles.save(); err != nil {
+		return err
+	}
+	return e.message("profile %s deleted", args[0])
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+var scoreCommands = []command{
+	{name: "set", usage: "<user-id> <rating> [-mode latest|best|sum|cas] [-expected-version N]", description: "write one score", run: scoresSet},
+	{name: "batch", usage: "<file|-> [-format csv|ndjson] [-mode M] [-parallel N]", description: "write scores listed in a file", run: scoresBatch},
+}
+
+// scoreWrite is the body of PUT /leaderboard/user/:id/score.
+type scoreWrite struct {
+	Rating          int    `json:"rating"`
+	Mode            string `json:"mode,omitempty"`
+	ExpectedVersion *int64 `json:"expected_version,omitempty"`
+}
+
+// scoreOutcome is how the server handled one score write.
+type scoreOutcome struct {
+	Outcome   string                   `json:"outcome"`
+	Result    *entity.ScoreWriteResult `json:"result,omitempty"`
+	AnomalyID string                   `json:"anomaly_id,omitempty"`
+	Error     string                   `json:"error,omitempty"`
+}
+
+const (
+	outcomeUpdated   = "updated"
+	outcomeUnchanged = "unchanged"
+	outcomeQueued    = "queued"
+	outcomeRejected  = "rejected"
+	outcomeConflict  = "conflict"
+	outcomeFailed    = "failed"
+)
+
+var outcomes = []string{outcomeUpdated, outcomeUnchanged, outcomeQueued, outcomeRejected, outcomeConflict, outcomeFailed}
+
+// writeScore sends one write. Answers the score path gives on purpose,
+// such as a queued or rejected update, are outcomes rather than errors.
+func writeScore(ctx context.Context, c *client, userID string, w scoreWrite) scoreOutcome {
+	var resp struct {
+		Data      *entity.ScoreWriteResult `json:"data"`
+		AnomalyID string                   `json:"anomaly_id"`
+	}
+	status, err := c.call(ctx, http.MethodPut, "/leaderboard/user/"+userID+"/score", nil, w, &resp)
+
+	var apiErr *apiError
+	switch {
+	case err == nil && status == http.StatusAccepted:
+		return scoreOutcome{Outcome: outcomeQueued, AnomalyID: resp.AnomalyID}
+	case err == nil && resp.Data != nil && !resp.Data.Changed:
+		return scoreOutcome{Outcome: outcomeUnchanged, Result: resp.Data}
+	case err == nil:
+		return scoreOutcome{Outcome: outcomeUpdated, Result: resp.Data}
+	case errors.As(err, &apiErr) && apiErr.Status == http.StatusUnprocessableEntity:
+		return scoreOutcome{Outcome: outcomeRejected, Error: apiErr.Message}
+	case errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict:
+		return scoreOutcome{Outcome: outcomeConflict, Error: apiErr.Message}
+	default:
+		return scoreOutcome{Outcome: outcomeFailed, Error: err.Error()}
+	}
+}
+
+func scoresSet(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("scores set", flag.ContinueOnError)
+	mode := fs.String("mode", "", "write mode: latest (default), best, sum or cas")
+	expected := fs.Int64("expected-version", -1, "version the score must have (cas mode)")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errUsage
+	}
+	id, err := parseUserID(args[0])
+	if err != nil {
+		return err
+	}
+	rating, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("rating %q is not an integer", args[1])
+	}
+
+	w := scoreWrite{Rating: rating, Mode: *mode}
+	if *expected >= 0 {
+		w.ExpectedVersion = expected
+	}
+	out := writeScore(ctx, e.client, id, w)
+	if out.Outcome == outcomeFailed {
+		return errors.New(out.Error)
+	}
+	if e.json {
+		return e.printJSON(out)
+	}
+
+	switch out.Outcome {
+	case outcomeQueued:
+		return e.message("score queued for review as anomaly %s", out.AnomalyID)
+	case outcomeRejected, outcomeConflict:
+		return fmt.Errorf("score %s: %s", out.Outcome, out.Error)
+	}
+	pairs := [][2]string{
+		{"outcome", out.Outcome},
+		{"rating", strconv.Itoa(out.Result.Rating)},
+		{"version", strconv.FormatInt(out.Result.Version, 10)},
+	}
+	if out.Result.PreviousRating != nil {
+		pairs = append(pairs, [2]string{"previous rating", strconv.Itoa(*out.Result.PreviousRating)})
+	}
+	if out.Result.Rank > 0 {
+		pairs = append(pairs, [2]string{"rank", strconv.FormatInt(out.Result.Rank, 10)})
+	}
+	return e.fields(pairs)
+}
+
+// batchRow is one score from a batch file.
+type batchRow struct {
+	line   int
+	userID string
+	rating int
+	err    string
+}
+
+type batchFailure struct {
+	Line    int    `json:"line"`
+	UserID  string `json:"user_id"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error"`
+}
+
+type batchReport struct {
+	Rows     int            `json:"rows"`
+	Outcomes map[string]int `json:"outcomes"`
+	Failures []batchFailure `json:"failures"`
+}
+
+func scoresBatch(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("scores batch", flag.ContinueOnError)
+	format := fs.String("format", "", "file format: csv or ndjson (default: from the file extension, else csv)")
+	mode := fs.String("mode", "", "write mode for every row: latest (default), best or sum")
+	parallel := fs.Int("parallel", 8, "number of writes in flight")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("-parallel must be at least 1")
+	}
+	if *mode == entity.ScoreModeCAS {
+		return fmt.Errorf("cas mode needs a version per write; use scores set")
+	}
+
+	in := e.in
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	if *format == "" {
+		*format = "csv"
+		if ext := strings.ToLower(filepath.Ext(args[0])); ext == ".ndjson" || ext == ".jsonl" {
+			*format = "ndjson"
+		}
+	}
+
+	rows := make(chan batchRow)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(rows)
+		switch *format {
+		case "csv":
+			readErr <- readBatchCSV(in, rows)
+		case "ndjson":
+			readErr <- readBatchNDJSON(in, rows)
+		default:
+			readErr <- fmt.Errorf("-format must be csv or ndjson")
+		}
+	}()
+
+	report := batchReport{Outcomes: map[string]int{}, Failures: []batchFailure{}}
+	var mu sync.Mutex
+	add := func(row batchRow, out scoreOutcome) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Rows++
+		report.Outcomes[out.Outcome]++
+		switch out.Outcome {
+		case outcomeUpdated, outcomeUnchanged, outcomeQueued:
+			return
+		}
+		report.Failures = append(report.Failures, batchFailure{Line: row.line, UserID: row.userID, Outcome: out.Outcome, Error: out.Error})
+	}
+
+	var wg sync.WaitGroup
+	for range *parallel {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range rows {
+				if row.err != "" {
+					add(row, scoreOutcome{Outcome: outcomeFailed, Error: row.err})
+					continue
+				}
+				add(row, writeScore(ctx, e.client, row.userID, scoreWrite{Rating: row.rating, Mode: *mode}))
+			}
+		}()
+	}
+	wg.Wait()
+	if err := <-readErr; err != nil {
+		return err
+	}
+	sort.Slice(report.Failures, func(i, j int) bool { return report.Failures[i].Line < report.Failures[j].Line })
+
+	failed := fmt.Errorf("%d of %d writes did not apply", len(report.Failures), report.Rows)
+	if e.json {
+		if err := e.printJSON(report); err != nil || len(report.Failures) == 0 {
+			return err
+		}
+		return failed
+	}
+	pairs := [][2]string{{"rows", strconv.Itoa(report.Rows)}}
+	for _, o := range outcomes {
+		if n := report.Outcomes[o]; n > 0 {
+			pairs = append(pairs, [2]string{o, strconv.Itoa(n)})
+		}
+	}
+	if err := e.fields(pairs); err != nil {
+		return err
+	}
+	if len(report.Failures) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(e.out)
+	t := e.table("LINE", "USER ID", "OUTCOME", "ERROR")
+	for _, f := range report.Failures {
+		t.row(f.Line, f.UserID, f.Outcome, f.Error)
+	}
+	if err := t.flush(); err != nil {
+		return err
+	}
+	return failed
+}
+
+// readBatchCSV reads user_id,rating rows. A first row whose user_id is not
+// a UUID is taken as a header.
+func readBatchCSV(r io.Reader, rows chan<- batchRow) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows <- batchRow{line: parseErr.Line, err: parseErr.Err.Error()}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) < 2 {
+			rows <- batchRow{line: line, err: "want user_id,rating"}
+			continue
+		}
+		if _, err := uuid.Parse(strings.TrimSpace(record[0])); err != nil && first {
+			continue
+		}
+		rows <- parseBatchRow(line, strings.TrimSpace(record[0]), strings.TrimSpace(record[1]))
+	}
+}
+
+// readBatchNDJSON reads {"user_id": ..., "rating": ...} lines.
+func readBatchNDJSON(r io.Reader, rows chan<- batchRow) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var obj struct {
+			UserID string `json:"user_id"`
+			Rating *int   `json:"rating"`
+		}
+		if err := json.Unmarshal([]byte(text), &obj); err != nil {
+			rows <- batchRow{line: line, err: "invalid JSON: " + err.Error()}
+			continue
+		}
+		if obj.Rating == nil {
+			rows <- batchRow{line: line, userID: obj.UserID, err: "rating is required"}
+			continue
+		}
+		rows <- parseBatchRow(line, obj.UserID, strconv.Itoa(*obj.Rating))
+	}
+	return scanner.Err()
+}
+
+func parseBatchRow(line int, userID, rating string) batchRow {
+	row := batchRow{line: line, userID: userID}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		row.err = fmt.Sprintf("invalid user_id %q", userID)
+		return row
+	}
+	row.userID = id.String()
+	if row.rating, err = strconv.Atoi(rating); err != nil {
+		row.err = fmt.Sprintf("rating %q is not an integer", rating)
+	}
+	return row
+}
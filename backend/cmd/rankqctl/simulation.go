@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+var simulationCommands = []command{
+	{name: "start", usage: "[-scenario S] [-param k=v]... [-interval D] [-updates N] [-seed N] [-record]", description: "start a simulation", run: simulationStart},
+	{name: "stop", usage: "", description: "stop the running simulation", run: simulationStop},
+	{name: "status", usage: "[-watch D]", description: "show the live status", run: simulationStatus},
+	{name: "scenarios", usage: "", description: "list scenarios and their parameters", run: simulationScenarios},
+	{name: "runs", usage: "[-page N] [-size N]", description: "list past runs", run: simulationRuns},
+	{name: "run", usage: "<id>", description: "show one run", run: simulationRun},
+}
+
+// params collects repeated -param name=value flags.
+type params map[string]float64
+
+func (p params) String() string {
+	return fmt.Sprint(map[string]float64(p))
+}
+
+func (p params) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("want name=value")
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s is not a number", value)
+	}
+	p[strings.TrimSpace(name)] = f
+	return nil
+}
+
+func simulationStart(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("simulation start", flag.ContinueOnError)
+	scenario := fs.String("scenario", "", "scenario name (default: the server's default)")
+	interval := fs.Duration("interval", 0, "tick interval (default: the server's default)")
+	updates := fs.Int("updates", 0, "updates per tick (default: the server's default)")
+	seed := fs.Int64("seed", 0, "random seed, for a reproducible run")
+	record := fs.Bool("record", false, "write a record file for replay")
+	scenarioParams := params{}
+	fs.Var(scenarioParams, "param", "scenario parameter as name=value (repeatable)")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	// Fields left out take the server's defaults.
+	body := map[string]any{
+		"scenario": *scenario,
+		"params":   scenarioParams,
+		"record":   *record,
+	}
+	if *interval > 0 {
+		body["interval_ms"] = interval.Milliseconds()
+	}
+	if *updates > 0 {
+		body["updates_per_tick"] = *updates
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			body["seed"] = *seed
+		}
+	})
+
+	var resp struct {
+		Run *entity.SimulationRun `json:"run"`
+	}
+	if _, err := e.client.call(ctx, http.MethodPost, "/simulation/start", nil, body, &resp); err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(resp.Run)
+	}
+	return printRun(e, resp.Run)
+}
+
+func simulationStop(ctx context.Context, e *env, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	var resp struct {
+		Message string `json:"message"`
+	}
+	if _, err := e.client.call(ctx, http.MethodPost, "/simulation/stop", nil, nil, &resp); err != nil {
+		return err
+	}
+	return e.message("%s", resp.Message)
+}
+
+func simulationStatus(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("simulation status", flag.ContinueOnError)
+	watch := fs.Duration("watch", 0, "refresh the status at this interval until interrupted")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	for {
+		var status entity.SimulationStatus
+		if _, err := e.client.call(ctx, http.MethodGet, "/simulation/status", nil, nil, &status); err != nil {
+			return err
+		}
+		if e.json {
+			err = e.printJSON(status)
+		} else {
+			err = printStatus(e, status)
+		}
+		if err != nil || *watch <= 0 {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*watch):
+		}
+		if !e.json {
+			fmt.Fprintln(e.out)
+		}
+	}
+}
+
+func simulationScenarios(ctx context.Context, e *env, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	var resp struct {
+		Data []struct {
+			Name        string             `json:"name"`
+			Description string             `json:"description"`
+			Defaults    map[string]float64 `json:"defaults"`
+		} `json:"data"`
+	}
+	if _, err := e.client.call(ctx, http.MethodGet, "/simulation/scenarios", nil, nil, &resp); err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(resp.Data)
+	}
+
+	t := e.table("NAME", "PARAMETERS", "DESCRIPTION")
+	for _, s := range resp.Data {
+		t.row(s.Name, formatParams(s.Defaults), s.Description)
+	}
+	return t.flush()
+}
+
+func simulationRuns(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("simulation runs", flag.ContinueOnError)
+	page := fs.Int("page", 1, "page number")
+	size := fs.Int("size", 20, "page size (at most 100)")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	var resp struct {
+		Data []entity.SimulationRun `json:"data"`
+		Meta pageMeta               `json:"meta"`
+	}
+	query := url.Values{"page": {strconv.Itoa(*page)}, "page_size": {strconv.Itoa(*size)}}
+	if _, err := e.client.call(ctx, http.MethodGet, "/simulation/runs", query, nil, &resp); err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(resp)
+	}
+
+	t := e.table("ID", "SCENARIO", "SEED", "STARTED", "STOPPED", "TICKS", "UPDATES", "ERRORS")
+	for _, r := range resp.Data {
+		stopped := "running"
+		if r.StoppedAt != nil {
+			stopped = formatTime(*r.StoppedAt)
+		}
+		t.row(r.ID, r.Scenario, r.Seed, formatTime(r.StartedAt), stopped, r.Ticks, r.Updates, r.Errors)
+	}
+	if err := t.flush(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.out, "\npage %d of %d, %d runs\n", resp.Meta.Page, resp.Meta.TotalPages, resp.Meta.Total)
+	return err
+}
+
+func simulationRun(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		return fmt.Errorf("invalid run id %q", args[0])
+	}
+
+	var resp struct {
+		Data entity.SimulationRun `json:"data"`
+	}
+	if _, err := e.client.call(ctx, http.MethodGet, "/simulation/runs/"+args[0], nil, nil, &resp); err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(resp.Data)
+	}
+	return printRun(e, &resp.Data)
+}
+
+func runFields(r *entity.SimulationRun) [][2]string {
+	stopped := "running"
+	if r.StoppedAt != nil {
+		stopped = formatTime(*r.StoppedAt)
+	}
+	pairs := [][2]string{
+		{"run", strconv.FormatInt(r.ID, 10)},
+		{"scenario", r.Scenario},
+		{"params", formatParams(r.Params)},
+		{"interval", (time.Duration(r.IntervalMs) * time.Millisecond).String()},
+		{"updates per tick", strconv.Itoa(r.UpdatesPerTick)},
+		{"seed", strconv.FormatInt(r.Seed, 10)},
+		{"started", formatTime(r.StartedAt)},
+		{"stopped", stopped},
+		{"ticks", strconv.FormatInt(r.Ticks, 10)},
+		{"updates", strconv.FormatInt(r.Updates, 10)},
+		{"errors", strconv.FormatInt(r.Errors, 10)},
+	}
+	if r.RecordFile != "" {
+		pairs = append(pairs, [2]string{"record file", r.RecordFile})
+	}
+	if r.LastError != "" {
+		pairs = append(pairs, [2]string{"last error", r.LastError})
+	}
+	return pairs
+}
+
+func printRun(e *env, r *entity.SimulationRun) error {
+	if r == nil {
+		return e.message("no run")
+	}
+	return e.fields(runFields(r))
+}
+
+func printStatus(e *env, s entity.SimulationStatus) error {
+	pairs := [][2]string{{"running", yesNo(s.Running)}}
+	if s.Instance != "" {
+		pairs = append(pairs, [2]string{"instance", s.Instance})
+	}
+	if s.Run == nil {
+		pairs = append(pairs, [2]string{"run", "none yet"})
+		return e.fields(pairs)
+	}
+	pairs = append(pairs, runFields(s.Run)...)
+	pairs = append(pairs, [2]string{"updates/s", strconv.FormatFloat(s.UpdatesPerSecond, 'f', 1, 64)})
+	if s.Churn != nil {
+		pairs = append(pairs, [2]string{"churn", fmt.Sprintf("top %d: %d changed last tick, %d entered, %d in total",
+			s.Churn.TopN, s.Churn.LastTick, s.Churn.Entered, s.Churn.Total)})
+	}
+	if len(s.Stats) > 0 {
+		pairs = append(pairs, [2]string{"stats", formatParams(s.Stats)})
+	}
+	return e.fields(pairs)
+}
+
+func formatParams(p map[string]float64) string {
+	if len(p) == 0 {
+		return "-"
+	}
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.FormatFloat(p[name], 'g', -1, 64)
+	}
+	return strings.Join(parts, " ")
+}
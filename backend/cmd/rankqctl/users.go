@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+var userCommands = []command{
+	{name: "create", usage: "<username> [-rating N]", description: "create a user", run: usersCreate},
+	{name: "get", usage: "<user-id>", description: "show a user with its rating and rank", run: usersGet},
+	{name: "search", usage: "<query> [-limit N]", description: "search users by username", run: usersSearch},
+	{name: "delete", usage: "<user-id> [-y]", description: "delete a user and its scores", run: usersDelete},
+}
+
+func usersCreate(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("users create", flag.ContinueOnError)
+	rating := fs.Int("rating", 0, "initial rating (default: the server's default)")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	var resp struct {
+		Data entity.User `json:"data"`
+	}
+	body := map[string]any{"username": args[0], "initial_rating": *rating}
+	if _, err := e.client.call(ctx, http.MethodPost, "/users", nil, body, &resp); err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(resp.Data)
+	}
+	return e.fields([][2]string{
+		{"id", resp.Data.ID.String()},
+		{"username", resp.Data.Username},
+		{"created", formatTime(resp.Data.CreatedAt)},
+	})
+}
+
+func usersGet(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := parseUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	var user struct {
+		Data entity.User `json:"data"`
+	}
+	if _, err := e.client.call(ctx, http.MethodGet, "/users/"+id, nil, nil, &user); err != nil {
+		return err
+	}
+	// A user missing from the leaderboard has no rank; show it anyway.
+	var rank struct {
+		Data *entity.SearchResult `json:"data"`
+	}
+	if _, err := e.client.call(ctx, http.MethodGet, "/leaderboard/user/"+id, nil, nil, &rank); err != nil && !isNotFound(err) {
+		return err
+	}
+
+	if e.json {
+		return e.printJSON(map[string]any{"user": user.Data, "rank": rank.Data})
+	}
+	pairs := [][2]string{
+		{"id", user.Data.ID.String()},
+		{"username", user.Data.Username},
+		{"created", formatTime(user.Data.CreatedAt)},
+	}
+	if rank.Data != nil {
+		pairs = append(pairs,
+			[2]string{"rating", strconv.Itoa(rank.Data.Rating)},
+			[2]string{"rank", strconv.FormatInt(rank.Data.Rank, 10)},
+		)
+	} else {
+		pairs = append(pairs, [2]string{"rank", "not on the leaderboard"})
+	}
+	return e.fields(pairs)
+}
+
+func usersSearch(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("users search", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "maximum number of results (at most 100)")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	var resp struct {
+		Data []entity.SearchResult `json:"data"`
+	}
+	query := url.Values{"q": {args[0]}, "limit": {strconv.Itoa(*limit)}}
+	if _, err := e.client.call(ctx, http.MethodGet, "/leaderboard/search", query, nil, &resp); err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(resp.Data)
+	}
+
+	t := e.table("RANK", "USERNAME", "RATING", "USER ID")
+	for _, r := range resp.Data {
+		t.row(r.Rank, r.Username, r.Rating, r.UserID)
+	}
+	return t.flush()
+}
+
+func usersDelete(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("users delete", flag.ContinueOnError)
+	yes := fs.Bool("y", false, "do not ask for confirmation")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := parseUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	if !*yes {
+		var user struct {
+			Data entity.User `json:"data"`
+		}
+		if _, err := e.client.call(ctx, http.MethodGet, "/users/"+id, nil, nil, &user); err != nil {
+			return err
+		}
+		if !e.confirm(fmt.Sprintf("Delete user %s (%s) with its scores and history?", user.Data.Username, id)) {
+			return errors.New("aborted")
+		}
+	}
+
+	if _, err := e.client.call(ctx, http.MethodDelete, "/users/"+id, nil, nil, nil); err != nil {
+		return err
+	}
+	return e.message("user %s deleted", id)
+}
+
+func parseUserID(s string) (string, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid user id %q", s)
+	}
+	return id.String(), nil
+}
+
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
 	"github.com/rankq/backend/internal/infrastructure/cache"
 	"github.com/rankq/backend/internal/infrastructure/database"
 	"github.com/rankq/backend/pkg/config"
@@ -51,9 +52,14 @@ func main() {
 
 	userRepo := database.NewUserRepository(db)
 	scoreRepo := database.NewScoreRepository(db)
-	leaderboardRepo := cache.NewLeaderboardRepository(redisClient)
+	teamAggregate := entity.TeamAggregate{Mode: cfg.Teams.Aggregate, K: cfg.Teams.TopK}
+	leaderboardRepo := cache.NewLeaderboardRepository(redisClient, teamAggregate, int64(cfg.Events.MaxLen))
+	teamRepo := database.NewTeamRepository(db)
+	teamBoardRepo := cache.NewTeamLeaderboardRepository(redisClient, teamAggregate)
+	usernameIndex := cache.NewUsernameIndex(redisClient)
 
-	userService := service.NewUserService(userRepo, scoreRepo, leaderboardRepo)
+	ratings := entity.RatingRange{Min: cfg.Rating.Min, Max: cfg.Rating.Max, Default: cfg.Rating.Default}
+	userService := service.NewUserService(userRepo, scoreRepo, leaderboardRepo, teamRepo, teamBoardRepo, usernameIndex, ratings)
 
 	ctx := context.Background()
 
@@ -61,7 +67,7 @@ func main() {
 
 	for i := 0; i < *numUsers; i++ {
 		username := generateUsername(i)
-		rating := 100 + rand.Intn(4901)
+		rating := ratings.Min + rand.Intn(ratings.Max-ratings.Min+1)
 
 		_, err := userService.CreateUser(ctx, username, rating)
 		if err != nil {
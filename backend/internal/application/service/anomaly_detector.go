@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+// ScoreUpdate is a proposed rating change as seen by anomaly rules. OldRating
+// is nil for a user with no rating yet.
+type ScoreUpdate struct {
+	UserID    uuid.UUID
+	OldRating *int
+	NewRating int
+}
+
+// AnomalyRule inspects a score update before it is written. Evaluate returns
+// a human-readable detail when the rule matches.
+type AnomalyRule interface {
+	Name() string
+	Action() string
+	Evaluate(ctx context.Context, update ScoreUpdate) (string, bool, error)
+}
+
+// AnomalyDetector evaluates every rule against an update; the strictest
+// action among the matching rules decides what happens to it.
+type AnomalyDetector struct {
+	rules []AnomalyRule
+}
+
+func NewAnomalyDetector(rules ...AnomalyRule) *AnomalyDetector {
+	return &AnomalyDetector{rules: rules}
+}
+
+func (d *AnomalyDetector) Evaluate(ctx context.Context, update ScoreUpdate) (*entity.AnomalyVerdict, error) {
+	verdict := &entity.AnomalyVerdict{}
+	for _, rule := range d.rules {
+		detail, matched, err := rule.Evaluate(ctx, update)
+		if err != nil {
+			return nil, fmt.Errorf("anomaly rule %s: %w", rule.Name(), err)
+		}
+		if !matched {
+			continue
+		}
+		verdict.Matches = append(verdict.Matches, entity.AnomalyMatch{
+			Rule:   rule.Name(),
+			Action: rule.Action(),
+			Detail: detail,
+		})
+		if entity.AnomalySeverity(rule.Action()) > entity.AnomalySeverity(verdict.Action) {
+			verdict.Action = rule.Action()
+		}
+	}
+	return verdict, nil
+}
+
+type maxDeltaRule struct {
+	max    int
+	action string
+}
+
+// NewMaxDeltaRule matches updates that move a rating by more than max points.
+func NewMaxDeltaRule(max int, action string) AnomalyRule {
+	return &maxDeltaRule{max: max, action: action}
+}
+
+func (r *maxDeltaRule) Name() string   { return "max_delta" }
+func (r *maxDeltaRule) Action() string { return r.action }
+
+func (r *maxDeltaRule) Evaluate(ctx context.Context, update ScoreUpdate) (string, bool, error) {
+	if update.OldRating == nil {
+		return "", false, nil
+	}
+	delta := update.NewRating - *update.OldRating
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta <= r.max {
+		return "", false, nil
+	}
+	return fmt.Sprintf("rating changed by %d, limit is %d", delta, r.max), true, nil
+}
+
+type updateRateRule struct {
+	counter repository.RateCounter
+	max     int64
+	window  time.Duration
+	action  string
+}
+
+// NewUpdateRateRule matches once a user has had more than max updates
+// attempted within window. Every evaluated update counts, including ones
+// that end up rejected.
+func NewUpdateRateRule(counter repository.RateCounter, max int, window time.Duration, action string) AnomalyRule {
+	return &updateRateRule{counter: counter, max: int64(max), window: window, action: action}
+}
+
+func (r *updateRateRule) Name() string   { return "update_rate" }
+func (r *updateRateRule) Action() string { return r.action }
+
+func (r *updateRateRule) Evaluate(ctx context.Context, update ScoreUpdate) (string, bool, error) {
+	n, err := r.counter.Hit(ctx, "score_updates:"+update.UserID.String(), r.window)
+	if err != nil {
+		return "", false, err
+	}
+	if n <= r.max {
+		return "", false, nil
+	}
+	return fmt.Sprintf("%d updates within %v, limit is %d", n, r.window, r.max), true, nil
+}
+
+// minZScoreStdDev keeps users with a near-constant history from tripping the
+// z-score rule on every small change.
+const minZScoreStdDev = 25.0
+
+type zScoreRule struct {
+	historyRepo repository.ScoreHistoryRepository
+	threshold   float64
+	window      int
+	minSamples  int
+	action      string
+}
+
+// NewZScoreRule matches updates whose new rating lies more than threshold
+// standard deviations from the mean of the user's last window ratings. Users
+// with fewer than minSamples history entries are not evaluated.
+func NewZScoreRule(historyRepo repository.ScoreHistoryRepository, threshold float64, window, minSamples int, action string) AnomalyRule {
+	return &zScoreRule{
+		historyRepo: historyRepo,
+		threshold:   threshold,
+		window:      window,
+		minSamples:  minSamples,
+		action:      action,
+	}
+}
+
+func (r *zScoreRule) Name() string   { return "zscore" }
+func (r *zScoreRule) Action() string { return r.action }
+
+func (r *zScoreRule) Evaluate(ctx context.Context, update ScoreUpdate) (string, bool, error) {
+	history, err := r.historyRepo.ListByUser(ctx, update.UserID, r.window)
+	if err != nil {
+		return "", false, err
+	}
+	if len(history) < r.minSamples {
+		return "", false, nil
+	}
+
+	var sum float64
+	for _, change := range history {
+		sum += float64(change.NewRating)
+	}
+	mean := sum / float64(len(history))
+
+	var variance float64
+	for _, change := range history {
+		d := float64(change.NewRating) - mean
+		variance += d * d
+	}
+	stdDev := math.Max(math.Sqrt(variance/float64(len(history))), minZScoreStdDev)
+
+	z := math.Abs(float64(update.NewRating)-mean) / stdDev
+	if z <= r.threshold {
+		return "", false, nil
+	}
+	return fmt.Sprintf("z-score %.2f against the last %d ratings (mean %.0f, stddev %.0f), limit is %.2f",
+		z, len(history), mean, stdDev, r.threshold), true, nil
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+const decayJob = "decay"
+
+var ErrDecayInProgress = errors.New("decay run already in progress")
+
+type DecayOptions struct {
+	// BatchSize is the number of inactive users read per query.
+	BatchSize int
+	// MaxReported caps the changes kept in a report; totals are always exact.
+	MaxReported int
+}
+
+// DecayService lowers the ratings of inactive users according to a
+// DecayPolicy. Each decay is a versioned LeaderboardRepository.WriteScore like
+// a client write, so rating counts and team scores stay correct and a
+// concurrent write is never overwritten, and is written to the score history.
+type DecayService struct {
+	scoreRepo       repository.ScoreRepository
+	leaderboardRepo repository.LeaderboardRepository
+	historyRepo     repository.ScoreHistoryRepository
+	ratings         entity.RatingRange
+	policy          entity.DecayPolicy
+	opts            DecayOptions
+
+	mu         sync.Mutex
+	inProgress bool
+	scheduled  bool
+	stopCh     chan struct{}
+	cancel     context.CancelFunc
+
+	cluster JobCluster
+}
+
+func NewDecayService(
+	scoreRepo repository.ScoreRepository,
+	leaderboardRepo repository.LeaderboardRepository,
+	historyRepo repository.ScoreHistoryRepository,
+	ratings entity.RatingRange,
+	policy entity.DecayPolicy,
+	opts DecayOptions,
+	cluster JobCluster,
+) *DecayService {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1000
+	}
+	if opts.MaxReported < 1 {
+		opts.MaxReported = 100
+	}
+	return &DecayService{
+		scoreRepo:       scoreRepo,
+		leaderboardRepo: leaderboardRepo,
+		historyRepo:     historyRepo,
+		ratings:         ratings,
+		policy:          policy,
+		opts:            opts,
+		cluster:         cluster,
+	}
+}
+
+// Status reports whether a run is in progress on any instance and the most
+// recent report.
+func (s *DecayService) Status(ctx context.Context) (bool, *entity.DecayReport, error) {
+	running, err := s.cluster.running(ctx, decayJob)
+	if err != nil {
+		return false, nil, err
+	}
+	report, err := s.cluster.State.GetDecayReport(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	return running, report, nil
+}
+
+// Trigger starts a run in the background.
+func (s *DecayService) Trigger(dryRun bool) error {
+	ctx, end, err := s.begin(context.Background())
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer end()
+		if _, err := s.run(ctx, dryRun); err != nil {
+			log.Printf("decay: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Run performs a decay pass and waits for it to finish. A dry run only
+// reports who would decay and by how much.
+func (s *DecayService) Run(ctx context.Context, dryRun bool) (*entity.DecayReport, error) {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return s.run(ctx, dryRun)
+}
+
+// StartSchedule runs a decay pass every interval until Stop is called.
+func (s *DecayService) StartSchedule(interval time.Duration, dryRun bool) {
+	s.mu.Lock()
+	if s.scheduled {
+		s.mu.Unlock()
+		return
+	}
+	s.scheduled = true
+	s.stopCh = make(chan struct{})
+	stopCh := s.stopCh
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	log.Printf("decay: scheduled every %v (dry_run=%v)", interval, dryRun)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				report, err := s.Run(ctx, dryRun)
+				if err != nil {
+					log.Printf("decay: %v", err)
+					continue
+				}
+				if report.Decayed > 0 {
+					log.Printf("decay: %d users decayed (dry_run=%v)", report.Decayed, dryRun)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the schedule and cancels a scheduled run in progress.
+func (s *DecayService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.scheduled {
+		return
+	}
+	close(s.stopCh)
+	s.cancel()
+	s.scheduled = false
+}
+
+// begin claims the job locally and across the cluster. The returned context
+// is cancelled if the cluster lease is lost, and end must be called when the
+// run finishes.
+func (s *DecayService) begin(ctx context.Context) (context.Context, func(), error) {
+	s.mu.Lock()
+	if s.inProgress {
+		s.mu.Unlock()
+		return nil, nil, ErrDecayInProgress
+	}
+	s.inProgress = true
+	s.mu.Unlock()
+
+	runCtx, release, err := s.cluster.hold(ctx, decayJob)
+	if err != nil {
+		s.end()
+		if errors.Is(err, errJobLeaseHeld) {
+			return nil, nil, ErrDecayInProgress
+		}
+		return nil, nil, err
+	}
+	return runCtx, func() {
+		release()
+		s.end()
+	}, nil
+}
+
+func (s *DecayService) end() {
+	s.mu.Lock()
+	s.inProgress = false
+	s.mu.Unlock()
+}
+
+func (s *DecayService) run(ctx context.Context, dryRun bool) (*entity.DecayReport, error) {
+	now := time.Now()
+	report := &entity.DecayReport{
+		StartedAt: now,
+		DryRun:    dryRun,
+		Changes:   []entity.DecayChange{},
+	}
+
+	activeBefore := now.Add(-s.policy.InactiveAfter)
+	err := s.scoreRepo.StreamInactive(ctx, activeBefore, s.policy.Floor, s.opts.BatchSize, func(batch []*entity.InactiveScore) error {
+		report.Scanned += int64(len(batch))
+
+		for _, score := range batch {
+			days, decayedAt := s.policy.Due(score, now)
+			newRating := s.policy.Apply(score.Rating, days)
+			if newRating == score.Rating {
+				continue
+			}
+
+			change := entity.DecayChange{
+				UserID:        score.UserID,
+				OldRating:     score.Rating,
+				NewRating:     newRating,
+				Days:          days,
+				InactiveSince: score.ActiveAt,
+			}
+
+			if !dryRun {
+				applied, err := s.apply(ctx, score, newRating, decayedAt, now)
+				if err != nil {
+					return err
+				}
+				if !applied {
+					report.Skipped++
+					continue
+				}
+				change.Applied = true
+			}
+
+			report.Decayed++
+			if len(report.Changes) < s.opts.MaxReported {
+				report.Changes = append(report.Changes, change)
+			} else {
+				report.Truncated = true
+			}
+		}
+
+		return nil
+	})
+
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	// Saved even if ctx was cancelled, so the partial report is visible.
+	if saveErr := s.cluster.State.SaveDecayReport(context.Background(), report); saveErr != nil {
+		log.Printf("decay: failed to save report: %v", saveErr)
+	}
+
+	return report, err
+}
+
+// apply writes one decay as a compare-and-set on the score version read
+// from Postgres. Redis goes first, so a client write that has reached Redis
+// but not yet Postgres makes the decay conflict and the user is skipped.
+// Postgres is then only updated if the row is still the one that was read;
+// if it is not, Redis is put back to the stored rating unless a newer write
+// has landed there meanwhile.
+func (s *DecayService) apply(ctx context.Context, score *entity.InactiveScore, newRating int, decayedAt, now time.Time) (bool, error) {
+	result, err := s.write(ctx, score.UserID, newRating, score.Version)
+	if err != nil {
+		return false, err
+	}
+	if result.Conflict {
+		return false, nil
+	}
+
+	applied, err := s.scoreRepo.ApplyDecay(ctx, score, newRating, result.Version, decayedAt, now)
+	if err != nil {
+		return false, err
+	}
+	if !applied {
+		current, err := s.scoreRepo.GetByUserID(ctx, score.UserID)
+		if err != nil {
+			return false, err
+		}
+		if current != nil {
+			if _, err := s.write(ctx, score.UserID, current.Rating, result.Version); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
+	oldRating := score.Rating
+	change := entity.NewScoreChange(score.UserID, &oldRating, newRating, entity.ScoreChangeDecay)
+	if err := s.historyRepo.Record(ctx, change); err != nil {
+		log.Printf("decay: failed to record score history for %s: %v", score.UserID, err)
+	}
+
+	return true, nil
+}
+
+// write sets rating if the user's score version is still expected. A
+// version Redis does not track is seeded with expected, which was read
+// from Postgres.
+func (s *DecayService) write(ctx context.Context, userID uuid.UUID, rating int, expected int64) (*entity.ScoreWriteResult, error) {
+	write := entity.ScoreWrite{Mode: entity.ScoreModeCAS, Value: rating, ExpectedVersion: expected}
+	result, err := s.leaderboardRepo.WriteScore(ctx, userID, write, s.ratings, entity.ScoreChangeDecay)
+	if err != repository.ErrScoreVersionUnknown {
+		return result, err
+	}
+	if err := s.leaderboardRepo.SeedScoreVersion(ctx, userID, expected); err != nil {
+		return nil, err
+	}
+	return s.leaderboardRepo.WriteScore(ctx, userID, write, s.ratings, entity.ScoreChangeDecay)
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/rankq/backend/pkg/events"
+)
+
+var ErrInvalidOffset = errors.New(`offset must be a stream entry ID, "0" or "$"`)
+
+var streamIDPattern = regexp.MustCompile(`^\d+(-\d+)?$`)
+
+type EventOptions struct {
+	ClaimIdle time.Duration
+	MaxBlock  time.Duration
+}
+
+// EventService serves the score event stream to HTTP consumers, either
+// directly from an offset or through a consumer group.
+type EventService struct {
+	stream repository.EventStream
+	opts   EventOptions
+}
+
+func NewEventService(stream repository.EventStream, opts EventOptions) *EventService {
+	if opts.ClaimIdle <= 0 {
+		opts.ClaimIdle = time.Minute
+	}
+	if opts.MaxBlock <= 0 {
+		opts.MaxBlock = 30 * time.Second
+	}
+	return &EventService{stream: stream, opts: opts}
+}
+
+func (s *EventService) MaxBlock() time.Duration {
+	return s.opts.MaxBlock
+}
+
+// Read returns events after the given entry ID, and the offset to continue
+// from. "$" starts at the current end of the stream.
+func (s *EventService) Read(ctx context.Context, after string, count int64, block time.Duration) ([]events.Event, string, error) {
+	if !validOffset(after) {
+		return nil, "", ErrInvalidOffset
+	}
+	if after == "$" {
+		last, err := s.stream.LastID(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		after = last
+	}
+
+	evs, err := s.stream.Read(ctx, after, count, s.capBlock(block))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(evs) > 0 {
+		after = evs[len(evs)-1].ID
+	}
+	return evs, after, nil
+}
+
+// ReadGroup hands events to consumer within group. A group that does not
+// exist yet is created at start. Events stay pending until acknowledged and
+// are handed out again after the claim timeout.
+func (s *EventService) ReadGroup(ctx context.Context, group, consumer, start string, count int64, block time.Duration) ([]events.Event, error) {
+	if !validOffset(start) {
+		return nil, ErrInvalidOffset
+	}
+	return s.stream.ReadGroup(ctx, group, consumer, start, count, s.capBlock(block), s.opts.ClaimIdle)
+}
+
+func (s *EventService) Ack(ctx context.Context, group string, ids []string) (int64, error) {
+	for _, id := range ids {
+		if !streamIDPattern.MatchString(id) {
+			return 0, ErrInvalidOffset
+		}
+	}
+	return s.stream.Ack(ctx, group, ids)
+}
+
+// SetGroupOffset rewinds or advances group to offset, e.g. "0" to replay the
+// whole retained stream.
+func (s *EventService) SetGroupOffset(ctx context.Context, group, offset string) error {
+	if !validOffset(offset) {
+		return ErrInvalidOffset
+	}
+	return s.stream.SetGroupOffset(ctx, group, offset)
+}
+
+func (s *EventService) capBlock(block time.Duration) time.Duration {
+	if block > s.opts.MaxBlock {
+		return s.opts.MaxBlock
+	}
+	return block
+}
+
+func validOffset(offset string) bool {
+	return offset == "$" || streamIDPattern.MatchString(offset)
+}
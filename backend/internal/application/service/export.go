@@ -0,0 +1,108 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportJSON   = "json"
+)
+
+var ErrInvalidExportFormat = errors.New("format must be csv, ndjson or json")
+
+// ExportWriter encodes export entries as they arrive, so an export never
+// has to be held in memory. Close must be called to finish the document.
+type ExportWriter struct {
+	format string
+	w      io.Writer
+	csv    *csv.Writer
+	enc    *json.Encoder
+	count  int64
+}
+
+// NewExportWriter starts an export in format. generatedAt is recorded in
+// the JSON document and ignored by the line-based formats.
+func NewExportWriter(w io.Writer, format string, generatedAt time.Time) (*ExportWriter, error) {
+	ew := &ExportWriter{format: format, w: w}
+
+	switch format {
+	case ExportCSV:
+		ew.csv = csv.NewWriter(w)
+		if err := ew.csv.Write([]string{"rank", "username", "user_id", "rating", "updated_at"}); err != nil {
+			return nil, err
+		}
+	case ExportNDJSON:
+		ew.enc = json.NewEncoder(w)
+	case ExportJSON:
+		ew.enc = json.NewEncoder(w)
+		at, _ := json.Marshal(generatedAt.UTC())
+		if _, err := io.WriteString(w, `{"generated_at":`+string(at)+`,"data":[`); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidExportFormat
+	}
+
+	return ew, nil
+}
+
+func (ew *ExportWriter) Write(entries []entity.ExportEntry) error {
+	for _, e := range entries {
+		var err error
+		switch ew.format {
+		case ExportCSV:
+			err = ew.csv.Write([]string{
+				strconv.FormatInt(e.Rank, 10),
+				e.Username,
+				e.UserID,
+				strconv.Itoa(e.Rating),
+				e.UpdatedAt.UTC().Format(time.RFC3339),
+			})
+		case ExportNDJSON:
+			err = ew.enc.Encode(e)
+		case ExportJSON:
+			if ew.count > 0 {
+				_, err = io.WriteString(ew.w, ",")
+			}
+			if err == nil {
+				err = ew.enc.Encode(e)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		ew.count++
+	}
+
+	if ew.csv != nil {
+		ew.csv.Flush()
+		return ew.csv.Error()
+	}
+	return nil
+}
+
+// Count returns the number of entries written so far.
+func (ew *ExportWriter) Count() int64 {
+	return ew.count
+}
+
+func (ew *ExportWriter) Close() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		return ew.csv.Error()
+	}
+	if ew.format == ExportJSON {
+		_, err := io.WriteString(ew.w, `],"count":`+strconv.FormatInt(ew.count, 10)+"}\n")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+var (
+	ErrSelfFriendship        = errors.New("users cannot befriend themselves")
+	ErrFriendshipExists      = errors.New("friendship or request already exists")
+	ErrFriendRequestNotFound = errors.New("friend request not found")
+	ErrFriendshipNotFound    = errors.New("friendship not found")
+)
+
+type FriendService struct {
+	userRepo        repository.UserRepository
+	friendshipRepo  repository.FriendshipRepository
+	leaderboardRepo repository.LeaderboardRepository
+}
+
+func NewFriendService(
+	userRepo repository.UserRepository,
+	friendshipRepo repository.FriendshipRepository,
+	leaderboardRepo repository.LeaderboardRepository,
+) *FriendService {
+	return &FriendService{
+		userRepo:        userRepo,
+		friendshipRepo:  friendshipRepo,
+		leaderboardRepo: leaderboardRepo,
+	}
+}
+
+// SendRequest asks addresseeID to become requesterID's friend. If the
+// addressee had already asked the requester, the pending request is accepted
+// instead.
+func (s *FriendService) SendRequest(ctx context.Context, requesterID, addresseeID uuid.UUID) (*entity.Friendship, error) {
+	if requesterID == addresseeID {
+		return nil, ErrSelfFriendship
+	}
+
+	users, err := s.userRepo.GetByIDs(ctx, []uuid.UUID{requesterID, addresseeID})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) != 2 {
+		return nil, ErrUserNotFound
+	}
+
+	existing, err := s.friendshipRepo.GetBetween(ctx, requesterID, addresseeID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.Status == entity.FriendshipPending && existing.RequesterID == addresseeID {
+			return s.AcceptRequest(ctx, requesterID, addresseeID)
+		}
+		return nil, ErrFriendshipExists
+	}
+
+	friendship := entity.NewFriendRequest(requesterID, addresseeID)
+	created, err := s.friendshipRepo.Create(ctx, friendship)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// A concurrent request for the same pair won.
+		return nil, ErrFriendshipExists
+	}
+
+	return friendship, nil
+}
+
+// AcceptRequest accepts the pending request requesterID sent to userID.
+func (s *FriendService) AcceptRequest(ctx context.Context, userID, requesterID uuid.UUID) (*entity.Friendship, error) {
+	now := time.Now()
+	accepted, err := s.friendshipRepo.Accept(ctx, requesterID, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrFriendRequestNotFound
+	}
+
+	return s.friendshipRepo.GetBetween(ctx, userID, requesterID)
+}
+
+// RemoveFriendship unfriends two users, or declines/cancels a pending request
+// between them.
+func (s *FriendService) RemoveFriendship(ctx context.Context, userID, otherID uuid.UUID) error {
+	deleted, err := s.friendshipRepo.Delete(ctx, userID, otherID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrFriendshipNotFound
+	}
+	return nil
+}
+
+func (s *FriendService) ListIncomingRequests(ctx context.Context, userID uuid.UUID) ([]*entity.Friendship, error) {
+	requests, err := s.friendshipRepo.ListIncomingRequests(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []*entity.Friendship{}
+	}
+	return requests, nil
+}
+
+// GetFriendsLeaderboard ranks userID and their accepted friends by rating.
+// Ratings and global ranks come from one script call, so they are
+// consistent with each other and the cost stays flat for users with hundreds
+// of friends.
+func (s *FriendService) GetFriendsLeaderboard(ctx context.Context, userID uuid.UUID) ([]entity.FriendLeaderboardEntry, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	friends, err := s.friendshipRepo.ListFriends(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := append(friends, user)
+	userIDs := make([]uuid.UUID, len(members))
+	for i, m := range members {
+		userIDs[i] = m.ID
+	}
+
+	ranked, err := s.leaderboardRepo.GetUserRanks(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entity.FriendLeaderboardEntry, 0, len(members))
+	for _, m := range members {
+		member, ok := ranked[m.ID]
+		if !ok {
+			continue
+		}
+		entries = append(entries, entity.FriendLeaderboardEntry{
+			GlobalRank: member.Rank,
+			Username:   m.Username,
+			Rating:     member.Rating,
+			UserID:     m.ID.String(),
+			IsSelf:     m.ID == userID,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Rating != entries[j].Rating {
+			return entries[i].Rating > entries[j].Rating
+		}
+		return entries[i].Username < entries[j].Username
+	})
+
+	// Tie-aware like the global board: equal ratings share a rank.
+	for i := range entries {
+		if i > 0 && entries[i].Rating == entries[i-1].Rating {
+			entries[i].FriendRank = entries[i-1].FriendRank
+		} else {
+			entries[i].FriendRank = int64(i + 1)
+		}
+	}
+
+	return entries, nil
+}
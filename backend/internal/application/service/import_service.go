@@ -0,0 +1,294 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+var (
+	ErrInvalidImport       = errors.New("invalid import file")
+	ErrInvalidImportFormat = errors.New("format must be csv or ndjson")
+	ErrInvalidImportPolicy = errors.New("on_duplicate must be skip, overwrite or fail")
+)
+
+// maxImportLine bounds a single NDJSON line.
+const maxImportLine = 1 << 20
+
+type ImportOptions struct {
+	// Format is ExportCSV or ExportNDJSON; export files can be imported
+	// as they are.
+	Format string
+	// Policy is one of the entity.Import* duplicate policies.
+	Policy string
+	DryRun bool
+}
+
+// ImportService loads users and ratings from CSV or NDJSON files. Rows are
+// validated and streamed into Postgres with COPY, and the leaderboard and
+// username index are reloaded once at the end.
+type ImportService struct {
+	importRepo      repository.ImportRepository
+	userRepo        repository.UserRepository
+	scoreRepo       repository.ScoreRepository
+	leaderboardRepo repository.LeaderboardRepository
+	usernameIndex   repository.UsernameIndex
+	ratings         entity.RatingRange
+	rebuild         RebuildOptions
+}
+
+func NewImportService(
+	importRepo repository.ImportRepository,
+	userRepo repository.UserRepository,
+	scoreRepo repository.ScoreRepository,
+	leaderboardRepo repository.LeaderboardRepository,
+	usernameIndex repository.UsernameIndex,
+	ratings entity.RatingRange,
+	rebuild RebuildOptions,
+) *ImportService {
+	return &ImportService{
+		importRepo:      importRepo,
+		userRepo:        userRepo,
+		scoreRepo:       scoreRepo,
+		leaderboardRepo: leaderboardRepo,
+		usernameIndex:   usernameIndex,
+		ratings:         ratings,
+		rebuild:         rebuild.withDefaults(),
+	}
+}
+
+// Import reads r and imports every valid row. Invalid rows are rejected and
+// listed in the report without stopping the import. Under entity.ImportFail
+// any duplicate aborts it with repository.ErrImportDuplicates. A header
+// without a username column, or an unreadable file, returns ErrInvalidImport.
+func (s *ImportService) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*entity.ImportReport, error) {
+	var read func(io.Reader, *entity.ImportReport, func(entity.ImportRow) error) error
+	switch opts.Format {
+	case ExportCSV:
+		read = s.readCSV
+	case ExportNDJSON:
+		read = s.readNDJSON
+	default:
+		return nil, ErrInvalidImportFormat
+	}
+	if !entity.ValidImportPolicy(opts.Policy) {
+		return nil, ErrInvalidImportPolicy
+	}
+
+	report := &entity.ImportReport{DryRun: opts.DryRun, Errors: []entity.ImportError{}}
+	err := s.importRepo.Import(ctx, opts.Policy, opts.DryRun, report, func(add func(entity.ImportRow) error) error {
+		return read(r, report, add)
+	})
+	sort.SliceStable(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Line < report.Errors[j].Line
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if opts.DryRun || report.Created+report.Updated == 0 {
+		return report, nil
+	}
+	if err := s.reloadLeaderboard(ctx); err != nil {
+		return report, fmt.Errorf("users were imported but reloading the leaderboard failed, rebuild it manually: %w", err)
+	}
+	if report.Created > 0 {
+		if err := indexUsernames(ctx, s.userRepo, s.usernameIndex); err != nil {
+			return report, fmt.Errorf("users were imported but indexing their usernames failed, rebuild the leaderboard to retry: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// reloadLeaderboard rebuilds the leaderboard from Postgres. A rebuild that
+// is already running may have read user_scores before the import
+// committed, so wait for it and run another.
+func (s *ImportService) reloadLeaderboard(ctx context.Context) error {
+	deadline := time.Now().Add(s.rebuild.LockTTL)
+	for {
+		err := rebuildLeaderboard(ctx, s.scoreRepo, s.leaderboardRepo, s.rebuild)
+		if err != repository.ErrRebuildInProgress || time.Now().After(deadline) {
+			return err
+		}
+		log.Printf("import: waiting for a running leaderboard rebuild")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// importRecord is one row before validation. Rating is empty when the
+// file does not give one.
+type importRecord struct {
+	line     int
+	username string
+	rating   string
+	userID   string
+}
+
+func (s *ImportService) readCSV(r io.Reader, report *entity.ImportReport, add func(entity.ImportRow) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return fmt.Errorf("%w: missing header", ErrInvalidImport)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	columns := map[string]int{"username": -1, "rating": -1, "user_id": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	if columns["username"] < 0 {
+		return fmt.Errorf("%w: header has no username column", ErrInvalidImport)
+	}
+
+	field := func(record []string, name string) string {
+		if i := columns[name]; i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Rows++
+			report.Rejected++
+			report.AddError(parseErr.Line, "", parseErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		report.Rows++
+		err = s.addRecord(importRecord{
+			line:     line,
+			username: field(record, "username"),
+			rating:   field(record, "rating"),
+			userID:   field(record, "user_id"),
+		}, report, add)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *ImportService) readNDJSON(r io.Reader, report *entity.ImportReport, add func(entity.ImportRow) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		report.Rows++
+
+		var obj struct {
+			Username string `json:"username"`
+			Rating   *int   `json:"rating"`
+			UserID   string `json:"user_id"`
+		}
+		if err := json.Unmarshal([]byte(text), &obj); err != nil {
+			report.Rejected++
+			report.AddError(line, "", "invalid JSON: "+err.Error())
+			continue
+		}
+
+		rating := ""
+		if obj.Rating != nil {
+			rating = strconv.Itoa(*obj.Rating)
+		}
+		err := s.addRecord(importRecord{
+			line:     line,
+			username: strings.TrimSpace(obj.Username),
+			rating:   rating,
+			userID:   strings.TrimSpace(obj.UserID),
+		}, report, add)
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: line %d: %v", ErrInvalidImport, line+1, err)
+	}
+	return nil
+}
+
+// addRecord validates rec and passes it on, or rejects it in the report.
+func (s *ImportService) addRecord(rec importRecord, report *entity.ImportReport, add func(entity.ImportRow) error) error {
+	row, problem := s.validate(rec)
+	if problem != "" {
+		report.Rejected++
+		report.AddError(rec.line, rec.username, problem)
+		return nil
+	}
+	return add(row)
+}
+
+// validate applies the same rules as creating a user through the API,
+// except that out-of-range ratings are rejected rather than clamped.
+func (s *ImportService) validate(rec importRecord) (entity.ImportRow, string) {
+	row := entity.ImportRow{Line: rec.line, Username: rec.username, Rating: s.ratings.Default}
+
+	if !utf8.ValidString(rec.username) || strings.ContainsRune(rec.username, 0) {
+		return row, "username must be valid UTF-8 text"
+	}
+	if n := utf8.RuneCountInString(rec.username); n < 3 || n > 50 {
+		return row, "username must be 3 to 50 characters"
+	}
+
+	if rec.rating != "" {
+		rating, err := strconv.Atoi(rec.rating)
+		if err != nil {
+			return row, fmt.Sprintf("rating %q is not an integer", rec.rating)
+		}
+		if !s.ratings.Contains(rating) {
+			return row, fmt.Sprintf("rating must be between %d and %d", s.ratings.Min, s.ratings.Max)
+		}
+		row.Rating = rating
+	}
+
+	row.UserID = uuid.New()
+	if rec.userID != "" {
+		id, err := uuid.Parse(rec.userID)
+		if err != nil || id == uuid.Nil {
+			return row, fmt.Sprintf("invalid user_id %q", rec.userID)
+		}
+		row.UserID = id
+	}
+
+	return row, ""
+}
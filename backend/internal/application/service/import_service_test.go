@@ -0,0 +1,41 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+func TestReadCSVReportsMalformedRows(t *testing.T) {
+	s := NewImportService(nil, nil, nil, nil, nil, entity.RatingRange{Min: 0, Max: 3000, Default: 1000}, RebuildOptions{})
+	in := strings.Join([]string{
+		"username,rating",
+		`ab"c,1200`,
+		"alice,1500",
+		"bo,900",
+		"carol,",
+	}, "\n")
+
+	report := &entity.ImportReport{}
+	var added []entity.ImportRow
+	err := s.readCSV(strings.NewReader(in), report, func(row entity.ImportRow) error {
+		added = append(added, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Rows != 4 || report.Rejected != 2 {
+		t.Fatalf("report %+v, want 4 rows with 2 rejected", report)
+	}
+	if len(report.Errors) != 2 || report.Errors[0].Line != 2 || report.Errors[1].Line != 4 {
+		t.Fatalf("errors %+v, want lines 2 and 4", report.Errors)
+	}
+	if len(added) != 2 ||
+		added[0].Line != 3 || added[0].Username != "alice" || added[0].Rating != 1500 ||
+		added[1].Line != 5 || added[1].Username != "carol" || added[1].Rating != 1000 {
+		t.Fatalf("added %+v, want alice at 1500 on line 3 and carol at the default on line 5", added)
+	}
+}
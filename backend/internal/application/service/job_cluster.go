@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+// errJobLeaseHeld means another run of a job holds its lease.
+var errJobLeaseHeld = errors.New("job lease held elsewhere")
+
+// JobCluster lets the API instances of a cluster share the decay and
+// reconciliation jobs. A run holds the job's lease for as long as it runs,
+// so only one runs at a time anywhere, and its report is saved for every
+// instance to read.
+type JobCluster struct {
+	Leases     repository.LeaseStore
+	State      repository.JobStateStore
+	InstanceID string
+	LeaseTTL   time.Duration
+}
+
+// running reports whether a run of job holds its lease anywhere.
+func (c JobCluster) running(ctx context.Context, job string) (bool, error) {
+	holder, err := c.Leases.Holder(ctx, job)
+	return holder != "", err
+}
+
+// hold takes the lease of job and renews it until release is called. The
+// returned context is cancelled when ctx is, or when the lease cannot be
+// renewed, so a run stops before another instance could start one. It
+// returns errJobLeaseHeld if the lease is taken.
+func (c JobCluster) hold(ctx context.Context, job string) (context.Context, func(), error) {
+	owned, err := c.Leases.Acquire(ctx, job, c.InstanceID, c.LeaseTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !owned {
+		return nil, nil, errJobLeaseHeld
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(c.LeaseTTL / 3)
+		defer ticker.Stop()
+
+		renewedAt := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			owned, err := c.Leases.Acquire(runCtx, job, c.InstanceID, c.LeaseTTL)
+			switch {
+			case err != nil:
+				log.Printf("%s: failed to renew lease: %v", job, err)
+				if time.Since(renewedAt) >= c.LeaseTTL*2/3 {
+					log.Printf("%s: cancelling run, its lease could not be renewed", job)
+					cancel()
+					return
+				}
+			case !owned:
+				log.Printf("%s: cancelling run, its lease was lost", job)
+				cancel()
+				return
+			default:
+				renewedAt = time.Now()
+			}
+		}
+	}()
+
+	release := func() {
+		close(done)
+		cancel()
+		if err := c.Leases.Release(context.Background(), job, c.InstanceID); err != nil {
+			log.Printf("%s: failed to release lease: %v", job, err)
+		}
+	}
+	return runCtx, release, nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+// InstanceID returns id, or a name for this process made of the host name
+// and process ID if id is empty.
+func InstanceID(id string) string {
+	if id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+type leaderJob struct {
+	name  string
+	start func()
+	stop  func()
+}
+
+// LeaderElector makes one API instance of the cluster the leader by holding
+// a lease, and runs the registered jobs only on the leader. Jobs start when
+// this instance wins the lease and stop when it loses it.
+type LeaderElector struct {
+	leases repository.LeaseStore
+	name   string
+	id     string
+	ttl    time.Duration
+	jobs   []leaderJob
+
+	mu      sync.Mutex
+	leader  bool
+	started bool
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+// NewLeaderElector competes for the lease name as instance id. The lease
+// expires ttl after the leader last renewed it, so a crashed leader is
+// replaced within ttl.
+func NewLeaderElector(leases repository.LeaseStore, name, id string, ttl time.Duration) *LeaderElector {
+	return &LeaderElector{
+		leases: leases,
+		name:   name,
+		id:     id,
+		ttl:    ttl,
+	}
+}
+
+// Add registers a job. It must be called before Start.
+func (e *LeaderElector) Add(name string, start, stop func()) {
+	e.jobs = append(e.jobs, leaderJob{name: name, start: start, stop: stop})
+}
+
+// Start campaigns for the lease until Stop is called.
+func (e *LeaderElector) Start() {
+	e.mu.Lock()
+	if e.started {
+		e.mu.Unlock()
+		return
+	}
+	e.started = true
+	e.stopCh = make(chan struct{})
+	e.doneCh = make(chan struct{})
+	stopCh, doneCh := e.stopCh, e.doneCh
+	e.mu.Unlock()
+
+	go e.campaign(stopCh, doneCh)
+}
+
+// Stop stops the jobs and gives up the lease, letting another instance
+// take over without waiting for it to expire.
+func (e *LeaderElector) Stop() {
+	e.mu.Lock()
+	if !e.started {
+		e.mu.Unlock()
+		return
+	}
+	e.started = false
+	close(e.stopCh)
+	doneCh := e.doneCh
+	e.mu.Unlock()
+
+	<-doneCh
+}
+
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+func (e *LeaderElector) ID() string {
+	return e.id
+}
+
+// Leader returns the instance holding the lease, or "" if none does.
+func (e *LeaderElector) Leader(ctx context.Context) (string, error) {
+	return e.leases.Holder(ctx, e.name)
+}
+
+func (e *LeaderElector) campaign(stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	// Renewing three times per TTL survives one failed attempt.
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	var renewedAt time.Time
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+		owned, err := e.leases.Acquire(ctx, e.name, e.id, e.ttl)
+		cancel()
+
+		switch {
+		case err != nil:
+			log.Printf("leader: failed to renew lease %s: %v", e.name, err)
+			// The lease may still be ours, but step down well before it
+			// could expire and pass to another instance.
+			if time.Since(renewedAt) >= e.ttl*2/3 {
+				e.setLeader(false)
+			}
+		case owned:
+			renewedAt = time.Now()
+			e.setLeader(true)
+		default:
+			e.setLeader(false)
+		}
+
+		select {
+		case <-stopCh:
+			e.setLeader(false)
+			ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+			if err := e.leases.Release(ctx, e.name, e.id); err != nil {
+				log.Printf("leader: failed to release lease %s: %v", e.name, err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setLeader starts or stops the jobs when leadership changes.
+func (e *LeaderElector) setLeader(leader bool) {
+	e.mu.Lock()
+	changed := e.leader != leader
+	e.leader = leader
+	e.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if leader {
+		log.Printf("leader: %s is now the leader for %s", e.id, e.name)
+		for _, job := range e.jobs {
+			log.Printf("leader: starting %s", job.name)
+			job.start()
+		}
+		return
+	}
+	log.Printf("leader: %s is no longer the leader for %s", e.id, e.name)
+	for _, job := range e.jobs {
+		log.Printf("leader: stopping %s", job.name)
+		job.stop()
+	}
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"log"
 	"sort"
 	"time"
 
@@ -10,24 +12,85 @@ import (
 	"github.com/rankq/backend/internal/domain/repository"
 )
 
+var (
+	ErrScoreRejected   = errors.New("score update rejected")
+	ErrScoreQueued     = errors.New("score update queued for review")
+	ErrScoreChanged    = errors.New("score changed since the anomaly was recorded")
+	ErrVersionConflict = errors.New("score version does not match")
+)
+
+// RebuildOptions controls how RebuildFromPostgres streams user_scores into
+// Redis.
+type RebuildOptions struct {
+	BatchSize int
+	// LockTTL is how long the rebuild lock survives without progress; each
+	// loaded batch extends it.
+	LockTTL time.Duration
+}
+
+func (o RebuildOptions) withDefaults() RebuildOptions {
+	if o.BatchSize < 1 {
+		o.BatchSize = 1000
+	}
+	if o.LockTTL <= 0 {
+		o.LockTTL = time.Minute
+	}
+	return o
+}
+
+// ScoreListener is told about every client score update that changed a
+// rating. It runs on the request path, so slow work belongs elsewhere.
+type ScoreListener interface {
+	ScoreChanged(ctx context.Context, event *entity.ScoreEvent)
+}
+
 type LeaderboardService struct {
 	userRepo        repository.UserRepository
 	scoreRepo       repository.ScoreRepository
 	leaderboardRepo repository.LeaderboardRepository
+	usernameIndex   repository.UsernameIndex
+	historyRepo     repository.ScoreHistoryRepository
+	anomalyRepo     repository.AnomalyRepository
+	detector        *AnomalyDetector
+	ratings         entity.RatingRange
+	rebuild         RebuildOptions
+	listeners       []ScoreListener
 }
 
 func NewLeaderboardService(
 	userRepo repository.UserRepository,
 	scoreRepo repository.ScoreRepository,
 	leaderboardRepo repository.LeaderboardRepository,
+	usernameIndex repository.UsernameIndex,
+	historyRepo repository.ScoreHistoryRepository,
+	anomalyRepo repository.AnomalyRepository,
+	detector *AnomalyDetector,
+	ratings entity.RatingRange,
+	rebuild RebuildOptions,
 ) *LeaderboardService {
 	return &LeaderboardService{
 		userRepo:        userRepo,
 		scoreRepo:       scoreRepo,
 		leaderboardRepo: leaderboardRepo,
+		usernameIndex:   usernameIndex,
+		historyRepo:     historyRepo,
+		anomalyRepo:     anomalyRepo,
+		detector:        detector,
+		ratings:         ratings,
+		rebuild:         rebuild.withDefaults(),
 	}
 }
 
+// AddListener registers l for score changes. It must be called before the
+// service handles requests.
+func (s *LeaderboardService) AddListener(l ScoreListener) {
+	s.listeners = append(s.listeners, l)
+}
+
+func (s *LeaderboardService) Ratings() entity.RatingRange {
+	return s.ratings
+}
+
 func (s *LeaderboardService) GetLeaderboard(ctx context.Context, page, pageSize int) ([]entity.LeaderboardEntry, int64, error) {
 	start := int64((page - 1) * pageSize)
 	stop := start + int64(pageSize) - 1
@@ -85,35 +148,121 @@ func (s *LeaderboardService) GetLeaderboard(ctx context.Context, page, pageSize
 	return entries, total, nil
 }
 
-func (s *LeaderboardService) Search(ctx context.Context, query string, limit int) ([]entity.SearchResult, error) {
-	users, err := s.userRepo.Search(ctx, query, limit)
+// GetAround returns the user and the up to n users ranked directly above and
+// below it, or nil if the user is not on the leaderboard.
+func (s *LeaderboardService) GetAround(ctx context.Context, userID uuid.UUID, n int) ([]entity.LeaderboardEntry, error) {
+	members, err := s.leaderboardRepo.GetNeighbors(ctx, userID, int64(n))
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	userIDs := make([]uuid.UUID, len(members))
+	ratings := make([]int, len(members))
+	for i, m := range members {
+		userIDs[i] = m.UserID
+		ratings[i] = m.Rating
+	}
+
+	ranks, err := s.leaderboardRepo.GetRanks(ctx, ratings)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.userRepo.GetByIDs(ctx, userIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(users) == 0 {
-		return []entity.SearchResult{}, nil
+	entries := make([]entity.LeaderboardEntry, 0, len(members))
+	for _, m := range members {
+		user, ok := users[m.UserID]
+		if !ok {
+			continue
+		}
+		entries = append(entries, entity.LeaderboardEntry{
+			Rank:     ranks[m.Rating],
+			Username: user.Username,
+			Rating:   m.Rating,
+			UserID:   m.UserID.String(),
+		})
 	}
 
-	results := make([]entity.SearchResult, 0, len(users))
+	return entries, nil
+}
 
-	for _, user := range users {
-		rating, err := s.leaderboardRepo.GetUserScore(ctx, user.ID)
+// ExportLeaderboard walks the live leaderboard from the top in pages of
+// batchSize and calls fn with each page joined with usernames and update
+// times. It is not a snapshot: scores changing during the walk can move
+// users between pages.
+func (s *LeaderboardService) ExportLeaderboard(ctx context.Context, batchSize int, fn func(entries []entity.ExportEntry) error) error {
+	for start := int64(0); ; start += int64(batchSize) {
+		members, err := s.leaderboardRepo.GetTopUsers(ctx, start, start+int64(batchSize)-1)
 		if err != nil {
-			continue
+			return err
+		}
+		if len(members) == 0 {
+			return nil
+		}
+
+		userIDs := make([]uuid.UUID, len(members))
+		ratings := make([]int, len(members))
+		for i, m := range members {
+			userIDs[i] = m.UserID
+			ratings[i] = m.Rating
 		}
 
-		rank, err := s.leaderboardRepo.GetRank(ctx, rating)
+		ranks, err := s.leaderboardRepo.GetRanks(ctx, ratings)
 		if err != nil {
-			continue
+			return err
+		}
+		users, err := s.userRepo.GetByIDs(ctx, userIDs)
+		if err != nil {
+			return err
+		}
+		scores, err := s.scoreRepo.GetByUserIDs(ctx, userIDs)
+		if err != nil {
+			return err
 		}
 
-		results = append(results, entity.SearchResult{
-			Rank:     rank,
-			Username: user.Username,
-			Rating:   rating,
-			UserID:   user.ID.String(),
-		})
+		entries := make([]entity.ExportEntry, 0, len(members))
+		for _, m := range members {
+			user, ok := users[m.UserID]
+			if !ok {
+				continue
+			}
+			entry := entity.ExportEntry{
+				Rank:     ranks[m.Rating],
+				Username: user.Username,
+				UserID:   m.UserID.String(),
+				Rating:   m.Rating,
+			}
+			if score, ok := scores[m.UserID]; ok {
+				entry.UpdatedAt = score.UpdatedAt
+			}
+			entries = append(entries, entry)
+		}
+
+		if err := fn(entries); err != nil {
+			return err
+		}
+		if len(members) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (s *LeaderboardService) Search(ctx context.Context, query string, limit int) ([]entity.SearchResult, error) {
+	users, err := s.userRepo.Search(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]repository.UsernameEntry, len(users))
+	for i, user := range users {
+		entries[i] = repository.UsernameEntry{UserID: user.ID, Username: user.Username}
+	}
+	results, err := s.rankEntries(ctx, entries)
+	if err != nil {
+		return nil, err
 	}
 
 	sort.Slice(results, func(i, j int) bool {
@@ -123,15 +272,230 @@ func (s *LeaderboardService) Search(ctx context.Context, query string, limit int
 	return results, nil
 }
 
-func (s *LeaderboardService) UpdateScore(ctx context.Context, userID uuid.UUID, rating int) error {
-	if rating < 100 {
-		rating = 100
+// autocompleteMaxBatches bounds how many batches of matches Autocomplete
+// reads while looking for enough ranked users.
+const autocompleteMaxBatches = 4
+
+// Autocomplete returns up to limit ranked users whose username starts with
+// prefix, ignoring case, in username order. It reads only Redis: a batch of
+// matches from the username index and then every rating and rank in one
+// round trip. Matches without a rank, such as users deleted while Redis was
+// unreachable, are skipped, and further batches are read to make up for
+// them.
+func (s *LeaderboardService) Autocomplete(ctx context.Context, prefix string, limit int) ([]entity.SearchResult, error) {
+	results := make([]entity.SearchResult, 0, limit)
+	var offset int64
+	for batch := 0; batch < autocompleteMaxBatches && len(results) < limit; batch++ {
+		entries, read, err := s.usernameIndex.Complete(ctx, prefix, offset, int64(limit))
+		if err != nil {
+			return nil, err
+		}
+		ranked, err := s.rankEntries(ctx, entries)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range ranked {
+			if len(results) == limit {
+				break
+			}
+			results = append(results, r)
+		}
+		if read < int64(limit) {
+			break
+		}
+		offset += read
+	}
+	return results, nil
+}
+
+// rankEntries looks up the rating and rank of each user, keeping their
+// order and dropping users missing from the leaderboard.
+func (s *LeaderboardService) rankEntries(ctx context.Context, entries []repository.UsernameEntry) ([]entity.SearchResult, error) {
+	results := make([]entity.SearchResult, 0, len(entries))
+	if len(entries) == 0 {
+		return results, nil
+	}
+
+	userIDs := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		userIDs[i] = entry.UserID
 	}
-	if rating > 5000 {
-		rating = 5000
+	ranked, err := s.leaderboardRepo.GetUserRanks(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		member, ok := ranked[entry.UserID]
+		if !ok {
+			continue
+		}
+		results = append(results, entity.SearchResult{
+			Rank:     member.Rank,
+			Username: entry.Username,
+			Rating:   member.Rating,
+			UserID:   entry.UserID.String(),
+		})
 	}
+	return results, nil
+}
 
-	if err := s.leaderboardRepo.UpdateScore(ctx, userID, rating); err != nil {
+// UpdateScore checks a client score write against the anomaly rules and
+// applies it unless a rule rejects it or holds it for review. The rules see
+// the rating the write would produce. When a rule matched, the recorded
+// anomaly is set on the result, which comes with ErrScoreRejected,
+// ErrScoreQueued, or a nil error for updates that were applied and flagged.
+// A ScoreModeCAS write against a stale version returns ErrVersionConflict
+// with the current rating and version.
+func (s *LeaderboardService) UpdateScore(ctx context.Context, userID uuid.UUID, write entity.ScoreWrite) (*entity.ScoreWriteResult, error) {
+	previous, err := s.leaderboardRepo.GetUserScores(ctx, []uuid.UUID{userID})
+	if err != nil {
+		return nil, err
+	}
+	var oldRating *int
+	if old, ok := previous[userID]; ok {
+		oldRating = &old
+	}
+	rating := write.Resolve(oldRating, s.ratings)
+
+	var anomaly *entity.ScoreAnomaly
+	if s.detector != nil && (oldRating == nil || *oldRating != rating) {
+		verdict, err := s.detector.Evaluate(ctx, ScoreUpdate{UserID: userID, OldRating: oldRating, NewRating: rating})
+		if err != nil {
+			return nil, err
+		}
+		if verdict.Action != "" {
+			anomaly = entity.NewScoreAnomaly(userID, oldRating, rating, verdict)
+			if err := s.anomalyRepo.Create(ctx, anomaly); err != nil {
+				return nil, err
+			}
+			switch verdict.Action {
+			case entity.AnomalyReject:
+				return &entity.ScoreWriteResult{PreviousRating: oldRating, Anomaly: anomaly}, ErrScoreRejected
+			case entity.AnomalyReview:
+				return &entity.ScoreWriteResult{PreviousRating: oldRating, Anomaly: anomaly}, ErrScoreQueued
+			}
+		}
+	}
+
+	// Listeners need the rank before the update; it is only looked up when
+	// someone is listening.
+	var oldRank *int64
+	if len(s.listeners) > 0 && oldRating != nil {
+		rank, err := s.leaderboardRepo.GetRank(ctx, *oldRating)
+		if err != nil {
+			return nil, err
+		}
+		oldRank = &rank
+	}
+
+	result, err := s.writeScore(ctx, userID, write)
+	if err != nil {
+		return nil, err
+	}
+	result.Anomaly = anomaly
+	if result.Conflict {
+		return result, ErrVersionConflict
+	}
+
+	if result.Changed {
+		score := &entity.UserScore{
+			UserID:    userID,
+			Rating:    result.Rating,
+			Version:   result.Version,
+			UpdatedAt: time.Now(),
+		}
+		if err := s.scoreRepo.UpsertVersioned(ctx, score); err != nil {
+			return nil, err
+		}
+		s.recordHistory(ctx, entity.NewScoreChange(userID, result.PreviousRating, result.Rating, entity.ScoreChangeUpdate))
+	}
+
+	result.Rank, err = s.leaderboardRepo.GetRank(ctx, result.Rating)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Changed {
+		event := &entity.ScoreEvent{
+			UserID:     userID,
+			OldRating:  result.PreviousRating,
+			NewRating:  result.Rating,
+			OldRank:    oldRank,
+			NewRank:    result.Rank,
+			Version:    result.Version,
+			OccurredAt: time.Now(),
+		}
+		if result.PreviousRating == nil {
+			event.OldRank = nil
+		}
+		for _, l := range s.listeners {
+			l.ScoreChanged(ctx, event)
+		}
+	}
+	return result, nil
+}
+
+// writeScore runs write against Redis, first seeding the user's version
+// from Postgres if Redis does not track it.
+func (s *LeaderboardService) writeScore(ctx context.Context, userID uuid.UUID, write entity.ScoreWrite) (*entity.ScoreWriteResult, error) {
+	result, err := s.leaderboardRepo.WriteScore(ctx, userID, write, s.ratings, entity.ScoreChangeUpdate)
+	if err != repository.ErrScoreVersionUnknown {
+		return result, err
+	}
+	if _, err := s.seedScoreVersion(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.leaderboardRepo.WriteScore(ctx, userID, write, s.ratings, entity.ScoreChangeUpdate)
+}
+
+// scoreVersion returns the user's current score version.
+func (s *LeaderboardService) scoreVersion(ctx context.Context, userID uuid.UUID) (int64, error) {
+	version, err := s.leaderboardRepo.GetScoreVersion(ctx, userID)
+	if err != repository.ErrScoreVersionUnknown {
+		return version, err
+	}
+	return s.seedScoreVersion(ctx, userID)
+}
+
+func (s *LeaderboardService) seedScoreVersion(ctx context.Context, userID uuid.UUID) (int64, error) {
+	score, err := s.scoreRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	var version int64
+	if score != nil {
+		version = score.Version
+	}
+	if err := s.leaderboardRepo.SeedScoreVersion(ctx, userID, version); err != nil {
+		return 0, err
+	}
+	// Another instance may have seeded or bumped it first.
+	return s.leaderboardRepo.GetScoreVersion(ctx, userID)
+}
+
+// ApplyModeratedScore writes a rating on a moderator's behalf, bypassing the
+// anomaly rules. If expected is non-nil the write only happens while the
+// stored rating still equals it.
+func (s *LeaderboardService) ApplyModeratedScore(ctx context.Context, userID uuid.UUID, rating int, expected *int) error {
+	current, err := s.scoreRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var oldRating *int
+	if current != nil {
+		oldRating = &current.Rating
+	}
+	if expected != nil && (oldRating == nil || *oldRating != *expected) {
+		return ErrScoreChanged
+	}
+
+	return s.applyScore(ctx, userID, oldRating, s.ratings.Clamp(rating), entity.ScoreChangeModeration)
+}
+
+func (s *LeaderboardService) applyScore(ctx context.Context, userID uuid.UUID, oldRating *int, rating int, reason string) error {
+	if err := s.leaderboardRepo.UpdateScore(ctx, userID, rating, reason); err != nil {
 		return err
 	}
 
@@ -141,7 +505,41 @@ func (s *LeaderboardService) UpdateScore(ctx context.Context, userID uuid.UUID,
 		UpdatedAt: time.Now(),
 	}
 
-	return s.scoreRepo.Upsert(ctx, score)
+	if err := s.scoreRepo.Upsert(ctx, score); err != nil {
+		return err
+	}
+
+	s.recordHistory(ctx, entity.NewScoreChange(userID, oldRating, rating, reason))
+	return nil
+}
+
+// recordHistory stores change. History is informational; a failed insert
+// must not fail a score change that has already been stored.
+func (s *LeaderboardService) recordHistory(ctx context.Context, change *entity.ScoreChange) {
+	if err := s.historyRepo.Record(ctx, change); err != nil {
+		log.Printf("leaderboard: failed to record score history for %s: %v", change.UserID, err)
+	}
+}
+
+// GetScoreHistory returns the most recent rating changes of userID, newest
+// first.
+func (s *LeaderboardService) GetScoreHistory(ctx context.Context, userID uuid.UUID, limit int) ([]*entity.ScoreChange, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	changes, err := s.historyRepo.ListByUser(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []*entity.ScoreChange{}
+	}
+	return changes, nil
 }
 
 func (s *LeaderboardService) GetUserRank(ctx context.Context, userID uuid.UUID) (*entity.SearchResult, error) {
@@ -163,24 +561,69 @@ func (s *LeaderboardService) GetUserRank(ctx context.Context, userID uuid.UUID)
 		return nil, err
 	}
 
+	version, err := s.scoreVersion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &entity.SearchResult{
 		Rank:     rank,
 		Username: user.Username,
 		Rating:   rating,
 		UserID:   user.ID.String(),
+		Version:  &version,
 	}, nil
 }
 
+// RebuildFromPostgres streams user_scores in batches into rebuild keys and
+// then swaps them over the live leaderboard in one step. Readers keep seeing
+// the old board until the swap, and score updates made meanwhile are mirrored
+// into the rebuild so they are not lost. Only one rebuild can run at a time
+// across all instances. The username index is then refilled from users.
 func (s *LeaderboardService) RebuildFromPostgres(ctx context.Context) error {
-	scores, err := s.scoreRepo.GetAll(ctx)
+	if err := rebuildLeaderboard(ctx, s.scoreRepo, s.leaderboardRepo, s.rebuild); err != nil {
+		return err
+	}
+	return indexUsernames(ctx, s.userRepo, s.usernameIndex)
+}
+
+func rebuildLeaderboard(ctx context.Context, scoreRepo repository.ScoreRepository, leaderboardRepo repository.LeaderboardRepository, opts RebuildOptions) error {
+	token, err := leaderboardRepo.BeginRebuild(ctx, opts.LockTTL)
 	if err != nil {
 		return err
 	}
 
-	scoreMap := make(map[uuid.UUID]int, len(scores))
-	for _, score := range scores {
-		scoreMap[score.UserID] = score.Rating
+	err = scoreRepo.StreamAll(ctx, opts.BatchSize, func(batch []*entity.UserScore) error {
+		scoreMap := make(map[uuid.UUID]int, len(batch))
+		for _, score := range batch {
+			scoreMap[score.UserID] = score.Rating
+		}
+		return leaderboardRepo.LoadRebuildBatch(ctx, token, opts.LockTTL, scoreMap)
+	})
+	if err != nil {
+		if abortErr := leaderboardRepo.AbortRebuild(context.Background(), token); abortErr != nil {
+			log.Printf("leaderboard: failed to abort rebuild: %v", abortErr)
+		}
+		return err
+	}
+
+	return leaderboardRepo.CommitRebuild(ctx, token)
+}
+
+// RebuildIfEmpty rebuilds the leaderboard when Redis holds no users, e.g.
+// after a Redis restart without persistence or a change of key names. It
+// reports whether a rebuild ran.
+func (s *LeaderboardService) RebuildIfEmpty(ctx context.Context) (bool, error) {
+	total, err := s.leaderboardRepo.GetTotalCount(ctx)
+	if err != nil || total > 0 {
+		return false, err
 	}
 
-	return s.leaderboardRepo.BulkLoad(ctx, scoreMap)
+	if err := s.RebuildFromPostgres(ctx); err != nil {
+		if err == repository.ErrRebuildInProgress {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
 }
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+var (
+	ErrAnomalyNotFound = errors.New("anomaly not found")
+	ErrAnomalyResolved = errors.New("anomaly has already been resolved")
+	ErrNoPriorRating   = errors.New("flagged update has no prior rating to revert to; approve it instead")
+)
+
+// ModerationService lets moderators review score anomalies. Approving a
+// pending update applies it; rejecting a flagged update reverts it if the
+// user's rating has not changed since.
+type ModerationService struct {
+	anomalyRepo        repository.AnomalyRepository
+	leaderboardService *LeaderboardService
+}
+
+func NewModerationService(
+	anomalyRepo repository.AnomalyRepository,
+	leaderboardService *LeaderboardService,
+) *ModerationService {
+	return &ModerationService{
+		anomalyRepo:        anomalyRepo,
+		leaderboardService: leaderboardService,
+	}
+}
+
+func (s *ModerationService) ListAnomalies(ctx context.Context, status string, page, pageSize int) ([]*entity.ScoreAnomaly, int64, error) {
+	anomalies, total, err := s.anomalyRepo.List(ctx, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if anomalies == nil {
+		anomalies = []*entity.ScoreAnomaly{}
+	}
+	return anomalies, total, nil
+}
+
+func (s *ModerationService) GetAnomaly(ctx context.Context, id int64) (*entity.ScoreAnomaly, error) {
+	anomaly, err := s.anomalyRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if anomaly == nil {
+		return nil, ErrAnomalyNotFound
+	}
+	return anomaly, nil
+}
+
+// Decide records a moderator's decision on a pending or flagged anomaly and
+// applies its effect on the user's rating.
+func (s *ModerationService) Decide(ctx context.Context, id int64, approve bool, reviewer, note string) (*entity.ScoreAnomaly, error) {
+	anomaly, err := s.GetAnomaly(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var to string
+	var apply func() error
+	switch {
+	case anomaly.Status == entity.AnomalyStatusPending && approve:
+		to = entity.AnomalyStatusApproved
+		apply = func() error {
+			return s.leaderboardService.ApplyModeratedScore(ctx, anomaly.UserID, anomaly.NewRating, anomaly.OldRating)
+		}
+	case anomaly.Status == entity.AnomalyStatusPending:
+		to = entity.AnomalyStatusRejected
+	case anomaly.Status == entity.AnomalyStatusFlagged && approve:
+		to = entity.AnomalyStatusApproved
+	case anomaly.Status == entity.AnomalyStatusFlagged:
+		if anomaly.OldRating == nil {
+			return nil, ErrNoPriorRating
+		}
+		to = entity.AnomalyStatusReverted
+		apply = func() error {
+			return s.leaderboardService.ApplyModeratedScore(ctx, anomaly.UserID, *anomaly.OldRating, &anomaly.NewRating)
+		}
+	default:
+		return nil, ErrAnomalyResolved
+	}
+
+	// Claim the anomaly first so two moderators cannot both apply it.
+	now := time.Now()
+	claimed, err := s.anomalyRepo.Resolve(ctx, id, anomaly.Status, to, reviewer, note, now)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrAnomalyResolved
+	}
+
+	if apply != nil {
+		if err := apply(); err != nil {
+			if _, undoErr := s.anomalyRepo.Resolve(context.Background(), id, to, anomaly.Status, "", "", now); undoErr != nil {
+				log.Printf("moderation: failed to reopen anomaly %d: %v", id, undoErr)
+			}
+			return nil, err
+		}
+	}
+
+	return s.GetAnomaly(ctx, id)
+}
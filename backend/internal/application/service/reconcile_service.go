@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+const reconcileJob = "reconcile"
+
+var ErrReconcileInProgress = errors.New("reconciliation already in progress")
+
+type ReconcileOptions struct {
+	// BatchSize is the number of rows or members compared per round trip.
+	BatchSize int
+	// GracePeriod skips users whose score changed more recently than this, so
+	// an update that has reached one store but not yet the other is not
+	// reported or "repaired" backwards.
+	GracePeriod time.Duration
+	// MaxReported caps the discrepancies kept in a report; totals are always
+	// exact.
+	MaxReported int
+}
+
+// ReconcileService compares the Redis leaderboard with user_scores and can
+// repair differences in place, one user or rating at a time, without the full
+// reload that RebuildFromPostgres performs.
+type ReconcileService struct {
+	scoreRepo       repository.ScoreRepository
+	leaderboardRepo repository.LeaderboardRepository
+	opts            ReconcileOptions
+
+	mu         sync.Mutex
+	inProgress bool
+	scheduled  bool
+	stopCh     chan struct{}
+	cancel     context.CancelFunc
+
+	cluster JobCluster
+}
+
+func NewReconcileService(
+	scoreRepo repository.ScoreRepository,
+	leaderboardRepo repository.LeaderboardRepository,
+	opts ReconcileOptions,
+	cluster JobCluster,
+) *ReconcileService {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1000
+	}
+	if opts.MaxReported < 1 {
+		opts.MaxReported = 100
+	}
+	return &ReconcileService{
+		scoreRepo:       scoreRepo,
+		leaderboardRepo: leaderboardRepo,
+		opts:            opts,
+		cluster:         cluster,
+	}
+}
+
+// Status reports whether a run is in progress on any instance and the most
+// recent report.
+func (s *ReconcileService) Status(ctx context.Context) (bool, *entity.ReconcileReport, error) {
+	running, err := s.cluster.running(ctx, reconcileJob)
+	if err != nil {
+		return false, nil, err
+	}
+	report, err := s.cluster.State.GetReconcileReport(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	return running, report, nil
+}
+
+// Trigger starts a run in the background.
+func (s *ReconcileService) Trigger(repair bool) error {
+	ctx, end, err := s.begin(context.Background())
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer end()
+		if _, err := s.run(ctx, repair); err != nil {
+			log.Printf("reconcile: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Run performs a reconciliation and waits for it to finish.
+func (s *ReconcileService) Run(ctx context.Context, repair bool) (*entity.ReconcileReport, error) {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return s.run(ctx, repair)
+}
+
+// StartSchedule runs a reconciliation every interval until Stop is called.
+func (s *ReconcileService) StartSchedule(interval time.Duration, repair bool) {
+	s.mu.Lock()
+	if s.scheduled {
+		s.mu.Unlock()
+		return
+	}
+	s.scheduled = true
+	s.stopCh = make(chan struct{})
+	stopCh := s.stopCh
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	log.Printf("reconcile: scheduled every %v (repair=%v)", interval, repair)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				report, err := s.Run(ctx, repair)
+				if err != nil {
+					log.Printf("reconcile: %v", err)
+					continue
+				}
+				if n := report.Missing + report.Extra + report.Mismatched + report.BadCounts; n > 0 {
+					log.Printf("reconcile: found %d discrepancies, repaired %d", n, report.Repaired)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the schedule and cancels a scheduled run in progress.
+func (s *ReconcileService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.scheduled {
+		return
+	}
+	close(s.stopCh)
+	s.cancel()
+	s.scheduled = false
+}
+
+// begin claims the job locally and across the cluster. The returned context
+// is cancelled if the cluster lease is lost, and end must be called when the
+// run finishes.
+func (s *ReconcileService) begin(ctx context.Context) (context.Context, func(), error) {
+	s.mu.Lock()
+	if s.inProgress {
+		s.mu.Unlock()
+		return nil, nil, ErrReconcileInProgress
+	}
+	s.inProgress = true
+	s.mu.Unlock()
+
+	runCtx, release, err := s.cluster.hold(ctx, reconcileJob)
+	if err != nil {
+		s.end()
+		if errors.Is(err, errJobLeaseHeld) {
+			return nil, nil, ErrReconcileInProgress
+		}
+		return nil, nil, err
+	}
+	return runCtx, func() {
+		release()
+		s.end()
+	}, nil
+}
+
+func (s *ReconcileService) end() {
+	s.mu.Lock()
+	s.inProgress = false
+	s.mu.Unlock()
+}
+
+func (s *ReconcileService) run(ctx context.Context, repair bool) (*entity.ReconcileReport, error) {
+	report := &entity.ReconcileReport{
+		StartedAt:     time.Now(),
+		Repair:        repair,
+		Discrepancies: []entity.ScoreDiscrepancy{},
+		RatingCounts:  []entity.RatingCountDiscrepancy{},
+	}
+
+	observed := make(map[int]struct{})
+	err := s.checkPostgres(ctx, report, repair)
+	if err == nil {
+		err = s.checkRedis(ctx, report, repair, observed)
+	}
+	if err == nil {
+		err = s.checkRatingCounts(ctx, report, repair, observed)
+	}
+
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	// Saved even if ctx was cancelled, so the partial report is visible.
+	if saveErr := s.cluster.State.SaveReconcileReport(context.Background(), report); saveErr != nil {
+		log.Printf("reconcile: failed to save report: %v", saveErr)
+	}
+
+	return report, err
+}
+
+// checkPostgres streams user_scores and looks each batch up in Redis to find
+// users that are missing or have a different rating.
+func (s *ReconcileService) checkPostgres(ctx context.Context, report *entity.ReconcileReport, repair bool) error {
+	return s.scoreRepo.StreamAll(ctx, s.opts.BatchSize, func(batch []*entity.UserScore) error {
+		report.PostgresUsers += int64(len(batch))
+
+		cutoff := time.Now().Add(-s.opts.GracePeriod)
+		userIDs := make([]uuid.UUID, 0, len(batch))
+		for _, score := range batch {
+			if score.UpdatedAt.Before(cutoff) {
+				userIDs = append(userIDs, score.UserID)
+			}
+		}
+
+		redisScores, err := s.leaderboardRepo.GetUserScores(ctx, userIDs)
+		if err != nil {
+			return err
+		}
+
+		var candidates []uuid.UUID
+		for _, score := range batch {
+			if !score.UpdatedAt.Before(cutoff) {
+				continue
+			}
+			if rating, ok := redisScores[score.UserID]; !ok || rating != score.Rating {
+				candidates = append(candidates, score.UserID)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+
+		// Re-read both stores so a write that landed mid-batch is not
+		// reported or undone.
+		pgScores, err := s.scoreRepo.GetByUserIDs(ctx, candidates)
+		if err != nil {
+			return err
+		}
+		redisScores, err = s.leaderboardRepo.GetUserScores(ctx, candidates)
+		if err != nil {
+			return err
+		}
+
+		for _, userID := range candidates {
+			pg, ok := pgScores[userID]
+			if !ok || !pg.UpdatedAt.Before(cutoff) {
+				continue
+			}
+			pgRating := pg.Rating
+
+			d := entity.ScoreDiscrepancy{UserID: userID, PostgresRating: &pgRating}
+			if rating, ok := redisScores[userID]; !ok {
+				d.Kind = entity.DiscrepancyMissing
+				report.Missing++
+			} else if rating != pgRating {
+				redisRating := rating
+				d.Kind = entity.DiscrepancyMismatched
+				d.RedisRating = &redisRating
+				report.Mismatched++
+			} else {
+				continue
+			}
+
+			if repair {
+				if err := s.leaderboardRepo.UpdateScore(ctx, userID, pgRating, entity.ScoreChangeReconcile); err != nil {
+					return err
+				}
+				d.Repaired = true
+				report.Repaired++
+			}
+			s.record(report, d)
+		}
+
+		return nil
+	})
+}
+
+// checkRedis scans {leaderboard}:users for members with no row in user_scores,
+// collecting the distinct ratings it sees into observed.
+func (s *ReconcileService) checkRedis(ctx context.Context, report *entity.ReconcileReport, repair bool, observed map[int]struct{}) error {
+	total, err := s.leaderboardRepo.GetTotalCount(ctx)
+	if err != nil {
+		return err
+	}
+	report.RedisUsers = total
+
+	seen := make(map[uuid.UUID]bool)
+	var cursor uint64
+	for {
+		members, next, err := s.leaderboardRepo.ScanMembers(ctx, cursor, int64(s.opts.BatchSize))
+		if err != nil {
+			return err
+		}
+
+		if len(members) > 0 {
+			userIDs := make([]uuid.UUID, len(members))
+			for i, m := range members {
+				userIDs[i] = m.UserID
+				observed[m.Rating] = struct{}{}
+			}
+
+			pgScores, err := s.scoreRepo.GetByUserIDs(ctx, userIDs)
+			if err != nil {
+				return err
+			}
+
+			for _, m := range members {
+				if _, ok := pgScores[m.UserID]; ok || seen[m.UserID] {
+					continue
+				}
+				seen[m.UserID] = true
+
+				// Confirm the row is still absent before removing the member.
+				if still, err := s.scoreRepo.GetByUserID(ctx, m.UserID); err != nil {
+					return err
+				} else if still != nil {
+					continue
+				}
+
+				redisRating := m.Rating
+				d := entity.ScoreDiscrepancy{
+					UserID:      m.UserID,
+					Kind:        entity.DiscrepancyExtra,
+					RedisRating: &redisRating,
+				}
+				report.Extra++
+
+				if repair {
+					if err := s.leaderboardRepo.RemoveUser(ctx, m.UserID); err != nil {
+						return err
+					}
+					d.Repaired = true
+					report.Repaired++
+				}
+				s.record(report, d)
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// checkRatingCounts compares {leaderboard}:rating_counts and {leaderboard}:ratings
+// with exact per-rating counts taken from {leaderboard}:users. observed supplies
+// ratings held by users that may be absent from both the hash and the index.
+func (s *ReconcileService) checkRatingCounts(ctx context.Context, report *entity.ReconcileReport, repair bool, observed map[int]struct{}) error {
+	recorded, err := s.leaderboardRepo.GetRatingCounts(ctx)
+	if err != nil {
+		return err
+	}
+	buckets, err := s.leaderboardRepo.GetRatingBuckets(ctx)
+	if err != nil {
+		return err
+	}
+
+	indexed := make(map[int]bool, len(buckets))
+	candidates := make(map[int]struct{}, len(recorded))
+	for _, rating := range buckets {
+		indexed[rating] = true
+		candidates[rating] = struct{}{}
+	}
+	for rating := range recorded {
+		candidates[rating] = struct{}{}
+	}
+	for rating := range observed {
+		candidates[rating] = struct{}{}
+	}
+
+	ratings := make([]int, 0, len(candidates))
+	for rating := range candidates {
+		ratings = append(ratings, rating)
+	}
+
+	actual, err := s.leaderboardRepo.CountByRatings(ctx, ratings)
+	if err != nil {
+		return err
+	}
+
+	for _, rating := range ratings {
+		want := actual[rating]
+		if recorded[rating] == want && indexed[rating] == (want > 0) {
+			continue
+		}
+
+		d := entity.RatingCountDiscrepancy{
+			Rating:   rating,
+			Recorded: recorded[rating],
+			Actual:   want,
+			InIndex:  indexed[rating],
+		}
+		report.BadCounts++
+
+		if repair {
+			if err := s.leaderboardRepo.RepairRatingCount(ctx, rating); err != nil {
+				return err
+			}
+			d.Repaired = true
+			report.Repaired++
+		}
+
+		if len(report.RatingCounts) < s.opts.MaxReported {
+			report.RatingCounts = append(report.RatingCounts, d)
+		} else {
+			report.Truncated = true
+		}
+	}
+
+	return nil
+}
+
+func (s *ReconcileService) record(report *entity.ReconcileReport, d entity.ScoreDiscrepancy) {
+	if len(report.Discrepancies) < s.opts.MaxReported {
+		report.Discrepancies = append(report.Discrepancies, d)
+		return
+	}
+	report.Truncated = true
+}
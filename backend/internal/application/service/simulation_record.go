@@ -0,0 +1,223 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+var ErrInvalidRecord = errors.New("invalid simulation record")
+
+// simulationRecordLine is one line of a record file. The first line has
+// type "run" and describes the run; every other line is an "update".
+type simulationRecordLine struct {
+	Type     string                `json:"type"`
+	Run      *entity.SimulationRun `json:"run,omitempty"`
+	Tick     int64                 `json:"tick,omitempty"`
+	UserID   uuid.UUID             `json:"user_id,omitempty"`
+	Username string                `json:"username,omitempty"`
+	Rating   int                   `json:"rating,omitempty"`
+}
+
+type simulationRecorder struct {
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+func newSimulationRecorder(path string) (*simulationRecorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(file)
+	return &simulationRecorder{file: file, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (r *simulationRecorder) header(run *entity.SimulationRun) error {
+	if err := r.enc.Encode(simulationRecordLine{Type: "run", Run: run}); err != nil {
+		return err
+	}
+	return r.flush()
+}
+
+func (r *simulationRecorder) update(tick int64, userID uuid.UUID, username string, rating int) error {
+	return r.enc.Encode(simulationRecordLine{Type: "update", Tick: tick, UserID: userID, Username: username, Rating: rating})
+}
+
+func (r *simulationRecorder) flush() error {
+	return r.w.Flush()
+}
+
+func (r *simulationRecorder) close() error {
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+func (r *simulationRecorder) discard() {
+	r.file.Close()
+	os.Remove(r.file.Name())
+}
+
+// ReplayOptions controls Replay. Speed scales the recorded tick interval:
+// 2 replays twice as fast, 0 as fast as possible.
+type ReplayOptions struct {
+	Speed float64
+}
+
+// ReplayReport summarizes a replay. Run is the recorded run's header.
+type ReplayReport struct {
+	Run          *entity.SimulationRun `json:"run"`
+	Ticks        int64                 `json:"ticks"`
+	Updates      int64                 `json:"updates"`
+	UsersCreated int64                 `json:"users_created"`
+	Errors       int64                 `json:"errors"`
+}
+
+// Replay applies the updates of a record file tick by tick. Users missing
+// from this environment are matched by username or created with their
+// recorded ID, so a recording can be replayed against a fresh database.
+func (s *SimulationService) Replay(ctx context.Context, r io.Reader, opts ReplayOptions) (*ReplayReport, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	var first simulationRecordLine
+	if err := dec.Decode(&first); err != nil || first.Type != "run" || first.Run == nil {
+		return nil, fmt.Errorf("%w: the first line must describe the run", ErrInvalidRecord)
+	}
+	report := &ReplayReport{Run: first.Run}
+
+	var pause time.Duration
+	if opts.Speed > 0 {
+		pause = time.Duration(float64(time.Duration(first.Run.IntervalMs)*time.Millisecond) / opts.Speed)
+	}
+
+	users := make(map[uuid.UUID]uuid.UUID)
+	var batch []simulationRecordLine
+	apply := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if report.Ticks > 0 && pause > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pause):
+			}
+		}
+		if err := s.replayTick(ctx, batch, users, report); err != nil {
+			return err
+		}
+		report.Ticks++
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		var line simulationRecordLine
+		err := dec.Decode(&line)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+		}
+		if line.Type != "update" || line.UserID == uuid.Nil {
+			return report, fmt.Errorf("%w: unexpected line of type %q", ErrInvalidRecord, line.Type)
+		}
+
+		if len(batch) > 0 && line.Tick != batch[0].Tick {
+			if err := apply(); err != nil {
+				return report, err
+			}
+		}
+		batch = append(batch, line)
+	}
+
+	return report, apply()
+}
+
+// replayTick applies one tick, resolving recorded user IDs to local ones
+// through users.
+func (s *SimulationService) replayTick(ctx context.Context, batch []simulationRecordLine, users map[uuid.UUID]uuid.UUID, report *ReplayReport) error {
+	var unknown []uuid.UUID
+	for _, line := range batch {
+		if _, ok := users[line.UserID]; !ok {
+			unknown = append(unknown, line.UserID)
+		}
+	}
+
+	if len(unknown) > 0 {
+		found, err := s.userRepo.GetByIDs(ctx, unknown)
+		if err != nil {
+			return err
+		}
+		for id := range found {
+			users[id] = id
+		}
+	}
+
+	updates := make([]repository.LeaderboardMember, 0, len(batch))
+	for _, line := range batch {
+		localID, ok := users[line.UserID]
+		if !ok {
+			var err error
+			if localID, err = s.replayUser(ctx, line, report); err != nil {
+				return err
+			}
+			users[line.UserID] = localID
+		}
+		updates = append(updates, repository.LeaderboardMember{UserID: localID, Rating: line.Rating})
+	}
+
+	updates = s.prepareUpdates(updates)
+	if err := s.applyUpdates(ctx, updates); err != nil {
+		report.Errors += int64(len(updates))
+		return nil
+	}
+	report.Updates += int64(len(updates))
+	return nil
+}
+
+// replayUser finds a recorded user by username or creates it.
+func (s *SimulationService) replayUser(ctx context.Context, line simulationRecordLine, report *ReplayReport) (uuid.UUID, error) {
+	username := line.Username
+	if username == "" {
+		username = "replay_" + line.UserID.String()[:8]
+	}
+
+	existing, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if existing != nil {
+		return existing.ID, nil
+	}
+
+	user := entity.NewUser(username)
+	user.ID = line.UserID
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return uuid.Nil, err
+	}
+	entry := repository.UsernameEntry{UserID: user.ID, Username: user.Username}
+	if err := s.usernameIndex.Add(ctx, []repository.UsernameEntry{entry}); err != nil {
+		return uuid.Nil, err
+	}
+	report.UsersCreated++
+	return user.ID, nil
+}
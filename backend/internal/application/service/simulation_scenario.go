@@ -0,0 +1,648 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+var ErrInvalidScenario = errors.New("invalid simulation scenario")
+
+// SimulationScenario decides the rating changes of one simulation tick.
+// A scenario value lives for one run and may keep state between ticks.
+type SimulationScenario interface {
+	Name() string
+	// Params returns the effective parameters, defaults included.
+	Params() map[string]float64
+	// Tick returns up to tick.Count new ratings. They are clamped to the
+	// rating range before being applied.
+	Tick(ctx context.Context, tick *SimulationTick) ([]repository.LeaderboardMember, error)
+}
+
+// SimulationScenarioStats is implemented by scenarios that measure their
+// run, e.g. how closely ratings follow a hidden truth. Stats is called
+// concurrently with Tick.
+type SimulationScenarioStats interface {
+	Stats() map[string]float64
+}
+
+// SimulationTick gives a scenario access to the population it changes.
+type SimulationTick struct {
+	Count   int
+	Ratings entity.RatingRange
+	Rand    *rand.Rand
+
+	// users is the run's cached population, sorted by ID.
+	users           []uuid.UUID
+	leaderboardRepo repository.LeaderboardRepository
+}
+
+// Sample returns n users chosen at random, possibly with repeats, and their
+// current ratings. Users that left the leaderboard since the population was
+// loaded are left out, so fewer than n may be returned.
+func (t *SimulationTick) Sample(ctx context.Context, n int) ([]repository.LeaderboardMember, error) {
+	if len(t.users) == 0 || n <= 0 {
+		return nil, nil
+	}
+	userIDs := make([]uuid.UUID, n)
+	for i := range userIDs {
+		userIDs[i] = t.users[t.Rand.Intn(len(t.users))]
+	}
+
+	ratings, err := t.leaderboardRepo.GetUserScores(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]repository.LeaderboardMember, 0, n)
+	for _, id := range userIDs {
+		if rating, ok := ratings[id]; ok {
+			members = append(members, repository.LeaderboardMember{UserID: id, Rating: rating})
+		}
+	}
+	return members, nil
+}
+
+// Each calls fn for every user of the population, always in the same order.
+func (t *SimulationTick) Each(fn func(userID uuid.UUID)) {
+	for _, id := range t.users {
+		fn(id)
+	}
+}
+
+// Neighbors returns userID and the up to n users ranked directly above and
+// below it on the leaderboard, highest rating first.
+func (t *SimulationTick) Neighbors(ctx context.Context, userID uuid.UUID, n int) ([]repository.LeaderboardMember, error) {
+	return t.leaderboardRepo.GetNeighbors(ctx, userID, int64(n))
+}
+
+// Top returns the n best users, highest rating first.
+func (t *SimulationTick) Top(ctx context.Context, n int) ([]repository.LeaderboardMember, error) {
+	return t.leaderboardRepo.GetTopUsers(ctx, 0, int64(n)-1)
+}
+
+// CurrentRatings returns the ratings of userIDs; users no longer on the
+// leaderboard are missing from the result.
+func (t *SimulationTick) CurrentRatings(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	return t.leaderboardRepo.GetUserScores(ctx, userIDs)
+}
+
+type scenarioFactory struct {
+	description string
+	defaults    map[string]float64
+	validate    func(p map[string]float64, lim scenarioLimits) error
+	build       func(p map[string]float64) SimulationScenario
+}
+
+var simulationScenarios = map[string]scenarioFactory{
+	"uniform": {
+		description: "uniform delta of up to max_delta, pulled back from the ends of the rating range",
+		defaults:    map[string]float64{"max_delta": 100},
+		validate: func(p map[string]float64, lim scenarioLimits) error {
+			return between(p, "max_delta", 1, lim.span)
+		},
+		build: func(p map[string]float64) SimulationScenario {
+			return &uniformScenario{params: p}
+		},
+	},
+	"gaussian": {
+		description: "normally distributed delta with mean and stddev",
+		defaults:    map[string]float64{"mean": 0, "stddev": 50},
+		validate: func(p map[string]float64, lim scenarioLimits) error {
+			return atLeast(p, "stddev", 0)
+		},
+		build: func(p map[string]float64) SimulationScenario {
+			return &gaussianScenario{params: p}
+		},
+	},
+	"heavy_tail": {
+		description: "mostly small deltas with rare large jumps, Pareto distributed with scale and alpha, capped at max_jump",
+		defaults:    map[string]float64{"scale": 10, "alpha": 1.5, "max_jump": 1000},
+		validate: func(p map[string]float64, lim scenarioLimits) error {
+			if err := atLeast(p, "scale", 1); err != nil {
+				return err
+			}
+			if p["alpha"] <= 0 {
+				return fmt.Errorf("%w: alpha must be positive", ErrInvalidScenario)
+			}
+			return atLeast(p, "max_jump", p["scale"])
+		},
+		build: func(p map[string]float64) SimulationScenario {
+			return &heavyTailScenario{params: p}
+		},
+	},
+	"climber": {
+		description: "a cohort of cohort_size users gains climb points per tick while the rest drift with noise stddev",
+		defaults:    map[string]float64{"cohort_size": 10, "climb": 25, "noise": 30},
+		validate: func(p map[string]float64, lim scenarioLimits) error {
+			if err := between(p, "cohort_size", 1, lim.maxUpdates); err != nil {
+				return err
+			}
+			if err := atLeast(p, "climb", 1); err != nil {
+				return err
+			}
+			return atLeast(p, "noise", 0)
+		},
+		build: func(p map[string]float64) SimulationScenario {
+			return &climberScenario{params: p}
+		},
+	},
+	"mass_tie": {
+		description: "moves users onto a few shared ratings: target (0 for the default rating) plus up to buckets-1",
+		defaults:    map[string]float64{"target": 0, "buckets": 1},
+		validate: func(p map[string]float64, lim scenarioLimits) error {
+			if err := atLeast(p, "target", 0); err != nil {
+				return err
+			}
+			return between(p, "buckets", 1, lim.span)
+		},
+		build: func(p map[string]float64) SimulationScenario {
+			return &massTieScenario{params: p}
+		},
+	},
+	"top_churn": {
+		description: "keeps reshuffling the top n: users just below it jump in and members drop by up to drop points",
+		defaults:    map[string]float64{"n": 10, "drop": 100},
+		validate: func(p map[string]float64, lim scenarioLimits) error {
+			if err := between(p, "n", 1, lim.maxUpdates); err != nil {
+				return err
+			}
+			return between(p, "drop", 1, lim.span)
+		},
+		build: func(p map[string]float64) SimulationScenario {
+			return &topChurnScenario{params: p}
+		},
+	},
+	"matches": {
+		description: "plays updates_per_tick/2 matches between neighbours on the leaderboard, decided by hidden skills drawn with stddev skill_spread and rated with Elo using k",
+		defaults:    map[string]float64{"k": entity.DefaultKFactor, "skill_spread": 300, "window": 5},
+		validate: func(p map[string]float64, lim scenarioLimits) error {
+			if err := atLeast(p, "k", 1); err != nil {
+				return err
+			}
+			if err := atLeast(p, "skill_spread", 0); err != nil {
+				return err
+			}
+			return between(p, "window", 1, maxMatchWindow)
+		},
+		build: func(p map[string]float64) SimulationScenario {
+			return &matchScenario{params: p, elo: entity.Elo{KFactor: p["k"]}, skills: make(map[uuid.UUID]float64)}
+		},
+	},
+}
+
+// DefaultSimulationScenario reproduces the original simulation behaviour.
+const DefaultSimulationScenario = "uniform"
+
+// SimulationScenarioInfo describes a scenario and its default parameters.
+type SimulationScenarioInfo struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Defaults    map[string]float64 `json:"defaults"`
+}
+
+func SimulationScenarios() []SimulationScenarioInfo {
+	infos := make([]SimulationScenarioInfo, 0, len(simulationScenarios))
+	for name, f := range simulationScenarios {
+		infos = append(infos, SimulationScenarioInfo{Name: name, Description: f.description, Defaults: f.defaults})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// NewSimulationScenario builds the named scenario. params overrides its
+// defaults; unknown parameters are an error, as are parameters too large for
+// ratings or for maxUpdatesPerTick updates a tick.
+func NewSimulationScenario(name string, params map[string]float64, ratings entity.RatingRange, maxUpdatesPerTick int) (SimulationScenario, error) {
+	if name == "" {
+		name = DefaultSimulationScenario
+	}
+	f, ok := simulationScenarios[name]
+	if !ok {
+		names := make([]string, 0, len(simulationScenarios))
+		for n := range simulationScenarios {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%w: unknown scenario %q (want %s)", ErrInvalidScenario, name, strings.Join(names, ", "))
+	}
+
+	p := make(map[string]float64, len(f.defaults))
+	for k, v := range f.defaults {
+		p[k] = v
+	}
+	for k, v := range params {
+		if _, ok := f.defaults[k]; !ok {
+			return nil, fmt.Errorf("%w: scenario %s has no parameter %q", ErrInvalidScenario, name, k)
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("%w: %s must be a finite number", ErrInvalidScenario, k)
+		}
+		p[k] = v
+	}
+	lim := scenarioLimits{span: ratings.Max - ratings.Min, maxUpdates: maxUpdatesPerTick}
+	if err := f.validate(p, lim); err != nil {
+		return nil, err
+	}
+	return f.build(p), nil
+}
+
+// scenarioLimits bounds the parameters that size a tick's reads and random
+// draws.
+type scenarioLimits struct {
+	span       int
+	maxUpdates int
+}
+
+// maxMatchWindow bounds how many neighbours the match scenario reads to find
+// an opponent.
+const maxMatchWindow = 100
+
+func atLeast(p map[string]float64, name string, min float64) error {
+	if p[name] < min {
+		return fmt.Errorf("%w: %s must be at least %g", ErrInvalidScenario, name, min)
+	}
+	return nil
+}
+
+func between(p map[string]float64, name string, min float64, max int) error {
+	if err := atLeast(p, name, min); err != nil {
+		return err
+	}
+	if p[name] > float64(max) {
+		return fmt.Errorf("%w: %s must be at most %d", ErrInvalidScenario, name, max)
+	}
+	return nil
+}
+
+type uniformScenario struct {
+	params map[string]float64
+}
+
+func (s *uniformScenario) Name() string               { return "uniform" }
+func (s *uniformScenario) Params() map[string]float64 { return s.params }
+
+func (s *uniformScenario) Tick(ctx context.Context, tick *SimulationTick) ([]repository.LeaderboardMember, error) {
+	maxDelta := int(s.params["max_delta"])
+	span := tick.Ratings.Max - tick.Ratings.Min
+
+	members, err := tick.Sample(ctx, tick.Count)
+	if err != nil {
+		return nil, err
+	}
+	for i := range members {
+		// Pull ratings near either end of the range back toward the middle.
+		delta := tick.Rand.Intn(2*maxDelta+1) - maxDelta
+		if members[i].Rating > tick.Ratings.Max-span/5 {
+			delta -= maxDelta / 2
+		} else if members[i].Rating < tick.Ratings.Min+span/10 {
+			delta += maxDelta / 2
+		}
+		members[i].Rating += delta
+	}
+	return members, nil
+}
+
+type gaussianScenario struct {
+	params map[string]float64
+}
+
+func (s *gaussianScenario) Name() string               { return "gaussian" }
+func (s *gaussianScenario) Params() map[string]float64 { return s.params }
+
+func (s *gaussianScenario) Tick(ctx context.Context, tick *SimulationTick) ([]repository.LeaderboardMember, error) {
+	members, err := tick.Sample(ctx, tick.Count)
+	if err != nil {
+		return nil, err
+	}
+	for i := range members {
+		members[i].Rating += int(math.Round(tick.Rand.NormFloat64()*s.params["stddev"] + s.params["mean"]))
+	}
+	return members, nil
+}
+
+type heavyTailScenario struct {
+	params map[string]float64
+}
+
+func (s *heavyTailScenario) Name() string               { return "heavy_tail" }
+func (s *heavyTailScenario) Params() map[string]float64 { return s.params }
+
+func (s *heavyTailScenario) Tick(ctx context.Context, tick *SimulationTick) ([]repository.LeaderboardMember, error) {
+	scale, alpha, maxJump := s.params["scale"], s.params["alpha"], s.params["max_jump"]
+
+	members, err := tick.Sample(ctx, tick.Count)
+	if err != nil {
+		return nil, err
+	}
+	for i := range members {
+		// Inverse transform sampling of a Pareto distribution.
+		jump := math.Min(scale/math.Pow(1-tick.Rand.Float64(), 1/alpha), maxJump)
+		if tick.Rand.Intn(2) == 0 {
+			jump = -jump
+		}
+		members[i].Rating += int(math.Round(jump))
+	}
+	return members, nil
+}
+
+type climberScenario struct {
+	params map[string]float64
+	cohort []uuid.UUID
+}
+
+func (s *climberScenario) Name() string               { return "climber" }
+func (s *climberScenario) Params() map[string]float64 { return s.params }
+
+func (s *climberScenario) Tick(ctx context.Context, tick *SimulationTick) ([]repository.LeaderboardMember, error) {
+	if len(s.cohort) == 0 {
+		candidates, err := tick.Sample(ctx, int(s.params["cohort_size"]))
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[uuid.UUID]bool)
+		for _, m := range candidates {
+			if !seen[m.UserID] {
+				seen[m.UserID] = true
+				s.cohort = append(s.cohort, m.UserID)
+			}
+		}
+	}
+
+	ratings, err := tick.CurrentRatings(ctx, s.cohort)
+	if err != nil {
+		return nil, err
+	}
+
+	climb := s.params["climb"]
+	updates := make([]repository.LeaderboardMember, 0, tick.Count)
+	for _, userID := range s.cohort {
+		rating, ok := ratings[userID]
+		if !ok || len(updates) == tick.Count {
+			continue
+		}
+		// Climbers advance by climb on average, never backwards.
+		step := int(math.Round(climb/2 + tick.Rand.Float64()*climb))
+		updates = append(updates, repository.LeaderboardMember{UserID: userID, Rating: rating + step})
+	}
+
+	noise, err := tick.Sample(ctx, tick.Count-len(updates))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range noise {
+		m.Rating += int(math.Round(tick.Rand.NormFloat64() * s.params["noise"]))
+		updates = append(updates, m)
+	}
+	return updates, nil
+}
+
+type massTieScenario struct {
+	params map[string]float64
+}
+
+func (s *massTieScenario) Name() string               { return "mass_tie" }
+func (s *massTieScenario) Params() map[string]float64 { return s.params }
+
+func (s *massTieScenario) Tick(ctx context.Context, tick *SimulationTick) ([]repository.LeaderboardMember, error) {
+	target := int(s.params["target"])
+	if target == 0 {
+		target = tick.Ratings.Default
+	}
+	buckets := int(s.params["buckets"])
+
+	members, err := tick.Sample(ctx, tick.Count)
+	if err != nil {
+		return nil, err
+	}
+	for i := range members {
+		members[i].Rating = target + tick.Rand.Intn(buckets)
+	}
+	return members, nil
+}
+
+type topChurnScenario struct {
+	params map[string]float64
+}
+
+func (s *topChurnScenario) Name() string               { return "top_churn" }
+func (s *topChurnScenario) Params() map[string]float64 { return s.params }
+
+func (s *topChurnScenario) Tick(ctx context.Context, tick *SimulationTick) ([]repository.LeaderboardMember, error) {
+	n := int(s.params["n"])
+	drop := int(s.params["drop"])
+
+	// The top n plus as many challengers from just below it.
+	members, err := tick.Top(ctx, 2*n)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) <= n {
+		return nil, nil
+	}
+	top, challengers := members[:n], members[n:]
+	leader := top[0].Rating
+
+	updates := make([]repository.LeaderboardMember, 0, tick.Count)
+	for len(updates) < tick.Count {
+		if len(updates)%2 == 0 {
+			// A challenger lands somewhere inside the top n.
+			c := challengers[tick.Rand.Intn(len(challengers))]
+			target := top[tick.Rand.Intn(len(top))].Rating
+			c.Rating = target + tick.Rand.Intn(leader-target+2)
+			updates = append(updates, c)
+		} else {
+			m := top[tick.Rand.Intn(len(top))]
+			m.Rating -= 1 + tick.Rand.Intn(drop)
+			updates = append(updates, m)
+		}
+	}
+	return updates, nil
+}
+
+// matchStatsPlayers bounds how many players the match scenario measures,
+// keeping the per-tick cost flat on large boards.
+const matchStatsPlayers = 10000
+
+// matchScenario plays matches between users of similar rating. Every user
+// gets a hidden skill when the run starts, or on first sight if they join
+// later; the outcome is drawn from the Elo expectation of the two skills
+// and ratings move by the Elo update, so the ratings should converge on the
+// skills.
+type matchScenario struct {
+	params map[string]float64
+	elo    entity.Elo
+	skills map[uuid.UUID]float64
+	// tracked lists the first matchStatsPlayers users to play, whose
+	// latest ratings are kept in ratings.
+	tracked []uuid.UUID
+	ratings map[uuid.UUID]int
+
+	mu      sync.Mutex
+	matches int64
+	stats   map[string]float64
+}
+
+func (s *matchScenario) Name() string               { return "matches" }
+func (s *matchScenario) Params() map[string]float64 { return s.params }
+
+func (s *matchScenario) skill(tick *SimulationTick, userID uuid.UUID) float64 {
+	skill, ok := s.skills[userID]
+	if !ok {
+		skill = float64(tick.Ratings.Default) + tick.Rand.NormFloat64()*s.params["skill_spread"]
+		s.skills[userID] = skill
+	}
+	return skill
+}
+
+func (s *matchScenario) Tick(ctx context.Context, tick *SimulationTick) ([]repository.LeaderboardMember, error) {
+	if s.ratings == nil {
+		s.ratings = make(map[uuid.UUID]int)
+		tick.Each(func(userID uuid.UUID) { s.skill(tick, userID) })
+	}
+
+	matches := tick.Count / 2
+	if matches < 1 {
+		matches = 1
+	}
+	window := int(s.params["window"])
+
+	seekers, err := tick.Sample(ctx, matches)
+	if err != nil {
+		return nil, err
+	}
+
+	played := make(map[uuid.UUID]bool)
+	updates := make([]repository.LeaderboardMember, 0, 2*matches)
+	for _, seeker := range seekers {
+		if played[seeker.UserID] {
+			continue
+		}
+		neighbors, err := tick.Neighbors(ctx, seeker.UserID, window)
+		if err != nil {
+			return nil, err
+		}
+
+		var player *repository.LeaderboardMember
+		opponents := make([]repository.LeaderboardMember, 0, len(neighbors))
+		for i, m := range neighbors {
+			switch {
+			case m.UserID == seeker.UserID:
+				player = &neighbors[i]
+			case !played[m.UserID]:
+				opponents = append(opponents, m)
+			}
+		}
+		if player == nil || len(opponents) == 0 {
+			continue
+		}
+		opponent := opponents[tick.Rand.Intn(len(opponents))]
+
+		result := entity.MatchLoss
+		if tick.Rand.Float64() < s.elo.Expected(s.skill(tick, player.UserID), s.skill(tick, opponent.UserID)) {
+			result = entity.MatchWin
+		}
+		a, b := s.elo.Play(player.Rating, opponent.Rating, result)
+
+		played[player.UserID], played[opponent.UserID] = true, true
+		updates = append(updates,
+			repository.LeaderboardMember{UserID: player.UserID, Rating: a},
+			repository.LeaderboardMember{UserID: opponent.UserID, Rating: b},
+		)
+	}
+
+	s.measure(tick, updates)
+	return updates, nil
+}
+
+// measure compares the tracked players' ratings after this tick's matches
+// with their skills.
+func (s *matchScenario) measure(tick *SimulationTick, updates []repository.LeaderboardMember) {
+	for _, u := range updates {
+		if _, ok := s.ratings[u.UserID]; !ok {
+			if len(s.tracked) == matchStatsPlayers {
+				continue
+			}
+			s.tracked = append(s.tracked, u.UserID)
+		}
+		s.ratings[u.UserID] = tick.Ratings.Clamp(u.Rating)
+	}
+
+	ratings := make([]float64, len(s.tracked))
+	skills := make([]float64, len(s.tracked))
+	var absError float64
+	for i, userID := range s.tracked {
+		ratings[i], skills[i] = float64(s.ratings[userID]), s.skills[userID]
+		absError += math.Abs(ratings[i] - skills[i])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.matches += int64(len(updates) / 2)
+	s.stats = map[string]float64{
+		"matches":           float64(s.matches),
+		"players":           float64(len(s.tracked)),
+		"skill_correlation": spearman(ratings, skills),
+		"mean_abs_error":    absError / math.Max(float64(len(s.tracked)), 1),
+	}
+}
+
+func (s *matchScenario) Stats() map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// spearman returns the rank correlation of x and y, 1 when they are in the
+// same order and 0 when there is too little data.
+func spearman(x, y []float64) float64 {
+	if len(x) < 2 {
+		return 0
+	}
+	rx, ry := fractionalRanks(x), fractionalRanks(y)
+
+	n := float64(len(x))
+	mean := (n + 1) / 2
+	var cov, vx, vy float64
+	for i := range rx {
+		dx, dy := rx[i]-mean, ry[i]-mean
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// fractionalRanks ranks values from 1, giving ties their average rank.
+func fractionalRanks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+
+	ranks := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j < len(order) && values[order[j]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			ranks[order[k]] = rank
+		}
+		i = j
+	}
+	return ranks
+}
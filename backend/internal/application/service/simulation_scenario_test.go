@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+func TestNewSimulationScenarioBounds(t *testing.T) {
+	ratings := entity.RatingRange{Min: 0, Max: 3000, Default: 1000}
+	const maxUpdates = 100
+
+	tests := []struct {
+		scenario string
+		params   map[string]float64
+		ok       bool
+	}{
+		{"uniform", map[string]float64{"max_delta": 3000}, true},
+		{"uniform", map[string]float64{"max_delta": 3001}, false},
+		{"uniform", map[string]float64{"max_delta": 5e18}, false},
+		{"uniform", map[string]float64{"max_delta": 0}, false},
+		{"climber", map[string]float64{"cohort_size": 100}, true},
+		{"climber", map[string]float64{"cohort_size": 1e12}, false},
+		{"mass_tie", map[string]float64{"buckets": 1e19}, false},
+		{"top_churn", map[string]float64{"n": 100, "drop": 3000}, true},
+		{"top_churn", map[string]float64{"n": 101}, false},
+		{"top_churn", map[string]float64{"drop": 1e19}, false},
+		{"matches", map[string]float64{"window": maxMatchWindow}, true},
+		{"matches", map[string]float64{"window": maxMatchWindow + 1}, false},
+	}
+
+	for _, tt := range tests {
+		_, err := NewSimulationScenario(tt.scenario, tt.params, ratings, maxUpdates)
+		if tt.ok && err != nil {
+			t.Errorf("%s %v: unexpected error %v", tt.scenario, tt.params, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidScenario) {
+			t.Errorf("%s %v: got %v, want ErrInvalidScenario", tt.scenario, tt.params, err)
+		}
+	}
+}
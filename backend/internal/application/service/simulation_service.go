@@ -1,121 +1,555 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"math/rand"
+	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
 	"github.com/rankq/backend/internal/domain/repository"
 )
 
+var (
+	ErrSimulationRunning    = errors.New("a simulation is already running")
+	ErrRecordingDisabled    = errors.New("recording is disabled: simulation.record_dir is not set")
+	ErrSimulationRunMissing = errors.New("simulation run not found")
+)
+
+const (
+	// simulationProgressInterval is how often a running simulation saves
+	// its counters to the run log.
+	simulationProgressInterval = 5 * time.Second
+	// simulationUsersRefreshTicks is how many ticks a running simulation
+	// samples from one list of user IDs before switching to a newer one,
+	// picking up users created since. Counting ticks rather than time keeps
+	// seeded runs reproducible.
+	simulationUsersRefreshTicks = 600
+	// simulationScanBatch is the ZSCAN count used to load the user IDs.
+	simulationScanBatch = 1000
+)
+
+// SimulationLimits bounds what callers may request when starting a
+// simulation and supplies the defaults for omitted parameters.
+type SimulationLimits struct {
+	MinInterval           time.Duration
+	DefaultInterval       time.Duration
+	DefaultUpdatesPerTick int
+	MaxUpdatesPerTick     int
+}
+
+// SimulationParams describes one simulation run.
+type SimulationParams struct {
+	Interval       time.Duration
+	UpdatesPerTick int
+	// Scenario names the SimulationScenario; empty means
+	// DefaultSimulationScenario. ScenarioParams overrides its defaults.
+	Scenario       string
+	ScenarioParams map[string]float64
+	// Seed seeds the run's random source; nil picks one from the clock.
+	Seed *int64
+	// Record writes every generated update to a file in the record
+	// directory, for Replay.
+	Record bool
+}
+
+// SimulationCluster lets the API instances of a cluster share one
+// simulation. The instance that starts a run holds the simulation lease
+// while it runs and publishes its status for the others.
+type SimulationCluster struct {
+	Leases     repository.LeaseStore
+	State      repository.SimulationStateStore
+	InstanceID string
+	LeaseTTL   time.Duration
+}
+
+const (
+	// simulationLease is the lease held by the instance running the
+	// simulation.
+	simulationLease = "simulation"
+	// simulationSyncInterval is how often a running simulation renews its
+	// lease, publishes its status and checks for stop requests.
+	simulationSyncInterval = time.Second
+)
+
 type SimulationService struct {
+	userRepo        repository.UserRepository
 	leaderboardRepo repository.LeaderboardRepository
+	usernameIndex   repository.UsernameIndex
 	scoreRepo       repository.ScoreRepository
+	runRepo         repository.SimulationRunRepository
+	ratings         entity.RatingRange
+	limits          SimulationLimits
+	recordDir       string
+	cluster         SimulationCluster
 	running         bool
+	status          entity.SimulationStatus
 	stopCh          chan struct{}
+	doneCh          chan struct{}
 	mu              sync.Mutex
 }
 
 func NewSimulationService(
+	userRepo repository.UserRepository,
 	leaderboardRepo repository.LeaderboardRepository,
+	usernameIndex repository.UsernameIndex,
 	scoreRepo repository.ScoreRepository,
+	runRepo repository.SimulationRunRepository,
+	ratings entity.RatingRange,
+	limits SimulationLimits,
+	recordDir string,
+	cluster SimulationCluster,
 ) *SimulationService {
 	return &SimulationService{
+		userRepo:        userRepo,
 		leaderboardRepo: leaderboardRepo,
+		usernameIndex:   usernameIndex,
 		scoreRepo:       scoreRepo,
+		runRepo:         runRepo,
+		ratings:         ratings,
+		limits:          limits,
+		recordDir:       recordDir,
+		cluster:         cluster,
 	}
 }
 
-func (s *SimulationService) Start(ctx context.Context, interval time.Duration, updatesPerTick int) {
+func (s *SimulationService) Limits() SimulationLimits {
+	return s.limits
+}
+
+// Start begins a simulation run on this instance and records it in the run
+// log. It returns ErrInvalidScenario for an unknown scenario or bad
+// parameters and ErrSimulationRunning if a run is active anywhere in the
+// cluster.
+func (s *SimulationService) Start(ctx context.Context, params SimulationParams) (*entity.SimulationRun, error) {
+	scenario, err := NewSimulationScenario(params.Scenario, params.ScenarioParams, s.ratings, s.limits.MaxUpdatesPerTick)
+	if err != nil {
+		return nil, err
+	}
+	if params.Record && s.recordDir == "" {
+		return nil, ErrRecordingDisabled
+	}
+
 	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.running {
-		s.mu.Unlock()
-		return
+		return nil, ErrSimulationRunning
 	}
+
+	owned, err := s.cluster.Leases.Acquire(ctx, simulationLease, s.cluster.InstanceID, s.cluster.LeaseTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrSimulationRunning
+	}
+	started := false
+	defer func() {
+		if !started {
+			s.releaseLease()
+		}
+	}()
+
+	seed := time.Now().UnixNano()
+	if params.Seed != nil {
+		seed = *params.Seed
+	}
+	run := &entity.SimulationRun{
+		Scenario:       scenario.Name(),
+		Params:         scenario.Params(),
+		IntervalMs:     int(params.Interval.Milliseconds()),
+		UpdatesPerTick: params.UpdatesPerTick,
+		Seed:           seed,
+		StartedAt:      time.Now(),
+	}
+
+	var recorder *simulationRecorder
+	if params.Record {
+		path := filepath.Join(s.recordDir, fmt.Sprintf("run-%s-%d.ndjson", run.StartedAt.UTC().Format("20060102-150405.000"), seed))
+		if recorder, err = newSimulationRecorder(path); err != nil {
+			return nil, err
+		}
+		run.RecordFile = path
+	}
+
+	if err := s.runRepo.Create(ctx, run); err != nil {
+		if recorder != nil {
+			recorder.discard()
+		}
+		return nil, err
+	}
+	if recorder != nil {
+		if err := recorder.header(run); err != nil {
+			log.Printf("simulation: failed to write record header: %v", err)
+		}
+	}
+
+	monitor := newSimulationMonitor(run)
+	s.status = s.snapshot(run, scenario, monitor)
+	s.saveStatus(s.status)
+
 	s.running = true
 	s.stopCh = make(chan struct{})
-	s.mu.Unlock()
+	s.doneCh = make(chan struct{})
+	started = true
+
+	log.Printf("simulation: starting run %d, scenario %s with interval=%v, updatesPerTick=%d, seed=%d",
+		run.ID, scenario.Name(), params.Interval, params.UpdatesPerTick, seed)
+	snapshot := *run
+	go s.run(context.Background(), run, scenario, recorder, monitor, s.stopCh, s.doneCh)
 
-	log.Printf("simulation: starting with interval=%v, updatesPerTick=%d", interval, updatesPerTick)
-	go s.run(context.Background(), interval, updatesPerTick)
+	return &snapshot, nil
 }
 
+// Stop ends the run active on this instance, if any, and waits until its
+// final state is recorded.
 func (s *SimulationService) Stop() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	if !s.running {
+		s.mu.Unlock()
 		return
 	}
-
 	close(s.stopCh)
 	s.running = false
+	done := s.doneCh
+	s.mu.Unlock()
+
+	<-done
 }
 
+// StopCluster ends the run active anywhere in the cluster. A run on
+// another instance is asked to stop and waited for until ctx is done.
+func (s *SimulationService) StopCluster(ctx context.Context) error {
+	if s.IsRunning() {
+		s.Stop()
+		return nil
+	}
+
+	holder, err := s.cluster.Leases.Holder(ctx, simulationLease)
+	if err != nil || holder == "" {
+		return err
+	}
+	status, err := s.cluster.State.GetStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if status == nil || status.Run == nil {
+		return nil
+	}
+	if err := s.cluster.State.RequestStop(ctx, status.Run.ID); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if holder, err := s.cluster.Leases.Holder(ctx, simulationLease); err != nil || holder == "" {
+			return err
+		}
+	}
+}
+
+// IsRunning reports whether a run is active on this instance.
 func (s *SimulationService) IsRunning() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	return s.running
 }
 
-func (s *SimulationService) run(ctx context.Context, interval time.Duration, updatesPerTick int) {
-	ticker := time.NewTicker(interval)
+func (s *SimulationService) GetRun(ctx context.Context, id int64) (*entity.SimulationRun, error) {
+	run, err := s.runRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrSimulationRunMissing
+	}
+	return run, nil
+}
+
+func (s *SimulationService) ListRuns(ctx context.Context, page, pageSize int) ([]*entity.SimulationRun, int64, error) {
+	runs, total, err := s.runRepo.List(ctx, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if runs == nil {
+		runs = []*entity.SimulationRun{}
+	}
+	return runs, total, nil
+}
+
+func (s *SimulationService) run(ctx context.Context, run *entity.SimulationRun, scenario SimulationScenario, recorder *simulationRecorder, monitor *simulationMonitor, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	// The first list is loaded before the first tick. Every
+	// simulationUsersRefreshTicks ticks, a reload starts in the background
+	// and the one started the previous time is swapped in, so ticks never
+	// wait for a scan of the board unless one takes that many ticks.
+	users, err := s.loadUsers(ctx)
+	if err != nil {
+		log.Printf("simulation: failed to load users: %v", err)
+		run.Errors++
+		run.LastError = err.Error()
+	}
+	var reload <-chan usersLoad
+
+	ticker := time.NewTicker(time.Duration(run.IntervalMs) * time.Millisecond)
 	defer ticker.Stop()
+	syncTicker := time.NewTicker(simulationSyncInterval)
+	defer syncTicker.Stop()
+
+	rng := rand.New(rand.NewSource(run.Seed))
+	lastSaved := time.Now()
+	leaseRenewed := time.Now()
+
+	defer func() {
+		now := time.Now()
+		run.StoppedAt = &now
+		s.saveProgress(run)
+		status := s.snapshot(run, scenario, monitor)
+		status.Running = false
+		status.UpdatesPerSecond = 0
+
+		s.mu.Lock()
+		s.status = status
+		if s.stopCh == stopCh {
+			s.running = false
+		}
+		s.mu.Unlock()
+
+		s.saveStatus(status)
+		s.releaseLease()
+		if recorder != nil {
+			if err := recorder.close(); err != nil {
+				log.Printf("simulation: failed to close record file: %v", err)
+			}
+		}
+		log.Printf("simulation: run %d stopped after %d ticks, %d updates, %d errors", run.ID, run.Ticks, run.Updates, run.Errors)
+	}()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-s.stopCh:
+		case <-stopCh:
 			return
+		case <-syncTicker.C:
+			if !s.sync(ctx, run, &leaseRenewed) {
+				return
+			}
 		case <-ticker.C:
-			s.performUpdates(ctx, updatesPerTick)
+			run.Ticks++
+			if run.Ticks%simulationUsersRefreshTicks == 0 {
+				if reload != nil {
+					if loaded := <-reload; loaded.err != nil {
+						log.Printf("simulation: failed to load users: %v", loaded.err)
+						run.Errors++
+						run.LastError = loaded.err.Error()
+					} else {
+						users = loaded.users
+					}
+				}
+				reload = s.loadUsersAsync(ctx)
+			}
+			s.performUpdates(ctx, run, scenario, recorder, &SimulationTick{
+				Count:           run.UpdatesPerTick,
+				Ratings:         s.ratings,
+				Rand:            rng,
+				users:           users,
+				leaderboardRepo: s.leaderboardRepo,
+			})
+			monitor.observe(ctx, s, run)
+			s.publishStatus(s.snapshot(run, scenario, monitor))
+			if time.Since(lastSaved) >= simulationProgressInterval {
+				s.saveProgress(run)
+				lastSaved = time.Now()
+			}
 		}
 	}
 }
 
-func (s *SimulationService) performUpdates(ctx context.Context, count int) {
-	scores, err := s.scoreRepo.GetAll(ctx)
+// sync renews the simulation lease, shares the status with the cluster and
+// checks for a stop request. It reports whether the run should go on.
+func (s *SimulationService) sync(ctx context.Context, run *entity.SimulationRun, leaseRenewed *time.Time) bool {
+	owned, err := s.cluster.Leases.Acquire(ctx, simulationLease, s.cluster.InstanceID, s.cluster.LeaseTTL)
+	switch {
+	case err != nil:
+		log.Printf("simulation: failed to renew lease: %v", err)
+		// Stop before the lease could expire and let a second run start.
+		if time.Since(*leaseRenewed) >= s.cluster.LeaseTTL*2/3 {
+			log.Printf("simulation: stopping run %d, its lease could not be renewed", run.ID)
+			return false
+		}
+	case !owned:
+		log.Printf("simulation: stopping run %d, its lease was lost", run.ID)
+		return false
+	default:
+		*leaseRenewed = time.Now()
+	}
+
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+	s.saveStatus(status)
+
+	stop, err := s.cluster.State.StopRequested(ctx, run.ID)
 	if err != nil {
-		log.Printf("simulation: failed to get scores: %v", err)
-		return
+		log.Printf("simulation: failed to check for a stop request: %v", err)
+		return true
 	}
+	if stop {
+		log.Printf("simulation: run %d stopped on request from another instance", run.ID)
+	}
+	return !stop
+}
 
-	if len(scores) == 0 {
-		return
+func (s *SimulationService) releaseLease() {
+	if err := s.cluster.Leases.Release(context.Background(), simulationLease, s.cluster.InstanceID); err != nil {
+		log.Printf("simulation: failed to release lease: %v", err)
 	}
+}
 
-	for i := 0; i < count && i < len(scores); i++ {
-		idx := rand.Intn(len(scores))
-		score := scores[idx]
+type usersLoad struct {
+	users []uuid.UUID
+	err   error
+}
 
-		delta := rand.Intn(201) - 100
-		if score.Rating > 4000 {
-			delta = delta - 50
-		} else if score.Rating < 500 {
-			delta = delta + 50
-		}
-		newRating := score.Rating + delta
+// loadUsersAsync runs loadUsers in the background and delivers the result
+// on the returned channel.
+func (s *SimulationService) loadUsersAsync(ctx context.Context) <-chan usersLoad {
+	ch := make(chan usersLoad, 1)
+	go func() {
+		users, err := s.loadUsers(ctx)
+		ch <- usersLoad{users: users, err: err}
+	}()
+	return ch
+}
 
-		if newRating < 100 {
-			newRating = 100
+// loadUsers returns the IDs of every user on the leaderboard, sorted so
+// that sampling depends only on the seed.
+func (s *SimulationService) loadUsers(ctx context.Context) ([]uuid.UUID, error) {
+	var users []uuid.UUID
+	var cursor uint64
+	for {
+		members, next, err := s.leaderboardRepo.ScanMembers(ctx, cursor, simulationScanBatch)
+		if err != nil {
+			return nil, err
 		}
-		if newRating > 5000 {
-			newRating = 5000
+		for _, m := range members {
+			users = append(users, m.UserID)
 		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	// ZSCAN may return a member more than once.
+	slices.SortFunc(users, func(a, b uuid.UUID) int { return bytes.Compare(a[:], b[:]) })
+	return slices.Compact(users), nil
+}
+
+func (s *SimulationService) saveProgress(run *entity.SimulationRun) {
+	if err := s.runRepo.UpdateProgress(context.Background(), run); err != nil {
+		log.Printf("simulation: failed to save run %d: %v", run.ID, err)
+	}
+}
+
+func (s *SimulationService) performUpdates(ctx context.Context, run *entity.SimulationRun, scenario SimulationScenario, recorder *simulationRecorder, tick *SimulationTick) {
+	fail := func(err error, n int) {
+		run.Errors += int64(n)
+		run.LastError = err.Error()
+	}
 
+	updates, err := scenario.Tick(ctx, tick)
+	if err != nil {
+		log.Printf("simulation: scenario %s failed: %v", scenario.Name(), err)
+		fail(err, 1)
+		return
+	}
+	updates = s.prepareUpdates(updates)
+	if len(updates) == 0 {
+		return
+	}
+
+	if recorder != nil {
+		if err := s.record(ctx, recorder, run.Ticks, updates); err != nil {
+			log.Printf("simulation: failed to record tick %d: %v", run.Ticks, err)
+			fail(err, 1)
+		}
+	}
 
-		if err := s.leaderboardRepo.UpdateScore(ctx, score.UserID, newRating); err != nil {
-			log.Printf("simulation: failed to update redis: %v", err)
+	if err := s.applyUpdates(ctx, updates); err != nil {
+		fail(err, len(updates))
+		return
+	}
+	run.Updates += int64(len(updates))
+}
+
+// prepareUpdates clamps the ratings of a tick and keeps only the last
+// update of each user, in the order users first appear.
+func (s *SimulationService) prepareUpdates(updates []repository.LeaderboardMember) []repository.LeaderboardMember {
+	index := make(map[uuid.UUID]int, len(updates))
+	prepared := make([]repository.LeaderboardMember, 0, len(updates))
+	for _, u := range updates {
+		u.Rating = s.ratings.Clamp(u.Rating)
+		if i, ok := index[u.UserID]; ok {
+			prepared[i] = u
 			continue
 		}
+		index[u.UserID] = len(prepared)
+		prepared = append(prepared, u)
+	}
+	return prepared
+}
+
+// applyUpdates writes the simulated ratings of one tick to Redis in one
+// script call and to Postgres in one statement.
+func (s *SimulationService) applyUpdates(ctx context.Context, updates []repository.LeaderboardMember) error {
+	if err := s.leaderboardRepo.UpdateScores(ctx, updates, entity.ScoreChangeSimulation); err != nil {
+		log.Printf("simulation: failed to update redis: %v", err)
+		return err
+	}
 
-		score.Rating = newRating
-		score.UpdatedAt = time.Now()
-		if err := s.scoreRepo.Upsert(ctx, score); err != nil {
-			log.Printf("simulation: failed to update postgres: %v", err)
+	if err := s.scoreRepo.UpsertMany(ctx, updates, time.Now()); err != nil {
+		log.Printf("simulation: failed to update postgres: %v", err)
+		return err
+	}
+	return nil
+}
+
+// record writes the updates of a tick with their usernames, which Replay
+// needs to recreate the users elsewhere.
+func (s *SimulationService) record(ctx context.Context, recorder *simulationRecorder, tick int64, updates []repository.LeaderboardMember) error {
+	userIDs := make([]uuid.UUID, len(updates))
+	for i, u := range updates {
+		userIDs[i] = u.UserID
+	}
+	users, err := s.userRepo.GetByIDs(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		username := ""
+		if user, ok := users[u.UserID]; ok {
+			username = user.Username
+		}
+		if err := recorder.update(tick, u.UserID, username, u.Rating); err != nil {
+			return err
 		}
 	}
+	return recorder.flush()
 }
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+const (
+	// simulationChurnTop is how many leading positions churn is counted on.
+	simulationChurnTop = 100
+	// simulationRateWindow is the window updates per second are averaged
+	// over.
+	simulationRateWindow = 10 * time.Second
+)
+
+// simulationMonitor derives the live status of a run. It is only used by
+// the run's goroutine, which publishes its snapshots to the service.
+type simulationMonitor struct {
+	rates  []rateSample
+	top    []uuid.UUID
+	churn  entity.SimulationChurn
+	hasTop bool
+	perSec float64
+}
+
+type rateSample struct {
+	at      time.Time
+	updates int64
+}
+
+func newSimulationMonitor(run *entity.SimulationRun) *simulationMonitor {
+	return &simulationMonitor{
+		rates: []rateSample{{at: run.StartedAt, updates: 0}},
+		churn: entity.SimulationChurn{TopN: simulationChurnTop},
+	}
+}
+
+// observe records the state after a tick.
+func (m *simulationMonitor) observe(ctx context.Context, s *SimulationService, run *entity.SimulationRun) {
+	now := time.Now()
+	m.rates = append(m.rates, rateSample{at: now, updates: run.Updates})
+	// Keep one sample older than the window as its baseline.
+	for len(m.rates) > 2 && now.Sub(m.rates[1].at) >= simulationRateWindow {
+		m.rates = m.rates[1:]
+	}
+	first, last := m.rates[0], m.rates[len(m.rates)-1]
+	if elapsed := last.at.Sub(first.at).Seconds(); elapsed > 0 {
+		m.perSec = float64(last.updates-first.updates) / elapsed
+	}
+
+	top, err := s.leaderboardRepo.GetTopUsers(ctx, 0, simulationChurnTop-1)
+	if err != nil {
+		log.Printf("simulation: failed to read the top %d: %v", simulationChurnTop, err)
+		return
+	}
+	ids := make([]uuid.UUID, len(top))
+	for i, member := range top {
+		ids[i] = member.UserID
+	}
+
+	if m.hasTop {
+		previous := make(map[uuid.UUID]bool, len(m.top))
+		for _, id := range m.top {
+			previous[id] = true
+		}
+		m.churn.LastTick, m.churn.Entered = 0, 0
+		for i, id := range ids {
+			if i >= len(m.top) || m.top[i] != id {
+				m.churn.LastTick++
+				if !previous[id] {
+					m.churn.Entered++
+				}
+			}
+		}
+		m.churn.Total += int64(m.churn.LastTick)
+	}
+	m.top, m.hasTop = ids, true
+}
+
+// snapshot builds the status of run, which must be called from the run's
+// goroutine or before it starts.
+func (s *SimulationService) snapshot(run *entity.SimulationRun, scenario SimulationScenario, m *simulationMonitor) entity.SimulationStatus {
+	snapshot := *run
+	churn := m.churn
+	status := entity.SimulationStatus{
+		Running:          true,
+		Instance:         s.cluster.InstanceID,
+		Run:              &snapshot,
+		UpdatesPerSecond: m.perSec,
+		Churn:            &churn,
+		UpdatedAt:        time.Now(),
+	}
+	if stats, ok := scenario.(SimulationScenarioStats); ok {
+		status.Stats = stats.Stats()
+	}
+	return status
+}
+
+// publishStatus makes status the one Status reports on this instance.
+func (s *SimulationService) publishStatus(status entity.SimulationStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// saveStatus shares status with the other instances.
+func (s *SimulationService) saveStatus(status entity.SimulationStatus) {
+	if err := s.cluster.State.SaveStatus(context.Background(), &status); err != nil {
+		log.Printf("simulation: failed to save status: %v", err)
+	}
+}
+
+// Status returns the status of the simulation running in the cluster, or
+// of the most recent one after it stopped. The instance running it answers
+// from memory; the others read the status it last shared, at most
+// simulationSyncInterval old.
+func (s *SimulationService) Status(ctx context.Context) (entity.SimulationStatus, error) {
+	s.mu.Lock()
+	status, running := s.status, s.running
+	s.mu.Unlock()
+	if running {
+		return status, nil
+	}
+
+	shared, err := s.cluster.State.GetStatus(ctx)
+	if err != nil {
+		return status, err
+	}
+	if shared == nil {
+		return entity.SimulationStatus{UpdatedAt: time.Now()}, nil
+	}
+
+	// A run whose instance died keeps its last status; the expired lease
+	// tells that it is no longer running.
+	holder, err := s.cluster.Leases.Holder(ctx, simulationLease)
+	if err != nil {
+		return *shared, err
+	}
+	shared.Running = holder != "" && shared.Instance == holder
+	return *shared, nil
+}
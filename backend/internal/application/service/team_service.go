@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+var (
+	ErrTeamExists    = errors.New("team already exists")
+	ErrTeamNotFound  = errors.New("team not found")
+	ErrAlreadyInTeam = errors.New("user already belongs to a team")
+	ErrNotTeamMember = errors.New("user is not a member of this team")
+)
+
+// TeamService manages teams and the team leaderboard. Team scores are kept
+// current by the leaderboard scripts on every member score update; this
+// service only changes membership and reads the board.
+type TeamService struct {
+	teamRepo        repository.TeamRepository
+	userRepo        repository.UserRepository
+	leaderboardRepo repository.LeaderboardRepository
+	teamBoardRepo   repository.TeamLeaderboardRepository
+	aggregate       entity.TeamAggregate
+}
+
+func NewTeamService(
+	teamRepo repository.TeamRepository,
+	userRepo repository.UserRepository,
+	leaderboardRepo repository.LeaderboardRepository,
+	teamBoardRepo repository.TeamLeaderboardRepository,
+	aggregate entity.TeamAggregate,
+) *TeamService {
+	return &TeamService{
+		teamRepo:        teamRepo,
+		userRepo:        userRepo,
+		leaderboardRepo: leaderboardRepo,
+		teamBoardRepo:   teamBoardRepo,
+		aggregate:       aggregate,
+	}
+}
+
+func (s *TeamService) CreateTeam(ctx context.Context, name string) (*entity.Team, error) {
+	existing, err := s.teamRepo.GetByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrTeamExists
+	}
+
+	team := entity.NewTeam(name)
+	if err := s.teamRepo.Create(ctx, team); err != nil {
+		return nil, err
+	}
+
+	if err := s.teamBoardRepo.SyncTeam(ctx, team.ID, nil); err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+func (s *TeamService) GetTeam(ctx context.Context, teamID uuid.UUID) (*entity.TeamSummary, error) {
+	team, err := s.teamRepo.GetByID(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, ErrTeamNotFound
+	}
+	return s.summarize(ctx, team)
+}
+
+// GetUserTeam returns the team userID belongs to, or nil if they have none.
+func (s *TeamService) GetUserTeam(ctx context.Context, userID uuid.UUID) (*entity.TeamSummary, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	team, err := s.teamRepo.GetByUserID(ctx, userID)
+	if err != nil || team == nil {
+		return nil, err
+	}
+	return s.summarize(ctx, team)
+}
+
+func (s *TeamService) summarize(ctx context.Context, team *entity.Team) (*entity.TeamSummary, error) {
+	score, _, err := s.teamBoardRepo.GetTeamScore(ctx, team.ID)
+	if err != nil {
+		return nil, err
+	}
+	rank, err := s.teamBoardRepo.GetTeamRank(ctx, score)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.teamBoardRepo.GetMemberCounts(ctx, []uuid.UUID{team.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.TeamSummary{
+		ID:          team.ID,
+		Name:        team.Name,
+		CreatedAt:   team.CreatedAt,
+		Rank:        rank,
+		Score:       roundScore(score),
+		MemberCount: counts[team.ID],
+		Aggregate:   s.aggregate.String(),
+	}, nil
+}
+
+func (s *TeamService) GetTeamLeaderboard(ctx context.Context, page, pageSize int) ([]entity.TeamLeaderboardEntry, int64, error) {
+	start := int64((page - 1) * pageSize)
+	stop := start + int64(pageSize) - 1
+
+	standings, err := s.teamBoardRepo.GetTopTeams(ctx, start, stop)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.teamBoardRepo.GetTotalTeams(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(standings) == 0 {
+		return []entity.TeamLeaderboardEntry{}, total, nil
+	}
+
+	teamIDs := make([]uuid.UUID, len(standings))
+	for i, st := range standings {
+		teamIDs[i] = st.TeamID
+	}
+
+	teams, err := s.teamRepo.GetByIDs(ctx, teamIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	counts, err := s.teamBoardRepo.GetMemberCounts(ctx, teamIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Only the first team on the page can tie with teams on an earlier page;
+	// every later rank follows from its position.
+	rank, err := s.teamBoardRepo.GetTeamRank(ctx, standings[0].Score)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]entity.TeamLeaderboardEntry, 0, len(standings))
+	for i, st := range standings {
+		if i > 0 && st.Score != standings[i-1].Score {
+			rank = start + int64(i) + 1
+		}
+
+		team, ok := teams[st.TeamID]
+		if !ok {
+			continue
+		}
+
+		entries = append(entries, entity.TeamLeaderboardEntry{
+			Rank:        rank,
+			Name:        team.Name,
+			Score:       roundScore(st.Score),
+			MemberCount: counts[st.TeamID],
+			TeamID:      st.TeamID.String(),
+		})
+	}
+
+	return entries, total, nil
+}
+
+// GetTeamMembers lists the members of teamID by rating, with their rank in
+// the team and on the global leaderboard and whether they count towards the
+// team score.
+func (s *TeamService) GetTeamMembers(ctx context.Context, teamID uuid.UUID) ([]entity.TeamMemberEntry, error) {
+	team, err := s.teamRepo.GetByID(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, ErrTeamNotFound
+	}
+
+	members, err := s.teamRepo.ListMembers(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	rated, err := s.teamBoardRepo.GetMembers(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]*entity.TeamMember, len(members))
+	for _, m := range members {
+		byID[m.UserID] = m
+	}
+
+	ratings := make([]int, 0, len(rated))
+	for _, m := range rated {
+		ratings = append(ratings, m.Rating)
+	}
+	globalRanks, err := s.leaderboardRepo.GetRanks(ctx, ratings)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entity.TeamMemberEntry, 0, len(rated))
+	for i, m := range rated {
+		member, ok := byID[m.UserID]
+		if !ok {
+			continue
+		}
+
+		var teamRank int64
+		if i > 0 && m.Rating == rated[i-1].Rating {
+			teamRank = entries[len(entries)-1].TeamRank
+		} else {
+			teamRank = int64(i + 1)
+		}
+
+		entries = append(entries, entity.TeamMemberEntry{
+			TeamRank:   teamRank,
+			GlobalRank: globalRanks[m.Rating],
+			Username:   member.Username,
+			Rating:     m.Rating,
+			UserID:     m.UserID.String(),
+			Counted:    s.aggregate.Counts(i),
+			JoinedAt:   member.JoinedAt,
+		})
+	}
+
+	return entries, nil
+}
+
+func (s *TeamService) JoinTeam(ctx context.Context, teamID, userID uuid.UUID) error {
+	team, err := s.teamRepo.GetByID(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	if team == nil {
+		return ErrTeamNotFound
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	current, err := s.teamRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		return ErrAlreadyInTeam
+	}
+
+	// The check above is only a fast path: a concurrent join can still win
+	// the insert.
+	added, err := s.teamRepo.AddMember(ctx, teamID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !added {
+		return ErrAlreadyInTeam
+	}
+
+	return s.teamBoardRepo.AddMember(ctx, teamID, userID)
+}
+
+func (s *TeamService) LeaveTeam(ctx context.Context, teamID, userID uuid.UUID) error {
+	removed, err := s.teamRepo.RemoveMember(ctx, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNotTeamMember
+	}
+
+	return s.teamBoardRepo.RemoveMember(ctx, teamID, userID)
+}
+
+// SyncAll rebuilds every team from Postgres membership and the live user
+// leaderboard, then records the aggregate it used.
+func (s *TeamService) SyncAll(ctx context.Context) error {
+	err := s.teamRepo.StreamMemberships(ctx, func(teamID uuid.UUID, userIDs []uuid.UUID) error {
+		return s.teamBoardRepo.SyncTeam(ctx, teamID, userIDs)
+	})
+	if err != nil {
+		return err
+	}
+	return s.teamBoardRepo.SetAggregate(ctx, s.aggregate.String())
+}
+
+// SyncIfStale runs SyncAll when the team board was never synced or was built
+// with a different aggregate, e.g. after a Redis restart or a config change.
+// It reports whether a sync ran.
+func (s *TeamService) SyncIfStale(ctx context.Context) (bool, error) {
+	current, err := s.teamBoardRepo.GetAggregate(ctx)
+	if err != nil || current == s.aggregate.String() {
+		return false, err
+	}
+
+	log.Printf("teams: syncing team leaderboard (aggregate %q, was %q)", s.aggregate, current)
+	if err := s.SyncAll(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// roundScore trims averages to two decimals for display.
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
@@ -19,20 +19,36 @@ type UserService struct {
 	userRepo        repository.UserRepository
 	scoreRepo       repository.ScoreRepository
 	leaderboardRepo repository.LeaderboardRepository
+	teamRepo        repository.TeamRepository
+	teamBoardRepo   repository.TeamLeaderboardRepository
+	usernameIndex   repository.UsernameIndex
+	ratings         entity.RatingRange
 }
 
 func NewUserService(
 	userRepo repository.UserRepository,
 	scoreRepo repository.ScoreRepository,
 	leaderboardRepo repository.LeaderboardRepository,
+	teamRepo repository.TeamRepository,
+	teamBoardRepo repository.TeamLeaderboardRepository,
+	usernameIndex repository.UsernameIndex,
+	ratings entity.RatingRange,
 ) *UserService {
 	return &UserService{
 		userRepo:        userRepo,
 		scoreRepo:       scoreRepo,
 		leaderboardRepo: leaderboardRepo,
+		teamRepo:        teamRepo,
+		teamBoardRepo:   teamBoardRepo,
+		usernameIndex:   usernameIndex,
+		ratings:         ratings,
 	}
 }
 
+func (s *UserService) Ratings() entity.RatingRange {
+	return s.ratings
+}
+
 func (s *UserService) CreateUser(ctx context.Context, username string, initialRating int) (*entity.User, error) {
 	existing, err := s.userRepo.GetByUsername(ctx, username)
 	if err != nil {
@@ -47,12 +63,7 @@ func (s *UserService) CreateUser(ctx context.Context, username string, initialRa
 		return nil, err
 	}
 
-	if initialRating < 100 {
-		initialRating = 100
-	}
-	if initialRating > 5000 {
-		initialRating = 5000
-	}
+	initialRating = s.ratings.Clamp(initialRating)
 
 	score := &entity.UserScore{
 		UserID:    user.ID,
@@ -64,7 +75,12 @@ func (s *UserService) CreateUser(ctx context.Context, username string, initialRa
 		return nil, err
 	}
 
-	if err := s.leaderboardRepo.UpdateScore(ctx, user.ID, initialRating); err != nil {
+	if err := s.leaderboardRepo.UpdateScore(ctx, user.ID, initialRating, entity.ScoreChangeCreate); err != nil {
+		return nil, err
+	}
+
+	entry := repository.UsernameEntry{UserID: user.ID, Username: user.Username}
+	if err := s.usernameIndex.Add(ctx, []repository.UsernameEntry{entry}); err != nil {
 		return nil, err
 	}
 
@@ -82,3 +98,107 @@ func (s *UserService) ListUsers(ctx context.Context, limit, offset int) ([]*enti
 func (s *UserService) GetTotalUsers(ctx context.Context) (int64, error) {
 	return s.userRepo.Count(ctx)
 }
+
+// RenameUser changes a user's username and moves its autocomplete entry.
+func (s *UserService) RenameUser(ctx context.Context, id uuid.UUID, username string) (*entity.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Username == username {
+		return user, nil
+	}
+
+	existing, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrUserExists
+	}
+
+	renamed, err := s.userRepo.Rename(ctx, id, username)
+	if errors.Is(err, repository.ErrUsernameTaken) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !renamed {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.usernameIndex.Rename(ctx, id, user.Username, username); err != nil {
+		return nil, err
+	}
+
+	user.Username = username
+	return user, nil
+}
+
+// DeleteUser removes a user from Postgres, then from the leaderboard, its
+// team's score and the username index. If the Redis cleanup fails,
+// reconciliation removes the leftover leaderboard entries later; a leftover
+// username entry is ignored by autocomplete since the user has no rank.
+func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	team, err := s.teamRepo.GetByUserID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.userRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrUserNotFound
+	}
+
+	if team != nil {
+		if err := s.teamBoardRepo.RemoveMember(ctx, team.ID, id); err != nil {
+			return err
+		}
+	}
+	if err := s.leaderboardRepo.RemoveUser(ctx, id); err != nil {
+		return err
+	}
+	return s.usernameIndex.Remove(ctx, id, user.Username)
+}
+
+// IndexUsernamesIfEmpty fills the username index from Postgres when Redis
+// holds none, e.g. on first start after upgrading or after a Redis restart
+// without persistence. It reports whether it ran.
+func (s *UserService) IndexUsernamesIfEmpty(ctx context.Context) (bool, error) {
+	total, err := s.usernameIndex.Count(ctx)
+	if err != nil || total > 0 {
+		return false, err
+	}
+	return true, indexUsernames(ctx, s.userRepo, s.usernameIndex)
+}
+
+// usernameIndexBatchSize is how many users indexUsernames reads and adds at
+// a time.
+const usernameIndexBatchSize = 1000
+
+// indexUsernames adds every user in Postgres to the index. Entries already
+// there are kept, so it is safe to run alongside user writes.
+func indexUsernames(ctx context.Context, userRepo repository.UserRepository, index repository.UsernameIndex) error {
+	return userRepo.StreamAll(ctx, usernameIndexBatchSize, func(batch []*entity.User) error {
+		entries := make([]repository.UsernameEntry, len(batch))
+		for i, user := range batch {
+			entries[i] = repository.UsernameEntry{UserID: user.ID, Username: user.Username}
+		}
+		return index.Add(ctx, entries)
+	})
+}
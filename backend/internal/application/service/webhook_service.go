@@ -0,0 +1,577 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// Delivery request headers. The signature is "t=<unix>,v1=<hex>", where hex
+// is the HMAC-SHA256 of "<unix>.<body>" keyed with the webhook secret.
+const (
+	WebhookEventHeader     = "X-RankQ-Event"
+	WebhookDeliveryHeader  = "X-RankQ-Delivery"
+	WebhookSignatureHeader = "X-RankQ-Signature"
+)
+
+const (
+	// webhookCacheTTL bounds how long a subscription created on another
+	// instance goes unnoticed.
+	webhookCacheTTL = 30 * time.Second
+	// maxDisplacedUsers caps the users reported as pushed into or out of
+	// the top N by a single update.
+	maxDisplacedUsers = 100
+)
+
+type WebhookOptions struct {
+	MaxAttempts  int
+	Backoff      time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	PollInterval time.Duration
+	Workers      int
+}
+
+// WebhookService turns client score updates into webhook deliveries and
+// sends them. Events are detected on the request path and queued in
+// Postgres; a background dispatcher claims due deliveries, so any instance
+// may send them, and retries failures with exponential backoff.
+type WebhookService struct {
+	webhookRepo     repository.WebhookRepository
+	leaderboardRepo repository.LeaderboardRepository
+	client          *http.Client
+	opts            WebhookOptions
+
+	cacheMu  sync.Mutex
+	hooks    []*entity.Webhook
+	loadedAt time.Time
+
+	wake   chan struct{}
+	mu     sync.Mutex
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+func NewWebhookService(
+	webhookRepo repository.WebhookRepository,
+	leaderboardRepo repository.LeaderboardRepository,
+	opts WebhookOptions,
+) *WebhookService {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 8
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 10 * time.Second
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = opts.Backoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 4
+	}
+	return &WebhookService{
+		webhookRepo:     webhookRepo,
+		leaderboardRepo: leaderboardRepo,
+		client:          &http.Client{Timeout: opts.Timeout},
+		opts:            opts,
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+// CreateWebhook stores a subscription. An empty secret is replaced by a
+// random one.
+func (s *WebhookService) CreateWebhook(ctx context.Context, url, secret string, events []string, topN int, thresholds []int) (*entity.Webhook, error) {
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		secret = "whsec_" + hex.EncodeToString(buf)
+	}
+
+	webhook := entity.NewWebhook(url, secret, events, topN, thresholds)
+	if err := s.webhookRepo.Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return webhook, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]*entity.Webhook, error) {
+	webhooks, err := s.webhookRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = []*entity.Webhook{}
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id uuid.UUID) (*entity.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	deleted, err := s.webhookRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, page, pageSize int) ([]*entity.WebhookDelivery, int64, error) {
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return nil, 0, err
+	}
+
+	deliveries, total, err := s.webhookRepo.ListDeliveries(ctx, webhookID, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if deliveries == nil {
+		deliveries = []*entity.WebhookDelivery{}
+	}
+	return deliveries, total, nil
+}
+
+func (s *WebhookService) GetDelivery(ctx context.Context, webhookID uuid.UUID, deliveryID int64) (*entity.WebhookDelivery, error) {
+	delivery, err := s.webhookRepo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil || delivery.WebhookID != webhookID {
+		return nil, ErrDeliveryNotFound
+	}
+	return delivery, nil
+}
+
+// Redeliver queues a new delivery with the payload of an earlier one,
+// whatever its outcome. The original stays in the log unchanged.
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID uuid.UUID, deliveryID int64) (*entity.WebhookDelivery, error) {
+	original, err := s.GetDelivery(ctx, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := entity.NewWebhookDelivery(webhookID, original.Event, original.Payload)
+	delivery.RedeliveryOf = &original.ID
+	if err := s.webhookRepo.CreateDeliveries(ctx, []*entity.WebhookDelivery{delivery}); err != nil {
+		return nil, err
+	}
+	s.notify()
+	return delivery, nil
+}
+
+// ScoreChanged implements ScoreListener. Failures are logged rather than
+// returned, since the score update itself has already been applied.
+func (s *WebhookService) ScoreChanged(ctx context.Context, event *entity.ScoreEvent) {
+	hooks, err := s.subscriptions(ctx)
+	if err != nil {
+		log.Printf("webhooks: failed to load subscriptions: %v", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	var deliveries []*entity.WebhookDelivery
+	topN := make(map[int][]entity.WebhookEvent)
+	for _, hook := range hooks {
+		var events []entity.WebhookEvent
+
+		if hook.Wants(entity.WebhookTopNEntered) || hook.Wants(entity.WebhookTopNLeft) {
+			changes, ok := topN[hook.TopN]
+			if !ok {
+				changes, err = s.topNChanges(ctx, event, hook.TopN)
+				if err != nil {
+					log.Printf("webhooks: failed to detect top %d changes: %v", hook.TopN, err)
+				}
+				topN[hook.TopN] = changes
+			}
+			for _, change := range changes {
+				if hook.Wants(change.Event) {
+					events = append(events, change)
+				}
+			}
+		}
+		if hook.Wants(entity.WebhookRatingCrossed) {
+			events = append(events, ratingCrossings(event, hook.Thresholds)...)
+		}
+		if hook.Wants(entity.WebhookNewLeader) && event.NewRank == 1 && (event.OldRank == nil || *event.OldRank != 1) {
+			events = append(events, newWebhookEvent(entity.WebhookNewLeader, event))
+		}
+
+		for _, e := range events {
+			payload, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("webhooks: failed to encode %s event: %v", e.Event, err)
+				continue
+			}
+			deliveries = append(deliveries, entity.NewWebhookDelivery(hook.ID, e.Event, payload))
+		}
+	}
+
+	if len(deliveries) == 0 {
+		return
+	}
+	if err := s.webhookRepo.CreateDeliveries(ctx, deliveries); err != nil {
+		log.Printf("webhooks: failed to queue %d deliveries: %v", len(deliveries), err)
+		return
+	}
+	s.notify()
+}
+
+// topNChanges reports who entered or left the top n through event. Ranks
+// are dense, so besides the updated user only the holders of the ratings
+// now at rank n and n+1 can have crossed the boundary: they moved by one if
+// the update added a distinct rating above them or emptied one.
+func (s *WebhookService) topNChanges(ctx context.Context, event *entity.ScoreEvent, n int) ([]entity.WebhookEvent, error) {
+	limit := int64(n)
+	var changes []entity.WebhookEvent
+
+	wasIn := event.OldRank != nil && *event.OldRank <= limit
+	isIn := event.NewRank <= limit
+	if !wasIn && isIn {
+		changes = append(changes, topNEvent(entity.WebhookTopNEntered, event, n))
+	} else if wasIn && !isIn {
+		changes = append(changes, topNEvent(entity.WebhookTopNLeft, event, n))
+	}
+
+	ratings := []int{event.NewRating}
+	if event.OldRating != nil {
+		ratings = append(ratings, *event.OldRating)
+	}
+	counts, err := s.leaderboardRepo.CountByRatings(ctx, ratings)
+	if err != nil {
+		return changes, err
+	}
+	added := counts[event.NewRating] == 1
+	removed := event.OldRating != nil && counts[*event.OldRating] == 0
+
+	for _, rank := range []int64{limit, limit + 1} {
+		rating, ok, err := s.leaderboardRepo.GetRatingAtRank(ctx, rank)
+		if err != nil {
+			return changes, err
+		}
+		if !ok || rating == event.NewRating {
+			continue
+		}
+
+		var shift int64
+		if added && event.NewRating > rating {
+			shift++
+		}
+		if removed && *event.OldRating > rating {
+			shift--
+		}
+		before := rank - shift
+
+		var kind string
+		switch {
+		case before > limit && rank <= limit:
+			kind = entity.WebhookTopNEntered
+		case before <= limit && rank > limit:
+			kind = entity.WebhookTopNLeft
+		default:
+			continue
+		}
+
+		userIDs, err := s.leaderboardRepo.GetUsersByRating(ctx, rating, maxDisplacedUsers)
+		if err != nil {
+			return changes, err
+		}
+		for _, userID := range userIDs {
+			if userID == event.UserID {
+				continue
+			}
+			oldRank := before
+			changes = append(changes, entity.WebhookEvent{
+				Event:      kind,
+				UserID:     userID,
+				OldRating:  &rating,
+				NewRating:  rating,
+				OldRank:    &oldRank,
+				NewRank:    rank,
+				TopN:       n,
+				OccurredAt: event.OccurredAt,
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+func ratingCrossings(event *entity.ScoreEvent, thresholds []int) []entity.WebhookEvent {
+	if event.OldRating == nil {
+		return nil
+	}
+	old := *event.OldRating
+
+	var crossings []entity.WebhookEvent
+	for _, t := range thresholds {
+		var direction string
+		switch {
+		case old < t && event.NewRating >= t:
+			direction = "up"
+		case old >= t && event.NewRating < t:
+			direction = "down"
+		default:
+			continue
+		}
+		threshold := t
+		e := newWebhookEvent(entity.WebhookRatingCrossed, event)
+		e.Threshold = &threshold
+		e.Direction = direction
+		crossings = append(crossings, e)
+	}
+	return crossings
+}
+
+func topNEvent(kind string, event *entity.ScoreEvent, n int) entity.WebhookEvent {
+	e := newWebhookEvent(kind, event)
+	e.TopN = n
+	return e
+}
+
+func newWebhookEvent(kind string, event *entity.ScoreEvent) entity.WebhookEvent {
+	return entity.WebhookEvent{
+		Event:      kind,
+		UserID:     event.UserID,
+		OldRating:  event.OldRating,
+		NewRating:  event.NewRating,
+		OldRank:    event.OldRank,
+		NewRank:    event.NewRank,
+		OccurredAt: event.OccurredAt,
+	}
+}
+
+func (s *WebhookService) subscriptions(ctx context.Context) ([]*entity.Webhook, error) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	if s.hooks != nil && time.Since(s.loadedAt) < webhookCacheTTL {
+		return s.hooks, nil
+	}
+	hooks, err := s.webhookRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if hooks == nil {
+		hooks = []*entity.Webhook{}
+	}
+	s.hooks = hooks
+	s.loadedAt = time.Now()
+	return hooks, nil
+}
+
+func (s *WebhookService) invalidate() {
+	s.cacheMu.Lock()
+	s.hooks = nil
+	s.cacheMu.Unlock()
+}
+
+// notify wakes the dispatcher without waiting for the next poll.
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the dispatcher until Stop is called.
+func (s *WebhookService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopCh != nil {
+		return
+	}
+	s.stopCh = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go s.dispatch(s.stopCh, s.done)
+}
+
+// Stop ends the dispatcher after the deliveries in flight finish.
+func (s *WebhookService) Stop() {
+	s.mu.Lock()
+	stopCh, done := s.stopCh, s.done
+	s.stopCh, s.done = nil, nil
+	s.mu.Unlock()
+
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	<-done
+}
+
+func (s *WebhookService) dispatch(stopCh, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.sendDue(stopCh)
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// sendDue sends claimed deliveries until none are due.
+func (s *WebhookService) sendDue(stopCh chan struct{}) {
+	ctx := context.Background()
+	batch := s.opts.Workers * 4
+	// A claimed delivery is invisible to other instances until its lease
+	// ends, which must outlast the request.
+	lease := 2*s.opts.Timeout + 5*time.Second
+
+	for {
+		now := time.Now()
+		due, err := s.webhookRepo.ClaimDue(ctx, now, now.Add(lease), batch)
+		if err != nil {
+			log.Printf("webhooks: failed to claim deliveries: %v", err)
+			return
+		}
+
+		sem := make(chan struct{}, s.opts.Workers)
+		var wg sync.WaitGroup
+		for _, d := range due {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(d repository.DueDelivery) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				s.attempt(ctx, d)
+			}(d)
+		}
+		wg.Wait()
+
+		if len(due) < batch {
+			return
+		}
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+	}
+}
+
+func (s *WebhookService) attempt(ctx context.Context, due repository.DueDelivery) {
+	d := due.Delivery
+	d.Attempts++
+
+	code, err := s.send(ctx, due)
+	now := time.Now()
+	d.LastStatusCode = code
+	if err == nil {
+		d.Status = entity.DeliverySucceeded
+		d.LastError = ""
+		d.DeliveredAt = &now
+	} else {
+		d.LastError = err.Error()
+		if d.Attempts >= s.opts.MaxAttempts {
+			d.Status = entity.DeliveryFailed
+		} else {
+			d.NextAttemptAt = now.Add(s.backoff(d.Attempts))
+		}
+	}
+
+	if err := s.webhookRepo.RecordAttempt(ctx, d); err != nil {
+		log.Printf("webhooks: failed to record attempt of delivery %d: %v", d.ID, err)
+	}
+}
+
+func (s *WebhookService) send(ctx context.Context, due repository.DueDelivery) (*int, error) {
+	d := due.Delivery
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, due.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, d.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(due.Secret, time.Now().Unix(), d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	code := resp.StatusCode
+	if code < 200 || code > 299 {
+		return &code, fmt.Errorf("unexpected status %d", code)
+	}
+	return &code, nil
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	wait := s.opts.Backoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= s.opts.MaxBackoff {
+			return s.opts.MaxBackoff
+		}
+	}
+	return wait
+}
+
+// WebhookSignature returns the X-RankQ-Signature value for body sent at
+// timestamp. Receivers recompute it to verify a delivery.
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/infrastructure/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTopNChanges(t *testing.T) {
+	type user = string
+	type change struct {
+		event   string
+		user    user
+		oldRank int64
+		newRank int64
+	}
+
+	tests := []struct {
+		name   string
+		board  map[user]int
+		n      int
+		update user
+		rating int
+		want   []change
+	}{
+		{
+			name:   "entering pushes the last one out",
+			board:  map[user]int{"a": 1000, "b": 900, "c": 800, "d": 700},
+			n:      2,
+			update: "d",
+			rating: 950,
+			want: []change{
+				{entity.WebhookTopNEntered, "d", 4, 2},
+				{entity.WebhookTopNLeft, "b", 2, 3},
+			},
+		},
+		{
+			name:   "leaving pulls the next one in",
+			board:  map[user]int{"a": 1000, "b": 900, "c": 800, "d": 700},
+			n:      2,
+			update: "a",
+			rating: 750,
+			want: []change{
+				{entity.WebhookTopNLeft, "a", 1, 3},
+				{entity.WebhookTopNEntered, "c", 3, 2},
+			},
+		},
+		{
+			name:   "joining a tie inside the top adds no rank",
+			board:  map[user]int{"a": 1000, "b": 900, "c": 800, "d": 700},
+			n:      2,
+			update: "d",
+			rating: 900,
+			want: []change{
+				{entity.WebhookTopNEntered, "d", 4, 2},
+			},
+		},
+		{
+			name:   "moving inside the top changes nothing",
+			board:  map[user]int{"a": 1000, "b": 900, "c": 800, "d": 700},
+			n:      2,
+			update: "a",
+			rating: 950,
+		},
+		{
+			name:   "a tied group is pushed out together",
+			board:  map[user]int{"a": 1000, "b": 900, "e": 900, "c": 800},
+			n:      2,
+			update: "c",
+			rating: 950,
+			want: []change{
+				{entity.WebhookTopNEntered, "c", 3, 2},
+				{entity.WebhookTopNLeft, "b", 2, 3},
+				{entity.WebhookTopNLeft, "e", 2, 3},
+			},
+		},
+		{
+			name:   "a new user entering",
+			board:  map[user]int{"a": 1000, "b": 900},
+			n:      2,
+			update: "z",
+			rating: 950,
+			want: []change{
+				{entity.WebhookTopNEntered, "z", 0, 2},
+				{entity.WebhookTopNLeft, "b", 2, 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+			repo := cache.NewLeaderboardRepository(client, entity.TeamAggregate{Mode: entity.TeamAggregateSum}, 0)
+			s := NewWebhookService(nil, repo, WebhookOptions{})
+
+			ids := map[user]uuid.UUID{}
+			names := map[uuid.UUID]user{}
+			idOf := func(u user) uuid.UUID {
+				if _, ok := ids[u]; !ok {
+					ids[u] = uuid.New()
+					names[ids[u]] = u
+				}
+				return ids[u]
+			}
+			for u, rating := range tt.board {
+				if err := repo.UpdateScore(ctx, idOf(u), rating, entity.ScoreChangeUpdate); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			event := &entity.ScoreEvent{UserID: idOf(tt.update), NewRating: tt.rating}
+			if old, ok := tt.board[tt.update]; ok {
+				oldRank, err := repo.GetRank(ctx, old)
+				if err != nil {
+					t.Fatal(err)
+				}
+				event.OldRating, event.OldRank = &old, &oldRank
+			}
+			if err := repo.UpdateScore(ctx, event.UserID, tt.rating, entity.ScoreChangeUpdate); err != nil {
+				t.Fatal(err)
+			}
+			newRank, err := repo.GetRank(ctx, tt.rating)
+			if err != nil {
+				t.Fatal(err)
+			}
+			event.NewRank = newRank
+
+			events, err := s.topNChanges(ctx, event, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]change, 0, len(events))
+			for _, e := range events {
+				if e.TopN != tt.n {
+					t.Fatalf("event %+v has top_n %d, want %d", e, e.TopN, tt.n)
+				}
+				c := change{event: e.Event, user: names[e.UserID], newRank: e.NewRank}
+				if e.OldRank != nil {
+					c.oldRank = *e.OldRank
+				}
+				got = append(got, c)
+			}
+			// The updated user comes first; displaced ties in any order.
+			if len(got) > 1 {
+				rest := got[1:]
+				sort.Slice(rest, func(i, j int) bool { return rest[i].user < rest[j].user })
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions an anomaly rule can take, from least to most severe.
+const (
+	AnomalyFlag   = "flag"
+	AnomalyReview = "review"
+	AnomalyReject = "reject"
+)
+
+// Anomaly statuses. Rejected updates are never applied; pending updates wait
+// for a moderator; flagged updates were applied and await acknowledgement.
+// A moderator moves pending and flagged items to approved, or to rejected
+// and reverted respectively.
+const (
+	AnomalyStatusRejected = "rejected"
+	AnomalyStatusPending  = "pending"
+	AnomalyStatusFlagged  = "flagged"
+	AnomalyStatusApproved = "approved"
+	AnomalyStatusReverted = "reverted"
+)
+
+// AnomalySeverity orders actions so the strictest matching rule wins.
+func AnomalySeverity(action string) int {
+	switch action {
+	case AnomalyFlag:
+		return 1
+	case AnomalyReview:
+		return 2
+	case AnomalyReject:
+		return 3
+	default:
+		return 0
+	}
+}
+
+type AnomalyMatch struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+	Detail string `json:"detail"`
+}
+
+// AnomalyVerdict is the outcome of evaluating a score update. Action is empty
+// when no rule matched.
+type AnomalyVerdict struct {
+	Action  string
+	Matches []AnomalyMatch
+}
+
+type ScoreAnomaly struct {
+	ID         int64          `json:"id"`
+	UserID     uuid.UUID      `json:"user_id"`
+	OldRating  *int           `json:"old_rating"`
+	NewRating  int            `json:"new_rating"`
+	Action     string         `json:"action"`
+	Status     string         `json:"status"`
+	Matches    []AnomalyMatch `json:"matches"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ReviewedAt *time.Time     `json:"reviewed_at,omitempty"`
+	Reviewer   string         `json:"reviewer,omitempty"`
+	Note       string         `json:"note,omitempty"`
+}
+
+func NewScoreAnomaly(userID uuid.UUID, oldRating *int, newRating int, verdict *AnomalyVerdict) *ScoreAnomaly {
+	status := AnomalyStatusFlagged
+	switch verdict.Action {
+	case AnomalyReject:
+		status = AnomalyStatusRejected
+	case AnomalyReview:
+		status = AnomalyStatusPending
+	}
+	return &ScoreAnomaly{
+		UserID:    userID,
+		OldRating: oldRating,
+		NewRating: newRating,
+		Action:    verdict.Action,
+		Status:    status,
+		Matches:   verdict.Matches,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,93 @@
+package entity
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DecayFixed   = "fixed"
+	DecayPercent = "percent"
+)
+
+const decayDay = 24 * time.Hour
+
+// DecayPolicy lowers the rating of users who have not had a score change for
+// InactiveAfter. Every further full day of inactivity removes Amount points
+// (DecayFixed) or Amount percent of the rating (DecayPercent), never going
+// below Floor.
+type DecayPolicy struct {
+	Mode          string
+	Amount        float64
+	Floor         int
+	InactiveAfter time.Duration
+}
+
+// InactiveScore is a user_scores row selected for decay.
+type InactiveScore struct {
+	UserID    uuid.UUID
+	Rating    int
+	Version   int64
+	ActiveAt  time.Time
+	DecayedAt *time.Time
+}
+
+// Due returns how many whole days of decay are owed at now and the time up
+// to which they are accounted for, which becomes the row's decayed_at.
+func (p DecayPolicy) Due(score *InactiveScore, now time.Time) (int, time.Time) {
+	anchor := score.ActiveAt.Add(p.InactiveAfter)
+	if score.DecayedAt != nil && score.DecayedAt.After(anchor) {
+		anchor = *score.DecayedAt
+	}
+	if !now.After(anchor) {
+		return 0, anchor
+	}
+	days := int(now.Sub(anchor) / decayDay)
+	return days, anchor.Add(time.Duration(days) * decayDay)
+}
+
+// Apply returns rating after days of decay.
+func (p DecayPolicy) Apply(rating, days int) int {
+	if rating <= p.Floor || days <= 0 {
+		return rating
+	}
+
+	var decayed float64
+	switch p.Mode {
+	case DecayPercent:
+		decayed = float64(rating) * math.Pow(1-p.Amount/100, float64(days))
+	default:
+		decayed = float64(rating) - p.Amount*float64(days)
+	}
+
+	next := int(math.Round(decayed))
+	if next < p.Floor {
+		next = p.Floor
+	}
+	return next
+}
+
+// DecayChange records one user's decay, or in a dry run the decay that
+// would have been applied.
+type DecayChange struct {
+	UserID        uuid.UUID `json:"user_id"`
+	OldRating     int       `json:"old_rating"`
+	NewRating     int       `json:"new_rating"`
+	Days          int       `json:"days"`
+	InactiveSince time.Time `json:"inactive_since"`
+	Applied       bool      `json:"applied"`
+}
+
+type DecayReport struct {
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	DryRun     bool          `json:"dry_run"`
+	Scanned    int64         `json:"scanned"`
+	Decayed    int           `json:"decayed"`
+	Skipped    int           `json:"skipped"`
+	Changes    []DecayChange `json:"changes"`
+	Truncated  bool          `json:"truncated"`
+	Error      string        `json:"error,omitempty"`
+}
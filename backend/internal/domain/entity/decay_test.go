@@ -0,0 +1,71 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecayPolicyDue(t *testing.T) {
+	policy := DecayPolicy{InactiveAfter: 7 * decayDay}
+	active := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	anchor := active.Add(7 * decayDay)
+	at := func(d time.Duration) *time.Time {
+		t := anchor.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name      string
+		decayedAt *time.Time
+		now       time.Time
+		wantDays  int
+		wantUntil time.Time
+	}{
+		{"still active", nil, anchor.Add(-time.Hour), 0, anchor},
+		{"exactly at the threshold", nil, anchor, 0, anchor},
+		{"part of a day", nil, anchor.Add(23 * time.Hour), 0, anchor},
+		{"one day", nil, anchor.Add(decayDay), 1, anchor.Add(decayDay)},
+		{"remainder carried over", nil, anchor.Add(3*decayDay + 5*time.Hour), 3, anchor.Add(3 * decayDay)},
+		{"counts from last decay", at(2 * decayDay), anchor.Add(3*decayDay + time.Hour), 1, anchor.Add(3 * decayDay)},
+		{"nothing new since last decay", at(2 * decayDay), anchor.Add(2*decayDay + time.Hour), 0, anchor.Add(2 * decayDay)},
+		{"decay before a newer activity is ignored", at(-decayDay), anchor.Add(decayDay), 1, anchor.Add(decayDay)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := &InactiveScore{Rating: 1500, ActiveAt: active, DecayedAt: tt.decayedAt}
+			days, until := policy.Due(score, tt.now)
+			if days != tt.wantDays || !until.Equal(tt.wantUntil) {
+				t.Fatalf("Due = %d, %v; want %d, %v", days, until, tt.wantDays, tt.wantUntil)
+			}
+		})
+	}
+}
+
+func TestDecayPolicyApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DecayPolicy
+		rating int
+		days   int
+		want   int
+	}{
+		{"fixed", DecayPolicy{Mode: DecayFixed, Amount: 10, Floor: 1000}, 1500, 3, 1470},
+		{"fixed stops at floor", DecayPolicy{Mode: DecayFixed, Amount: 10, Floor: 1000}, 1015, 3, 1000},
+		{"fixed no days", DecayPolicy{Mode: DecayFixed, Amount: 10, Floor: 1000}, 1500, 0, 1500},
+		{"at floor", DecayPolicy{Mode: DecayFixed, Amount: 10, Floor: 1000}, 1000, 5, 1000},
+		{"below floor untouched", DecayPolicy{Mode: DecayFixed, Amount: 10, Floor: 1000}, 900, 5, 900},
+		{"percent compounds", DecayPolicy{Mode: DecayPercent, Amount: 10, Floor: 0}, 2000, 2, 1620},
+		{"percent rounds", DecayPolicy{Mode: DecayPercent, Amount: 1, Floor: 0}, 1001, 1, 991},
+		{"percent stops at floor", DecayPolicy{Mode: DecayPercent, Amount: 50, Floor: 1200}, 2000, 1, 1200},
+		{"floor zero", DecayPolicy{Mode: DecayFixed, Amount: 100, Floor: 0}, 150, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Apply(tt.rating, tt.days); got != tt.want {
+				t.Fatalf("Apply(%d, %d) = %d, want %d", tt.rating, tt.days, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+)
+
+type Friendship struct {
+	RequesterID uuid.UUID  `json:"requester_id"`
+	AddresseeID uuid.UUID  `json:"addressee_id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+// FriendLeaderboardEntry ranks a user among the friends of another user.
+// FriendRank is relative to that friend group; GlobalRank is the user's rank
+// on the full leaderboard. Both are tie-aware.
+type FriendLeaderboardEntry struct {
+	FriendRank int64  `json:"friend_rank"`
+	GlobalRank int64  `json:"global_rank"`
+	Username   string `json:"username"`
+	Rating     int    `json:"rating"`
+	UserID     string `json:"user_id"`
+	IsSelf     bool   `json:"is_self"`
+}
+
+func NewFriendRequest(requesterID, addresseeID uuid.UUID) *Friendship {
+	return &Friendship{
+		RequesterID: requesterID,
+		AddresseeID: addresseeID,
+		Status:      FriendshipPending,
+		CreatedAt:   time.Now(),
+	}
+}
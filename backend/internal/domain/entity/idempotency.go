@@ -0,0 +1,12 @@
+package entity
+
+// IdempotencyRecord is what is stored under an Idempotency-Key. Until the
+// first request finishes only Fingerprint is set; afterwards the response is
+// kept so retries can be answered with it.
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
@@ -0,0 +1,59 @@
+package entity
+
+import "github.com/google/uuid"
+
+// Import duplicate policies decide what happens to a row whose username
+// already exists, in the database or earlier in the same file.
+const (
+	// ImportSkip keeps the existing user and the first row in the file.
+	ImportSkip = "skip"
+	// ImportOverwrite sets the existing user's rating; within the file the
+	// last row wins.
+	ImportOverwrite = "overwrite"
+	// ImportFail aborts the whole import.
+	ImportFail = "fail"
+)
+
+func ValidImportPolicy(policy string) bool {
+	switch policy {
+	case ImportSkip, ImportOverwrite, ImportFail:
+		return true
+	}
+	return false
+}
+
+// MaxImportErrors caps how many row problems an ImportReport lists; the
+// counters stay exact.
+const MaxImportErrors = 100
+
+// ImportRow is a validated row of an import file.
+type ImportRow struct {
+	Line     int
+	UserID   uuid.UUID
+	Username string
+	Rating   int
+}
+
+type ImportError struct {
+	Line     int    `json:"line"`
+	Username string `json:"username,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ImportReport summarizes an import. Rows counts the data rows read; each
+// ends up created, updated, skipped as a duplicate or rejected.
+type ImportReport struct {
+	Rows     int           `json:"rows"`
+	Created  int           `json:"created"`
+	Updated  int           `json:"updated"`
+	Skipped  int           `json:"skipped"`
+	Rejected int           `json:"rejected"`
+	DryRun   bool          `json:"dry_run"`
+	Errors   []ImportError `json:"errors"`
+}
+
+func (r *ImportReport) AddError(line int, username, message string) {
+	if len(r.Errors) < MaxImportErrors {
+		r.Errors = append(r.Errors, ImportError{Line: line, Username: username, Message: message})
+	}
+}
@@ -0,0 +1,32 @@
+package entity
+
+import "math"
+
+// Match outcomes, as the score of the first player.
+const (
+	MatchLoss = 0.0
+	MatchDraw = 0.5
+	MatchWin  = 1.0
+)
+
+// DefaultKFactor is the largest rating change a single match can cause.
+const DefaultKFactor = 32
+
+// Elo is the rating algorithm for head-to-head matches.
+type Elo struct {
+	KFactor float64
+}
+
+// Expected returns the probability that a player rated a beats one rated
+// b, counting a draw as half a win.
+func (e Elo) Expected(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// Play returns the ratings of both players after a match in which the
+// first scored scoreA (MatchWin, MatchDraw or MatchLoss). Whatever one
+// player gains the other loses.
+func (e Elo) Play(a, b int, scoreA float64) (int, int) {
+	delta := int(math.Round(e.KFactor * (scoreA - e.Expected(float64(a), float64(b)))))
+	return a + delta, b - delta
+}
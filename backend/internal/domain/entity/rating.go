@@ -0,0 +1,23 @@
+package entity
+
+// RatingRange holds the configured rating bounds and the rating new users
+// start with.
+type RatingRange struct {
+	Min     int
+	Max     int
+	Default int
+}
+
+func (r RatingRange) Clamp(rating int) int {
+	if rating < r.Min {
+		return r.Min
+	}
+	if rating > r.Max {
+		return r.Max
+	}
+	return rating
+}
+
+func (r RatingRange) Contains(rating int) bool {
+	return rating >= r.Min && rating <= r.Max
+}
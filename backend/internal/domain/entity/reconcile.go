@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DiscrepancyMissing    = "missing"
+	DiscrepancyExtra      = "extra"
+	DiscrepancyMismatched = "mismatched"
+)
+
+// ScoreDiscrepancy describes a user whose Redis state disagrees with
+// user_scores. Missing users exist only in Postgres, extra users only in
+// Redis.
+type ScoreDiscrepancy struct {
+	UserID         uuid.UUID `json:"user_id"`
+	Kind           string    `json:"kind"`
+	PostgresRating *int      `json:"postgres_rating,omitempty"`
+	RedisRating    *int      `json:"redis_rating,omitempty"`
+	Repaired       bool      `json:"repaired"`
+}
+
+// RatingCountDiscrepancy describes a rating whose entry in
+// {leaderboard}:rating_counts or {leaderboard}:ratings disagrees with the number
+// of users actually holding it.
+type RatingCountDiscrepancy struct {
+	Rating   int   `json:"rating"`
+	Recorded int64 `json:"recorded"`
+	Actual   int64 `json:"actual"`
+	InIndex  bool  `json:"in_index"`
+	Repaired bool  `json:"repaired"`
+}
+
+type ReconcileReport struct {
+	StartedAt     time.Time                `json:"started_at"`
+	FinishedAt    time.Time                `json:"finished_at"`
+	Repair        bool                     `json:"repair"`
+	PostgresUsers int64                    `json:"postgres_users"`
+	RedisUsers    int64                    `json:"redis_users"`
+	Missing       int                      `json:"missing"`
+	Extra         int                      `json:"extra"`
+	Mismatched    int                      `json:"mismatched"`
+	BadCounts     int                      `json:"bad_counts"`
+	Repaired      int                      `json:"repaired"`
+	Discrepancies []ScoreDiscrepancy       `json:"discrepancies"`
+	RatingCounts  []RatingCountDiscrepancy `json:"rating_counts"`
+	Truncated     bool                     `json:"truncated"`
+	Error         string                   `json:"error,omitempty"`
+}
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources of a score change. History only records update, decay and
+// moderation; the event stream carries all of them.
+const (
+	ScoreChangeUpdate     = "update"
+	ScoreChangeDecay      = "decay"
+	ScoreChangeModeration = "moderation"
+	ScoreChangeCreate     = "create"
+	ScoreChangeSimulation = "simulation"
+	ScoreChangeReconcile  = "reconcile"
+)
+
+// ScoreChange is one entry in a user's rating history. OldRating is nil when
+// the user had no rating before the change.
+type ScoreChange struct {
+	ID        int64     `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	OldRating *int      `json:"old_rating"`
+	NewRating int       `json:"new_rating"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewScoreChange(userID uuid.UUID, oldRating *int, newRating int, reason string) *ScoreChange {
+	return &ScoreChange{
+		UserID:    userID,
+		OldRating: oldRating,
+		NewRating: newRating,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+}
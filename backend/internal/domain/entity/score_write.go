@@ -0,0 +1,57 @@
+package entity
+
+const (
+	ScoreModeLatest = "latest"
+	ScoreModeBest   = "best"
+	ScoreModeSum    = "sum"
+	ScoreModeCAS    = "cas"
+)
+
+func ValidScoreMode(mode string) bool {
+	switch mode {
+	case ScoreModeLatest, ScoreModeBest, ScoreModeSum, ScoreModeCAS:
+		return true
+	}
+	return false
+}
+
+// ScoreWrite is a client score update. Value is the new rating, except in
+// ScoreModeSum where it is added to the current rating. ScoreModeBest only
+// ever raises the rating, and ScoreModeCAS only writes while the user's score
+// version still equals ExpectedVersion.
+type ScoreWrite struct {
+	Mode            string
+	Value           int
+	ExpectedVersion int64
+}
+
+// Resolve returns the rating the write would produce against current, which
+// is nil for a user without a score.
+func (w ScoreWrite) Resolve(current *int, ratings RatingRange) int {
+	switch w.Mode {
+	case ScoreModeBest:
+		if current != nil && *current >= w.Value {
+			return *current
+		}
+	case ScoreModeSum:
+		base := ratings.Default
+		if current != nil {
+			base = *current
+		}
+		return ratings.Clamp(base + w.Value)
+	}
+	return ratings.Clamp(w.Value)
+}
+
+// ScoreWriteResult describes what a ScoreWrite did. Rating and Version are the
+// user's score after the write; Rank is only set when the write was applied
+// or left the score unchanged.
+type ScoreWriteResult struct {
+	Changed        bool          `json:"changed"`
+	Conflict       bool          `json:"conflict,omitempty"`
+	PreviousRating *int          `json:"previous_rating"`
+	Rating         int           `json:"rating"`
+	Version        int64         `json:"version"`
+	Rank           int64         `json:"rank,omitempty"`
+	Anomaly        *ScoreAnomaly `json:"anomaly,omitempty"`
+}
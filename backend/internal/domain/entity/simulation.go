@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+// SimulationRun records one simulation run. Seed, scenario and parameters
+// are enough to reproduce it against the same population. Updates counts
+// applied rating changes and Errors the ones that failed; StoppedAt is nil
+// while the run is active.
+type SimulationRun struct {
+	ID             int64              `json:"id"`
+	Scenario       string             `json:"scenario"`
+	Params         map[string]float64 `json:"params"`
+	IntervalMs     int                `json:"interval_ms"`
+	UpdatesPerTick int                `json:"updates_per_tick"`
+	Seed           int64              `json:"seed"`
+	RecordFile     string             `json:"record_file,omitempty"`
+	StartedAt      time.Time          `json:"started_at"`
+	StoppedAt      *time.Time         `json:"stopped_at"`
+	Ticks          int64              `json:"ticks"`
+	Updates        int64              `json:"updates"`
+	Errors         int64              `json:"errors"`
+	LastError      string             `json:"last_error,omitempty"`
+}
+
+// SimulationStatus is a snapshot of the running or most recent simulation.
+type SimulationStatus struct {
+	Running bool `json:"running"`
+	// Instance is the API instance the run executes on.
+	Instance string `json:"instance,omitempty"`
+	// Run holds the parameters, start time and counters; nil before the
+	// first run.
+	Run              *SimulationRun   `json:"run,omitempty"`
+	UpdatesPerSecond float64          `json:"updates_per_second"`
+	Churn            *SimulationChurn `json:"churn,omitempty"`
+	// Stats are the scenario's own measurements, if it takes any.
+	Stats     map[string]float64 `json:"stats,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// SimulationChurn counts how the top positions change from tick to tick.
+type SimulationChurn struct {
+	TopN int `json:"top_n"`
+	// LastTick is the number of positions held by a different user than
+	// before the last tick; Entered counts the users among them who were
+	// not in the top at all.
+	LastTick int `json:"last_tick"`
+	Entered  int `json:"entered"`
+	// Total sums LastTick over the run.
+	Total int64 `json:"total"`
+}
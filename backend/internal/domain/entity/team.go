@@ -0,0 +1,87 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TeamAggregateSum     = "sum"
+	TeamAggregateAverage = "avg"
+	TeamAggregateTopK    = "top_k"
+)
+
+// TeamAggregate describes how member ratings combine into a team score.
+// With TeamAggregateTopK the score is the average of the K highest ratings,
+// or of all ratings when the team has fewer than K members.
+type TeamAggregate struct {
+	Mode string
+	K    int
+}
+
+func (a TeamAggregate) String() string {
+	if a.Mode == TeamAggregateTopK {
+		return fmt.Sprintf("%s:%d", a.Mode, a.K)
+	}
+	return a.Mode
+}
+
+// Counts reports whether the member at position i, with members ordered by
+// rating descending, contributes to the team score.
+func (a TeamAggregate) Counts(i int) bool {
+	return a.Mode != TeamAggregateTopK || i < a.K
+}
+
+type Team struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TeamMember struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// TeamSummary is a team with its current score and tie-aware rank on the
+// team leaderboard.
+type TeamSummary struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+	Rank        int64     `json:"rank"`
+	Score       float64   `json:"score"`
+	MemberCount int64     `json:"member_count"`
+	Aggregate   string    `json:"aggregate"`
+}
+
+type TeamLeaderboardEntry struct {
+	Rank        int64   `json:"rank"`
+	Name        string  `json:"name"`
+	Score       float64 `json:"score"`
+	MemberCount int64   `json:"member_count"`
+	TeamID      string  `json:"team_id"`
+}
+
+// TeamMemberEntry breaks a team score down by member. Counted marks the
+// members whose ratings make up the score under the configured aggregate.
+type TeamMemberEntry struct {
+	TeamRank   int64     `json:"team_rank"`
+	GlobalRank int64     `json:"global_rank"`
+	Username   string    `json:"username"`
+	Rating     int       `json:"rating"`
+	UserID     string    `json:"user_id"`
+	Counted    bool      `json:"counted"`
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+func NewTeam(name string) *Team {
+	return &Team{
+		ID:        uuid.New(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+}
@@ -15,6 +15,7 @@ type User struct {
 type UserScore struct {
 	UserID    uuid.UUID `json:"user_id"`
 	Rating    int       `json:"rating"`
+	Version   int64     `json:"version"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
@@ -30,6 +31,7 @@ type SearchResult struct {
 	Username string `json:"username"`
 	Rating   int    `json:"rating"`
 	UserID   string `json:"user_id"`
+	Version  *int64 `json:"version,omitempty"`
 }
 
 func NewUser(username string) *User {
@@ -47,3 +49,12 @@ func NewUserScore(userID uuid.UUID, rating int) *UserScore {
 		UpdatedAt: time.Now(),
 	}
 }
+
+// ExportEntry is one row of a leaderboard export.
+type ExportEntry struct {
+	Rank      int64     `json:"rank"`
+	Username  string    `json:"username"`
+	UserID    string    `json:"user_id"`
+	Rating    int       `json:"rating"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
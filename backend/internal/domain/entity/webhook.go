@@ -0,0 +1,119 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types. Top-N events use the subscription's TopN; rating
+// crossings use its Thresholds.
+const (
+	WebhookTopNEntered   = "top_n_entered"
+	WebhookTopNLeft      = "top_n_left"
+	WebhookRatingCrossed = "rating_crossed"
+	WebhookNewLeader     = "new_leader"
+)
+
+func ValidWebhookEvent(event string) bool {
+	switch event {
+	case WebhookTopNEntered, WebhookTopNLeft, WebhookRatingCrossed, WebhookNewLeader:
+		return true
+	}
+	return false
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a subscription to leaderboard events. Secret signs every
+// delivery and is only returned when the webhook is created.
+type Webhook struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`
+	Events     []string  `json:"events"`
+	TopN       int       `json:"top_n"`
+	Thresholds []int     `json:"thresholds"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func NewWebhook(url, secret string, events []string, topN int, thresholds []int) *Webhook {
+	return &Webhook{
+		ID:         uuid.New(),
+		URL:        url,
+		Secret:     secret,
+		Events:     events,
+		TopN:       topN,
+		Thresholds: thresholds,
+		CreatedAt:  time.Now(),
+	}
+}
+
+func (w *Webhook) Wants(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// ScoreEvent describes an applied client score update. OldRating and
+// OldRank are nil for a user who had no rating before.
+type ScoreEvent struct {
+	UserID     uuid.UUID `json:"user_id"`
+	OldRating  *int      `json:"old_rating"`
+	NewRating  int       `json:"new_rating"`
+	OldRank    *int64    `json:"old_rank"`
+	NewRank    int64     `json:"new_rank"`
+	Version    int64     `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// WebhookEvent is the JSON body of a delivery. UserID is the user the event
+// is about, which for top-N events may be someone pushed in or out by
+// another user's update.
+type WebhookEvent struct {
+	Event      string    `json:"event"`
+	UserID     uuid.UUID `json:"user_id"`
+	OldRating  *int      `json:"old_rating,omitempty"`
+	NewRating  int       `json:"new_rating"`
+	OldRank    *int64    `json:"old_rank,omitempty"`
+	NewRank    int64     `json:"new_rank"`
+	TopN       int       `json:"top_n,omitempty"`
+	Threshold  *int      `json:"threshold,omitempty"`
+	Direction  string    `json:"direction,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      uuid.UUID       `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	RedeliveryOf   *int64          `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func NewWebhookDelivery(webhookID uuid.UUID, event string, payload json.RawMessage) *WebhookDelivery {
+	now := time.Now()
+	return &WebhookDelivery{
+		WebhookID:     webhookID,
+		Event:         event,
+		Payload:       payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
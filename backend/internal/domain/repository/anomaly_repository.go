@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+type AnomalyRepository interface {
+	Create(ctx context.Context, anomaly *entity.ScoreAnomaly) error
+	GetByID(ctx context.Context, id int64) (*entity.ScoreAnomaly, error)
+	// List returns anomalies newest first, optionally filtered by status,
+	// and the total number matching the filter.
+	List(ctx context.Context, status string, limit, offset int) ([]*entity.ScoreAnomaly, int64, error)
+	// Resolve moves an anomaly from one status to another, recording the
+	// reviewer; an empty reviewer clears the review fields. It reports false
+	// if the anomaly was no longer in from.
+	Resolve(ctx context.Context, id int64, from, to, reviewer, note string, at time.Time) (bool, error)
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rankq/backend/pkg/events"
+)
+
+// EventStream reads the score event stream that LeaderboardRepository
+// appends to. A block of 0 returns immediately.
+type EventStream interface {
+	// Read returns up to count events after the entry ID after, waiting up
+	// to block for one if none are available. "$" waits for new events only.
+	Read(ctx context.Context, after string, count int64, block time.Duration) ([]events.Event, error)
+	// LastID returns the ID of the newest entry, or "0" for an empty stream.
+	LastID(ctx context.Context) (string, error)
+	// ReadGroup delivers events to consumer within group, creating the group
+	// at start if it does not exist. Events left unacknowledged for
+	// claimIdle by any consumer of the group are handed out again first.
+	ReadGroup(ctx context.Context, group, consumer, start string, count int64, block, claimIdle time.Duration) ([]events.Event, error)
+	Ack(ctx context.Context, group string, ids []string) (int64, error)
+	// SetGroupOffset moves group so it continues after offset, creating it
+	// if needed.
+	SetGroupOffset(ctx context.Context, group, offset string) error
+}
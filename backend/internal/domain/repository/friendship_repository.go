@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+type FriendshipRepository interface {
+	// Create stores a friend request and reports false, without error, if
+	// the two users already have a friendship or request in either direction.
+	Create(ctx context.Context, friendship *entity.Friendship) (bool, error)
+	// GetBetween returns the friendship between two users in either
+	// direction, or nil if there is none.
+	GetBetween(ctx context.Context, userID, otherID uuid.UUID) (*entity.Friendship, error)
+	Accept(ctx context.Context, requesterID, addresseeID uuid.UUID, at time.Time) (bool, error)
+	Delete(ctx context.Context, userID, otherID uuid.UUID) (bool, error)
+	ListFriends(ctx context.Context, userID uuid.UUID) ([]*entity.User, error)
+	ListIncomingRequests(ctx context.Context, userID uuid.UUID) ([]*entity.Friendship, error)
+}
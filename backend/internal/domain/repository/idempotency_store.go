@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+type IdempotencyStore interface {
+	// Reserve claims key for a new request with the given fingerprint for
+	// lockTTL. If the key is already taken it returns the existing record
+	// and false.
+	Reserve(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*entity.IdempotencyRecord, bool, error)
+	// Complete stores the finished response under key for ttl.
+	Complete(ctx context.Context, key string, record *entity.IdempotencyRecord, ttl time.Duration) error
+	// Release drops a reservation so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+// ErrImportDuplicates aborts an import under entity.ImportFail; the report
+// lists the duplicate rows.
+var ErrImportDuplicates = errors.New("import contains existing or repeated usernames")
+
+type ImportRepository interface {
+	// Import stages the rows passed to add by load and merges them into
+	// users and user_scores in one transaction, applying the duplicate
+	// policy and recording the outcome in report. A dry run rolls back.
+	Import(ctx context.Context, policy string, dryRun bool, report *entity.ImportReport, load func(add func(entity.ImportRow) error) error) error
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+// JobStateStore shares the reports of the cluster's maintenance jobs
+// between API instances, whichever instance ran them.
+type JobStateStore interface {
+	SaveDecayReport(ctx context.Context, report *entity.DecayReport) error
+	// GetDecayReport returns the last saved report, or nil if there is none.
+	GetDecayReport(ctx context.Context) (*entity.DecayReport, error)
+	SaveReconcileReport(ctx context.Context, report *entity.ReconcileReport) error
+	// GetReconcileReport returns the last saved report, or nil if there is
+	// none.
+	GetReconcileReport(ctx context.Context) (*entity.ReconcileReport, error)
+}
@@ -2,21 +2,91 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+var (
+	ErrRebuildInProgress = errors.New("leaderboard rebuild already in progress")
+	ErrRebuildLockLost   = errors.New("leaderboard rebuild lock lost")
+	// ErrScoreVersionUnknown means Redis does not track the user's score
+	// version, e.g. after a rebuild; seed it with SeedScoreVersion.
+	ErrScoreVersionUnknown = errors.New("score version not tracked")
 )
 
 type LeaderboardRepository interface {
-	UpdateScore(ctx context.Context, userID uuid.UUID, rating int) error
+	// UpdateScore overwrites a rating. source says what caused the change,
+	// e.g. entity.ScoreChangeDecay, and is recorded on the event stream.
+	UpdateScore(ctx context.Context, userID uuid.UUID, rating int, source string) error
+	// UpdateScores is UpdateScore for many users in one round trip. Each
+	// user should appear at most once.
+	UpdateScores(ctx context.Context, members []LeaderboardMember, source string) error
+	// WriteScore applies a ScoreWrite atomically against the user's current
+	// rating and version, clamping the result to ratings. On a version
+	// conflict the result holds the current rating and version. source is
+	// recorded on the event stream as for UpdateScore.
+	WriteScore(ctx context.Context, userID uuid.UUID, write entity.ScoreWrite, ratings entity.RatingRange, source string) (*entity.ScoreWriteResult, error)
+	GetScoreVersion(ctx context.Context, userID uuid.UUID) (int64, error)
+	// SeedScoreVersion sets the user's version unless one is already tracked.
+	SeedScoreVersion(ctx context.Context, userID uuid.UUID, version int64) error
 	GetRank(ctx context.Context, rating int) (int64, error)
+	// GetRanks resolves the rank of several ratings in one round trip.
+	GetRanks(ctx context.Context, ratings []int) (map[int]int64, error)
 	GetTopUsers(ctx context.Context, start, stop int64) ([]LeaderboardMember, error)
+	// GetNeighbors returns userID and the up to n users ranked directly
+	// above and below it, highest rating first. It returns nothing if the
+	// user is not on the leaderboard.
+	GetNeighbors(ctx context.Context, userID uuid.UUID, n int64) ([]LeaderboardMember, error)
 	GetUserScore(ctx context.Context, userID uuid.UUID) (int, error)
 	GetTotalCount(ctx context.Context) (int64, error)
 	RemoveUser(ctx context.Context, userID uuid.UUID) error
+	// BulkLoad replaces the whole leaderboard with scores without exposing an
+	// empty or partial board to readers.
 	BulkLoad(ctx context.Context, scores map[uuid.UUID]int) error
+
+	// BeginRebuild takes the cluster-wide rebuild lock for ttl and prepares
+	// empty rebuild keys; score writes are mirrored into them until
+	// CommitRebuild or AbortRebuild. It returns ErrRebuildInProgress if
+	// another rebuild holds the lock.
+	BeginRebuild(ctx context.Context, ttl time.Duration) (string, error)
+	// LoadRebuildBatch adds scores to the rebuild keys, keeping any newer
+	// mirrored write, and extends the lock to ttl.
+	LoadRebuildBatch(ctx context.Context, token string, ttl time.Duration, scores map[uuid.UUID]int) error
+	// CommitRebuild atomically renames the rebuild keys over the live keys.
+	CommitRebuild(ctx context.Context, token string) error
+	AbortRebuild(ctx context.Context, token string) error
+
+	// GetUserScores returns the ratings of the given users; users absent from
+	// the leaderboard are omitted from the result.
+	GetUserScores(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]int, error)
+	// GetUserRanks returns the ratings and ranks of the given users in one
+	// round trip; users absent from the leaderboard are omitted.
+	GetUserRanks(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]RankedMember, error)
+	// ScanMembers iterates the leaderboard with ZSCAN semantics: members may be
+	// returned more than once and a zero cursor ends the iteration.
+	ScanMembers(ctx context.Context, cursor uint64, count int64) ([]LeaderboardMember, uint64, error)
+	// GetRatingAtRank returns the rating holding rank, i.e. the rank-th
+	// highest distinct rating, and false if there are fewer ratings.
+	GetRatingAtRank(ctx context.Context, rank int64) (int, bool, error)
+	// GetUsersByRating returns up to limit users holding exactly rating.
+	GetUsersByRating(ctx context.Context, rating int, limit int64) ([]uuid.UUID, error)
+	GetRatingCounts(ctx context.Context) (map[int]int64, error)
+	GetRatingBuckets(ctx context.Context) ([]int, error)
+	CountByRatings(ctx context.Context, ratings []int) (map[int]int64, error)
+	// RepairRatingCount recomputes the count and bucket entry for one rating
+	// from the user set.
+	RepairRatingCount(ctx context.Context, rating int) error
 }
 
 type LeaderboardMember struct {
 	UserID uuid.UUID
 	Rating int
 }
+
+type RankedMember struct {
+	LeaderboardMember
+	Rank int64
+}
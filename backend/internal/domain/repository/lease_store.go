@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// LeaseStore hands out named leases that expire unless renewed, so at most
+// one holder owns a lease at a time across the cluster.
+type LeaseStore interface {
+	// Acquire takes the lease for holder, or renews it if holder already
+	// owns it, for ttl. It reports whether holder owns the lease.
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// Release gives the lease up if holder owns it.
+	Release(ctx context.Context, name, holder string) error
+	// Holder returns the lease's owner, or "" if nobody holds it.
+	Holder(ctx context.Context, name string) (string, error)
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// RateCounter counts events per key in fixed windows.
+type RateCounter interface {
+	// Hit records one event for key and returns the number recorded in the
+	// current window, which starts at the first event and lasts window.
+	Hit(ctx context.Context, key string, window time.Duration) (int64, error)
+}
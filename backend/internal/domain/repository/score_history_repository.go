@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+type ScoreHistoryRepository interface {
+	Record(ctx context.Context, change *entity.ScoreChange) error
+	// ListByUser returns the most recent changes for userID, newest first.
+	ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]*entity.ScoreChange, error)
+}
@@ -2,14 +2,35 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rankq/backend/internal/domain/entity"
 )
 
 type ScoreRepository interface {
+	// Upsert writes a rating and bumps the row's version.
 	Upsert(ctx context.Context, score *entity.UserScore) error
+	// UpsertMany is Upsert for many ratings in one statement, all written
+	// at the same time. Each user may appear at most once.
+	UpsertMany(ctx context.Context, ratings []LeaderboardMember, at time.Time) error
+	// UpsertVersioned stores a rating together with the version the
+	// leaderboard assigned to it, ignoring writes older than the stored row.
+	UpsertVersioned(ctx context.Context, score *entity.UserScore) error
 	GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.UserScore, error)
 	GetByUserIDs(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*entity.UserScore, error)
 	GetAll(ctx context.Context) ([]*entity.UserScore, error)
+	// StreamAll calls fn with successive batches of scores ordered by user ID,
+	// without holding the whole table in memory.
+	StreamAll(ctx context.Context, batchSize int, fn func(batch []*entity.UserScore) error) error
+	// StreamInactive is like StreamAll but only returns users last active
+	// before activeBefore whose rating is above minRating.
+	StreamInactive(ctx context.Context, activeBefore time.Time, minRating, batchSize int, fn func(batch []*entity.InactiveScore) error) error
+	// StreamStandings calls fn with successive batches of ranked scores,
+	// highest rating first, all read from one consistent snapshot.
+	StreamStandings(ctx context.Context, batchSize int, fn func(batch []entity.ExportEntry) error) error
+	// ApplyDecay sets a decayed rating and its new version if the row still
+	// holds the rating, version and active_at it was read with, i.e. no score
+	// change happened since. Unlike Upsert it leaves active_at untouched.
+	ApplyDecay(ctx context.Context, score *entity.InactiveScore, toRating int, version int64, decayedAt, now time.Time) (bool, error)
 }
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+type SimulationRunRepository interface {
+	Create(ctx context.Context, run *entity.SimulationRun) error
+	// UpdateProgress stores the counters, last error and stop time of run.
+	UpdateProgress(ctx context.Context, run *entity.SimulationRun) error
+	GetByID(ctx context.Context, id int64) (*entity.SimulationRun, error)
+	// List returns runs newest first and the total number of runs.
+	List(ctx context.Context, limit, offset int) ([]*entity.SimulationRun, int64, error)
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+// SimulationStateStore shares the state of the cluster's simulation
+// between API instances.
+type SimulationStateStore interface {
+	SaveStatus(ctx context.Context, status *entity.SimulationStatus) error
+	// GetStatus returns the last saved status, or nil if there is none.
+	GetStatus(ctx context.Context) (*entity.SimulationStatus, error)
+	// RequestStop asks the instance running runID to stop it.
+	RequestStop(ctx context.Context, runID int64) error
+	StopRequested(ctx context.Context, runID int64) (bool, error)
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// TeamLeaderboardRepository keeps team scores in Redis. Member ratings are
+// read from the user leaderboard, and UpdateScore/RemoveUser on
+// LeaderboardRepository update the member's team in the same step.
+type TeamLeaderboardRepository interface {
+	// AddMember assigns userID to teamID, moving them out of any previous
+	// team, and folds their current rating into the team score.
+	AddMember(ctx context.Context, teamID, userID uuid.UUID) error
+	RemoveMember(ctx context.Context, teamID, userID uuid.UUID) error
+	// SyncTeam replaces the members of teamID and recomputes its score from
+	// the live user leaderboard. An empty userIDs places the team on the
+	// board with a score of zero.
+	SyncTeam(ctx context.Context, teamID uuid.UUID, userIDs []uuid.UUID) error
+	GetTopTeams(ctx context.Context, start, stop int64) ([]TeamStanding, error)
+	// GetTeamScore returns the score of teamID and whether it is on the board.
+	GetTeamScore(ctx context.Context, teamID uuid.UUID) (float64, bool, error)
+	// GetTeamRank returns the tie-aware rank of score on the team board.
+	GetTeamRank(ctx context.Context, score float64) (int64, error)
+	GetTotalTeams(ctx context.Context) (int64, error)
+	GetMemberCounts(ctx context.Context, teamIDs []uuid.UUID) (map[uuid.UUID]int64, error)
+	// GetMembers returns the rated members of teamID, highest rating first.
+	GetMembers(ctx context.Context, teamID uuid.UUID) ([]LeaderboardMember, error)
+	// GetAggregate returns the aggregate the board was last fully synced
+	// with, or "" if it never was.
+	GetAggregate(ctx context.Context) (string, error)
+	SetAggregate(ctx context.Context, aggregate string) error
+}
+
+type TeamStanding struct {
+	TeamID uuid.UUID
+	Score  float64
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+type TeamRepository interface {
+	Create(ctx context.Context, team *entity.Team) error
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Team, error)
+	GetByName(ctx context.Context, name string) (*entity.Team, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entity.Team, error)
+	// GetByUserID returns the team userID belongs to, or nil.
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.Team, error)
+	// AddMember adds userID to a team and reports false, without error, if
+	// the user already belongs to one.
+	AddMember(ctx context.Context, teamID, userID uuid.UUID, joinedAt time.Time) (bool, error)
+	RemoveMember(ctx context.Context, teamID, userID uuid.UUID) (bool, error)
+	ListMembers(ctx context.Context, teamID uuid.UUID) ([]*entity.TeamMember, error)
+	// StreamMemberships calls fn once per team, including empty teams, with
+	// the IDs of its members.
+	StreamMemberships(ctx context.Context, fn func(teamID uuid.UUID, userIDs []uuid.UUID) error) error
+}
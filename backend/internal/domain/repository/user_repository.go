@@ -2,11 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 	"github.com/rankq/backend/internal/domain/entity"
 )
 
+// ErrUsernameTaken means another user already has the username.
+var ErrUsernameTaken = errors.New("username already taken")
+
 type UserRepository interface {
 	Create(ctx context.Context, user *entity.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
@@ -15,4 +19,13 @@ type UserRepository interface {
 	Search(ctx context.Context, query string, limit int) ([]*entity.User, error)
 	List(ctx context.Context, limit, offset int) ([]*entity.User, error)
 	Count(ctx context.Context) (int64, error)
+	// StreamAll calls fn with every user in batches of up to batchSize,
+	// ordered by ID.
+	StreamAll(ctx context.Context, batchSize int, fn func(batch []*entity.User) error) error
+	// Rename changes a user's username and reports whether the user existed.
+	// It returns ErrUsernameTaken if another user has the username.
+	Rename(ctx context.Context, id uuid.UUID, username string) (bool, error)
+	// Delete removes a user along with its score, history, friendships and
+	// team membership, and reports whether it existed.
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
 }
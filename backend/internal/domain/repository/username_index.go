@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// UsernameIndex finds users by username prefix, ignoring case. It is a
+// derived copy of the users table and may briefly hold renamed or deleted
+// users.
+type UsernameIndex interface {
+	Add(ctx context.Context, users []UsernameEntry) error
+	// Rename replaces the user's entry under oldName with one under newName
+	// in one step.
+	Rename(ctx context.Context, userID uuid.UUID, oldName, newName string) error
+	Remove(ctx context.Context, userID uuid.UUID, username string) error
+	// Complete returns up to limit users whose username starts with prefix,
+	// ordered by case-folded username, skipping the first offset. It also
+	// returns how many index entries it read, which can exceed the users
+	// returned if some entries are malformed; the next page starts that many
+	// entries later.
+	Complete(ctx context.Context, prefix string, offset, limit int64) ([]UsernameEntry, int64, error)
+	Count(ctx context.Context) (int64, error)
+}
+
+type UsernameEntry struct {
+	UserID   uuid.UUID
+	Username string
+}
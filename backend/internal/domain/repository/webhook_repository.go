@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *entity.Webhook) error
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Webhook, error)
+	List(ctx context.Context) ([]*entity.Webhook, error)
+	// Delete removes a webhook and its delivery log, reporting false if it
+	// did not exist.
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+
+	CreateDeliveries(ctx context.Context, deliveries []*entity.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id int64) (*entity.WebhookDelivery, error)
+	// ListDeliveries returns deliveries of webhookID newest first, optionally
+	// filtered by status, and the total number matching the filter.
+	ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]*entity.WebhookDelivery, int64, error)
+	// ClaimDue returns up to limit pending deliveries due at now and pushes
+	// their next attempt to leaseUntil, so other instances skip them while
+	// they are being sent.
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]DueDelivery, error)
+	// RecordAttempt stores the outcome of a delivery attempt: status,
+	// attempts, next attempt, last status code and error, and delivered_at.
+	RecordAttempt(ctx context.Context, delivery *entity.WebhookDelivery) error
+}
+
+// DueDelivery is a claimed delivery together with where and how to send it.
+type DueDelivery struct {
+	Delivery *entity.WebhookDelivery
+	URL      string
+	Secret   string
+}
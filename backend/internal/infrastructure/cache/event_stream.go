@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/rankq/backend/pkg/events"
+	"github.com/redis/go-redis/v9"
+)
+
+type eventStream struct {
+	client redis.UniversalClient
+}
+
+func NewEventStream(client redis.UniversalClient) repository.EventStream {
+	return &eventStream{client: client}
+}
+
+func (s *eventStream) Read(ctx context.Context, after string, count int64, block time.Duration) ([]events.Event, error) {
+	streams, err := s.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{eventsKey, after},
+		Count:   count,
+		Block:   blockArg(block),
+	}).Result()
+	if err == redis.Nil {
+		return []events.Event{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseStreams(streams), nil
+}
+
+func (s *eventStream) LastID(ctx context.Context) (string, error) {
+	msgs, err := s.client.XRevRangeN(ctx, eventsKey, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 {
+		return "0", nil
+	}
+	return msgs[0].ID, nil
+}
+
+func (s *eventStream) ReadGroup(ctx context.Context, group, consumer, start string, count int64, block, claimIdle time.Duration) ([]events.Event, error) {
+	claimed, _, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   eventsKey,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  claimIdle,
+		Start:    "0-0",
+		Count:    count,
+	}).Result()
+	if events.IsNoGroup(err) {
+		if err := s.createGroup(ctx, group, start); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if len(claimed) > 0 {
+		return parseMessages(claimed), nil
+	}
+
+	streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{eventsKey, ">"},
+		Count:    count,
+		Block:    blockArg(block),
+	}).Result()
+	if err == redis.Nil {
+		return []events.Event{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseStreams(streams), nil
+}
+
+func (s *eventStream) Ack(ctx context.Context, group string, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return s.client.XAck(ctx, eventsKey, group, ids...).Result()
+}
+
+func (s *eventStream) SetGroupOffset(ctx context.Context, group, offset string) error {
+	err := s.client.XGroupSetID(ctx, eventsKey, group, offset).Err()
+	if events.IsNoGroup(err) {
+		return s.createGroup(ctx, group, offset)
+	}
+	return err
+}
+
+func (s *eventStream) createGroup(ctx context.Context, group, start string) error {
+	err := s.client.XGroupCreateMkStream(ctx, eventsKey, group, start).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// blockArg converts a wait into go-redis terms, where 0 blocks forever and a
+// negative value does not block.
+func blockArg(block time.Duration) time.Duration {
+	if block <= 0 {
+		return -1
+	}
+	return block
+}
+
+func parseStreams(streams []redis.XStream) []events.Event {
+	if len(streams) == 0 {
+		return []events.Event{}
+	}
+	return parseMessages(streams[0].Messages)
+}
+
+func parseMessages(msgs []redis.XMessage) []events.Event {
+	out := make([]events.Event, 0, len(msgs))
+	for _, msg := range msgs {
+		// Entries trimmed while pending have no values.
+		if msg.Values == nil {
+			continue
+		}
+		e, err := events.Parse(msg)
+		if err != nil {
+			log.Printf("events: skipping malformed entry: %v", err)
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
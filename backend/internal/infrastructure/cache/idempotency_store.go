@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const idempotencyPrefix = "idempotency:"
+
+// reserveScript claims a key or returns the record already stored under it,
+// in one step so a reservation that expires in between is not missed.
+const reserveScript = `
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+    return {1}
+end
+return {0, redis.call('GET', KEYS[1])}
+`
+
+type idempotencyStore struct {
+	client        redis.UniversalClient
+	reserveScript *redis.Script
+}
+
+func NewIdempotencyStore(client redis.UniversalClient) repository.IdempotencyStore {
+	return &idempotencyStore{
+		client:        client,
+		reserveScript: redis.NewScript(reserveScript),
+	}
+}
+
+func (s *idempotencyStore) Reserve(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*entity.IdempotencyRecord, bool, error) {
+	pending, err := json.Marshal(&entity.IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, false, err
+	}
+
+	reply, err := s.reserveScript.Run(ctx, s.client, []string{idempotencyPrefix + key}, pending, lockTTL.Milliseconds()).Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	if reply[0].(int64) == 1 {
+		return nil, true, nil
+	}
+
+	raw, _ := reply[1].(string)
+	record := &entity.IdempotencyRecord{}
+	if err := json.Unmarshal([]byte(raw), record); err != nil {
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+func (s *idempotencyStore) Complete(ctx context.Context, key string, record *entity.IdempotencyRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, idempotencyPrefix+key, data, ttl).Err()
+}
+
+func (s *idempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, idempotencyPrefix+key).Err()
+}
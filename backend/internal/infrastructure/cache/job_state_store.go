@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	decayReportKey     = "{jobs}:decay:report"
+	reconcileReportKey = "{jobs}:reconcile:report"
+)
+
+type jobStateStore struct {
+	client redis.UniversalClient
+}
+
+func NewJobStateStore(client redis.UniversalClient) repository.JobStateStore {
+	return &jobStateStore{client: client}
+}
+
+func (s *jobStateStore) SaveDecayReport(ctx context.Context, report *entity.DecayReport) error {
+	return s.save(ctx, decayReportKey, report)
+}
+
+func (s *jobStateStore) GetDecayReport(ctx context.Context) (*entity.DecayReport, error) {
+	var report entity.DecayReport
+	found, err := s.get(ctx, decayReportKey, &report)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (s *jobStateStore) SaveReconcileReport(ctx context.Context, report *entity.ReconcileReport) error {
+	return s.save(ctx, reconcileReportKey, report)
+}
+
+func (s *jobStateStore) GetReconcileReport(ctx context.Context) (*entity.ReconcileReport, error) {
+	var report entity.ReconcileReport
+	found, err := s.get(ctx, reconcileReportKey, &report)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (s *jobStateStore) save(ctx context.Context, key string, report any) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, key, data, 0).Err()
+}
+
+func (s *jobStateStore) get(ctx context.Context, key string, report any) (bool, error) {
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, report)
+}
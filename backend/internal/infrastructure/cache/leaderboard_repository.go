@@ -2,99 +2,401 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
 	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/rankq/backend/pkg/events"
 	"github.com/redis/go-redis/v9"
 )
 
+// Every leaderboard key shares the {leaderboard} hash tag so that, on Redis
+// Cluster, they live in one slot and the multi-key Lua scripts and RENAMEs
+// keep working.
 const (
-	leaderboardKey = "leaderboard:users"
-	ratingsKey = "leaderboard:ratings"
-	ratingCountsKey = "leaderboard:rating_counts"
+	leaderboardKey  = "{leaderboard}:users"
+	ratingsKey      = "{leaderboard}:ratings"
+	ratingCountsKey = "{leaderboard}:rating_counts"
+
+	rebuildLockKey         = "{leaderboard}:rebuild:lock"
+	rebuildLeaderboardKey  = "{leaderboard}:rebuild:users"
+	rebuildRatingsKey      = "{leaderboard}:rebuild:ratings"
+	rebuildRatingCountsKey = "{leaderboard}:rebuild:rating_counts"
+
+	// versionsKey maps user IDs to their score version.
+	versionsKey = "{leaderboard}:versions"
+	// eventsKey is the stream every rating change is appended to.
+	eventsKey = events.Stream
 )
 
+// writeKeys is the KEYS list for scripts that modify a user's score.
+var writeKeys = []string{
+	leaderboardKey, ratingsKey, ratingCountsKey,
+	rebuildLockKey,
+	rebuildLeaderboardKey, rebuildRatingsKey, rebuildRatingCountsKey,
+	userTeamsKey, teamsKey, teamTotalsKey,
+	versionsKey, eventsKey,
+}
+
+// scoreLua is shared by the score write scripts. setRating moves a user to
+// newRating on the live board and, while a rebuild holds KEYS[4], on the
+// rebuild keys so the write survives the RENAME that publishes the rebuilt
+// board. The user's team, if any, is updated in the same step, and a change
+// is appended to the event stream unless maxLen is 0.
+const scoreLua = `
+local function moveRating(usersKey, ratingsKey, countsKey, userID, newRating)
+    -- Get old rating
+    local oldRating = redis.call('ZSCORE', usersKey, userID)
+
+    -- If user exists, handle old rating cleanup
+    if oldRating then
+        oldRating = math.floor(tonumber(oldRating))
+        local oldCount = redis.call('HINCRBY', countsKey, oldRating, -1)
+        if oldCount <= 0 then
+            redis.call('HDEL', countsKey, oldRating)
+            redis.call('ZREM', ratingsKey, oldRating)
+        end
+    end
+
+    -- Set new rating for user
+    redis.call('ZADD', usersKey, newRating, userID)
+
+    -- Update new rating count
+    local newCount = redis.call('HINCRBY', countsKey, newRating, 1)
+    if newCount == 1 then
+        redis.call('ZADD', ratingsKey, newRating, newRating)
+    end
+end
+
+local function rankOf(rating)
+    return redis.call('ZCOUNT', KEYS[2], '(' .. rating, '+inf') + 1
+end
+
+local function setRating(userID, newRating, teamMode, teamK, source, at, maxLen)
+    local oldRating = redis.call('ZSCORE', KEYS[1], userID)
+    local oldRank
+    if oldRating then
+        oldRating = math.floor(tonumber(oldRating))
+        oldRank = rankOf(oldRating)
+    end
+
+    moveRating(KEYS[1], KEYS[2], KEYS[3], userID, newRating)
+    if redis.call('EXISTS', KEYS[4]) == 1 then
+        moveRating(KEYS[5], KEYS[6], KEYS[7], userID, newRating)
+    end
+
+    local teamID = redis.call('HGET', KEYS[8], userID)
+    if teamID then
+        setTeamRating(KEYS[9], KEYS[10], teamID, userID, newRating, teamMode, teamK)
+    end
+
+    if maxLen > 0 and oldRating ~= newRating then
+        local fields = {'user_id', userID, 'new_rating', newRating, 'new_rank', rankOf(newRating), 'source', source, 'at', at}
+        if oldRating then
+            table.insert(fields, 'old_rating')
+            table.insert(fields, oldRating)
+            table.insert(fields, 'old_rank')
+            table.insert(fields, oldRank)
+        end
+        redis.call('XADD', KEYS[12], 'MAXLEN', '~', maxLen, '*', unpack(fields))
+    end
+end
+`
+
+// updateScoreScript overwrites a rating unconditionally. It bumps the score
+// version only if one is already tracked; otherwise the next versioned write
+// seeds it from Postgres, which bumps its own copy.
 const updateScoreScript = `
-local usersKey = KEYS[1]
-local ratingsKey = KEYS[2]
-local countsKey = KEYS[3]
 local userID = ARGV[1]
 local newRating = tonumber(ARGV[2])
+local teamMode = ARGV[3]
+local teamK = tonumber(ARGV[4])
+local source = ARGV[5]
+local at = ARGV[6]
+local maxLen = tonumber(ARGV[7])
+
+setRating(userID, newRating, teamMode, teamK, source, at, maxLen)
+if redis.call('HEXISTS', KEYS[11], userID) == 1 then
+    redis.call('HINCRBY', KEYS[11], userID, 1)
+end
 
--- Get old rating
-local oldRating = redis.call('ZSCORE', usersKey, userID)
+return 1
+`
 
--- If user exists, handle old rating cleanup
-if oldRating then
-    oldRating = math.floor(tonumber(oldRating))
-    local oldCount = redis.call('HINCRBY', countsKey, oldRating, -1)
-    if oldCount <= 0 then
-        redis.call('HDEL', countsKey, oldRating)
-        redis.call('ZREM', ratingsKey, oldRating)
+// updateScoresScript is updateScoreScript for a batch of users, given as
+// userID, rating pairs after the shared arguments.
+const updateScoresScript = `
+local teamMode = ARGV[1]
+local teamK = tonumber(ARGV[2])
+local source = ARGV[3]
+local at = ARGV[4]
+local maxLen = tonumber(ARGV[5])
+
+for i = 6, #ARGV, 2 do
+    local userID = ARGV[i]
+    setRating(userID, tonumber(ARGV[i + 1]), teamMode, teamK, source, at, maxLen)
+    if redis.call('HEXISTS', KEYS[11], userID) == 1 then
+        redis.call('HINCRBY', KEYS[11], userID, 1)
     end
 end
 
--- Set new rating for user
-redis.call('ZADD', usersKey, newRating, userID)
+return (#ARGV - 5) / 2
+`
 
--- Update new rating count
-local newCount = redis.call('HINCRBY', countsKey, newRating, 1)
-if newCount == 1 then
-    redis.call('ZADD', ratingsKey, newRating, newRating)
+// writeScoreScript resolves a versioned score write against the current
+// rating in one step. It returns {status, rating, version, had_old, old}
+// where status is 1 if the rating changed, 0 if it did not, 2 on a version
+// conflict and -1 if the user's version is not tracked yet.
+const writeScoreScript = `
+local userID = ARGV[1]
+local mode = ARGV[2]
+local value = tonumber(ARGV[3])
+local expected = tonumber(ARGV[4])
+local minRating = tonumber(ARGV[5])
+local maxRating = tonumber(ARGV[6])
+local defaultRating = tonumber(ARGV[7])
+local teamMode = ARGV[8]
+local teamK = tonumber(ARGV[9])
+local at = ARGV[10]
+local maxLen = tonumber(ARGV[11])
+local source = ARGV[12]
+
+local version = redis.call('HGET', KEYS[11], userID)
+if not version then
+    return {-1, 0, 0, 0, 0}
 end
+version = tonumber(version)
 
-return 1
+local old = redis.call('ZSCORE', KEYS[1], userID)
+local hadOld = 0
+if old then
+    old = math.floor(tonumber(old))
+    hadOld = 1
+end
+
+local function clamp(rating)
+    return math.max(minRating, math.min(maxRating, rating))
+end
+
+if mode == 'cas' and version ~= expected then
+    return {2, old or 0, version, hadOld, old or 0}
+end
+
+local newRating
+if mode == 'best' then
+    if old and old >= value then
+        newRating = old
+    else
+        newRating = clamp(value)
+    end
+elseif mode == 'sum' then
+    newRating = clamp((old or defaultRating) + value)
+else
+    newRating = clamp(value)
+end
+
+if old and old == newRating then
+    return {0, old, version, hadOld, old}
+end
+
+setRating(userID, newRating, teamMode, teamK, source, at, maxLen)
+version = redis.call('HINCRBY', KEYS[11], userID, 1)
+
+return {1, newRating, version, hadOld, old or 0}
 `
 
 const removeUserScript = `
-local usersKey = KEYS[1]
-local ratingsKey = KEYS[2]
-local countsKey = KEYS[3]
 local userID = ARGV[1]
+local teamMode = ARGV[2]
+local teamK = tonumber(ARGV[3])
+
+local function remove(usersKey, ratingsKey, countsKey)
+    local rating = redis.call('ZSCORE', usersKey, userID)
+    if not rating then
+        return 0
+    end
 
-local rating = redis.call('ZSCORE', usersKey, userID)
-if not rating then
-    return 0
+    rating = math.floor(tonumber(rating))
+
+    -- Remove user
+    redis.call('ZREM', usersKey, userID)
+
+    -- Decrement rating count
+    local count = redis.call('HINCRBY', countsKey, rating, -1)
+    if count <= 0 then
+        redis.call('HDEL', countsKey, rating)
+        redis.call('ZREM', ratingsKey, rating)
+    end
+
+    return 1
+end
+
+local removed = remove(KEYS[1], KEYS[2], KEYS[3])
+if redis.call('EXISTS', KEYS[4]) == 1 then
+    remove(KEYS[5], KEYS[6], KEYS[7])
 end
+redis.call('HDEL', KEYS[11], userID)
 
-rating = math.floor(tonumber(rating))
+-- Team membership lives in Postgres; only the rating leaves the team.
+local teamID = redis.call('HGET', KEYS[8], userID)
+if teamID then
+    dropTeamRating(KEYS[9], KEYS[10], teamID, userID, teamMode, teamK)
+end
 
--- Remove user
-redis.call('ZREM', usersKey, userID)
+return removed
+`
 
--- Decrement rating count
-local count = redis.call('HINCRBY', countsKey, rating, -1)
-if count <= 0 then
+const repairRatingCountScript = `
+local usersKey = KEYS[1]
+local ratingsKey = KEYS[2]
+local countsKey = KEYS[3]
+local rating = ARGV[1]
+
+local count = redis.call('ZCOUNT', usersKey, rating, rating)
+if count > 0 then
+    redis.call('HSET', countsKey, rating, count)
+    redis.call('ZADD', ratingsKey, rating, rating)
+else
     redis.call('HDEL', countsKey, rating)
     redis.call('ZREM', ratingsKey, rating)
 end
 
-return 1
+return count
+`
+
+// neighborsScript returns the window of users around a user's position in
+// one round trip, so the position cannot move between the two calls.
+const neighborsScript = `
+local pos = redis.call('ZREVRANK', KEYS[1], ARGV[1])
+if not pos then
+    return {}
+end
+local n = tonumber(ARGV[2])
+local start = pos - n
+if start < 0 then
+    start = 0
+end
+return redis.call('ZREVRANGE', KEYS[1], start, pos + n, 'WITHSCORES')
+`
+
+// userRanksScript looks up each user's rating and the rank of that rating
+// together, so a rating cannot change between the two.
+const userRanksScript = `
+local out = {}
+for i = 1, #ARGV do
+    local rating = redis.call('ZSCORE', KEYS[1], ARGV[i])
+    if rating then
+        local rank = redis.call('ZCOUNT', KEYS[2], '(' .. rating, '+inf') + 1
+        table.insert(out, ARGV[i])
+        table.insert(out, rating)
+        table.insert(out, tostring(rank))
+    end
+end
+return out
 `
 
 type leaderboardRepository struct {
-	client             *redis.Client
+	client             redis.UniversalClient
+	teams              entity.TeamAggregate
+	eventsMaxLen       int64
 	updateScoreScript  *redis.Script
+	updateScoresScript *redis.Script
+	writeScoreScript   *redis.Script
 	removeUserScript   *redis.Script
+	repairCountScript  *redis.Script
+	neighborsScript    *redis.Script
+	userRanksScript    *redis.Script
+
+	beginRebuildScript  *redis.Script
+	loadRebuildScript   *redis.Script
+	commitRebuildScript *redis.Script
+	abortRebuildScript  *redis.Script
 }
 
-func NewLeaderboardRepository(client *redis.Client) repository.LeaderboardRepository {
+// NewLeaderboardRepository returns the Redis leaderboard. teams is the
+// aggregate used to keep team scores current as member ratings change.
+// Rating changes are appended to the event stream, trimmed to about
+// eventsMaxLen entries; 0 turns the stream off.
+func NewLeaderboardRepository(client redis.UniversalClient, teams entity.TeamAggregate, eventsMaxLen int64) repository.LeaderboardRepository {
 	return &leaderboardRepository{
-		client:            client,
-		updateScoreScript: redis.NewScript(updateScoreScript),
-		removeUserScript:  redis.NewScript(removeUserScript),
+		client:             client,
+		teams:              teams,
+		eventsMaxLen:       eventsMaxLen,
+		updateScoreScript:  redis.NewScript(teamLua + scoreLua + updateScoreScript),
+		updateScoresScript: redis.NewScript(teamLua + scoreLua + updateScoresScript),
+		writeScoreScript:   redis.NewScript(teamLua + scoreLua + writeScoreScript),
+		removeUserScript:   redis.NewScript(teamLua + removeUserScript),
+		repairCountScript:  redis.NewScript(repairRatingCountScript),
+		neighborsScript:    redis.NewScript(neighborsScript),
+		userRanksScript:    redis.NewScript(userRanksScript),
+
+		beginRebuildScript:  redis.NewScript(beginRebuildScript),
+		loadRebuildScript:   redis.NewScript(loadRebuildScript),
+		commitRebuildScript: redis.NewScript(commitRebuildScript),
+		abortRebuildScript:  redis.NewScript(abortRebuildScript),
 	}
 }
 
-
-func (r *leaderboardRepository) UpdateScore(ctx context.Context, userID uuid.UUID, rating int) error {
-	return r.updateScoreScript.Run(ctx, r.client,
-		[]string{leaderboardKey, ratingsKey, ratingCountsKey},
-		userID.String(), rating,
+func (r *leaderboardRepository) UpdateScore(ctx context.Context, userID uuid.UUID, rating int, source string) error {
+	return r.updateScoreScript.Run(ctx, r.client, writeKeys,
+		userID.String(), rating, r.teams.Mode, r.teams.K,
+		source, time.Now().UnixMilli(), r.eventsMaxLen,
 	).Err()
 }
 
+func (r *leaderboardRepository) UpdateScores(ctx context.Context, members []repository.LeaderboardMember, source string) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, 5+2*len(members))
+	args = append(args, r.teams.Mode, r.teams.K, source, time.Now().UnixMilli(), r.eventsMaxLen)
+	for _, m := range members {
+		args = append(args, m.UserID.String(), m.Rating)
+	}
+	return r.updateScoresScript.Run(ctx, r.client, writeKeys, args...).Err()
+}
+
+func (r *leaderboardRepository) WriteScore(ctx context.Context, userID uuid.UUID, write entity.ScoreWrite, ratings entity.RatingRange, source string) (*entity.ScoreWriteResult, error) {
+	res, err := r.writeScoreScript.Run(ctx, r.client, writeKeys,
+		userID.String(), write.Mode, write.Value, write.ExpectedVersion,
+		ratings.Min, ratings.Max, ratings.Default, r.teams.Mode, r.teams.K,
+		time.Now().UnixMilli(), r.eventsMaxLen, source,
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if res[0] < 0 {
+		return nil, repository.ErrScoreVersionUnknown
+	}
+
+	result := &entity.ScoreWriteResult{
+		Changed:  res[0] == 1,
+		Conflict: res[0] == 2,
+		Rating:   int(res[1]),
+		Version:  res[2],
+	}
+	if res[3] == 1 {
+		previous := int(res[4])
+		result.PreviousRating = &previous
+	}
+	return result, nil
+}
+
+func (r *leaderboardRepository) GetScoreVersion(ctx context.Context, userID uuid.UUID) (int64, error) {
+	version, err := r.client.HGet(ctx, versionsKey, userID.String()).Int64()
+	if err == redis.Nil {
+		return 0, repository.ErrScoreVersionUnknown
+	}
+	return version, err
+}
+
+func (r *leaderboardRepository) SeedScoreVersion(ctx context.Context, userID uuid.UUID, version int64) error {
+	return r.client.HSetNX(ctx, versionsKey, userID.String(), version).Err()
+}
 
 func (r *leaderboardRepository) GetRank(ctx context.Context, rating int) (int64, error) {
 	count, err := r.client.ZCount(ctx, ratingsKey, strconv.Itoa(rating+1), "+inf").Result()
@@ -104,6 +406,61 @@ func (r *leaderboardRepository) GetRank(ctx context.Context, rating int) (int64,
 	return count + 1, nil
 }
 
+func (r *leaderboardRepository) GetRanks(ctx context.Context, ratings []int) (map[int]int64, error) {
+	ranks := make(map[int]int64, len(ratings))
+	if len(ratings) == 0 {
+		return ranks, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make(map[int]*redis.IntCmd, len(ratings))
+	for _, rating := range ratings {
+		if _, ok := cmds[rating]; ok {
+			continue
+		}
+		cmds[rating] = pipe.ZCount(ctx, ratingsKey, strconv.Itoa(rating+1), "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for rating, cmd := range cmds {
+		ranks[rating] = cmd.Val() + 1
+	}
+
+	return ranks, nil
+}
+
+func (r *leaderboardRepository) GetRatingAtRank(ctx context.Context, rank int64) (int, bool, error) {
+	ratings, err := r.client.ZRevRange(ctx, ratingsKey, rank-1, rank-1).Result()
+	if err != nil || len(ratings) == 0 {
+		return 0, false, err
+	}
+	rating, err := strconv.Atoi(ratings[0])
+	if err != nil {
+		return 0, false, err
+	}
+	return rating, true, nil
+}
+
+func (r *leaderboardRepository) GetUsersByRating(ctx context.Context, rating int, limit int64) ([]uuid.UUID, error) {
+	score := strconv.Itoa(rating)
+	members, err := r.client.ZRangeByScore(ctx, leaderboardKey, &redis.ZRangeBy{
+		Min: score, Max: score, Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(members))
+	for _, m := range members {
+		if userID, err := uuid.Parse(m); err == nil {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
 func (r *leaderboardRepository) GetTopUsers(ctx context.Context, start, stop int64) ([]repository.LeaderboardMember, error) {
 	results, err := r.client.ZRevRangeWithScores(ctx, leaderboardKey, start, stop).Result()
 	if err != nil {
@@ -125,6 +482,27 @@ func (r *leaderboardRepository) GetTopUsers(ctx context.Context, start, stop int
 	return members, nil
 }
 
+func (r *leaderboardRepository) GetNeighbors(ctx context.Context, userID uuid.UUID, n int64) ([]repository.LeaderboardMember, error) {
+	values, err := r.neighborsScript.Run(ctx, r.client, []string{leaderboardKey}, userID.String(), n).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]repository.LeaderboardMember, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		id, err := uuid.Parse(values[i])
+		if err != nil {
+			continue
+		}
+		score, err := strconv.ParseFloat(values[i+1], 64)
+		if err != nil {
+			continue
+		}
+		members = append(members, repository.LeaderboardMember{UserID: id, Rating: int(score)})
+	}
+	return members, nil
+}
+
 func (r *leaderboardRepository) GetUserScore(ctx context.Context, userID uuid.UUID) (int, error) {
 	score, err := r.client.ZScore(ctx, leaderboardKey, userID.String()).Result()
 	if err == redis.Nil {
@@ -140,50 +518,195 @@ func (r *leaderboardRepository) GetTotalCount(ctx context.Context) (int64, error
 	return r.client.ZCard(ctx, leaderboardKey).Result()
 }
 
-
 func (r *leaderboardRepository) RemoveUser(ctx context.Context, userID uuid.UUID) error {
-	return r.removeUserScript.Run(ctx, r.client,
-		[]string{leaderboardKey, ratingsKey, ratingCountsKey},
-		userID.String(),
+	return r.removeUserScript.Run(ctx, r.client, writeKeys,
+		userID.String(), r.teams.Mode, r.teams.K,
 	).Err()
 }
 
+func (r *leaderboardRepository) GetUserScores(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	scores := make(map[uuid.UUID]int, len(userIDs))
+	if len(userIDs) == 0 {
+		return scores, nil
+	}
 
-func (r *leaderboardRepository) BulkLoad(ctx context.Context, scores map[uuid.UUID]int) error {
-	if len(scores) == 0 {
-		return nil
+	members := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		members[i] = id.String()
 	}
 
-	pipe := r.client.Pipeline()
+	// ZMSCORE reports missing members as nil, which go-redis surfaces as 0;
+	// check membership separately via the raw reply.
+	values, err := r.client.Do(ctx, append([]interface{}{"ZMSCORE", leaderboardKey}, toInterfaces(members)...)...).Slice()
+	if err != nil {
+		return nil, err
+	}
 
-	pipe.Del(ctx, leaderboardKey)
-	pipe.Del(ctx, ratingsKey)
-	pipe.Del(ctx, ratingCountsKey)
-	ratingCounts := make(map[int]int)
-	userMembers := make([]redis.Z, 0, len(scores))
-	
-	for userID, rating := range scores {
-		userMembers = append(userMembers, redis.Z{
-			Score:  float64(rating),
-			Member: userID.String(),
-		})
-		ratingCounts[rating]++
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		score, err := parseScore(v)
+		if err != nil {
+			return nil, err
+		}
+		scores[userIDs[i]] = int(score)
+	}
+
+	return scores, nil
+}
+
+func (r *leaderboardRepository) GetUserRanks(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]repository.RankedMember, error) {
+	ranked := make(map[uuid.UUID]repository.RankedMember, len(userIDs))
+	if len(userIDs) == 0 {
+		return ranked, nil
 	}
 
+	members := make([]interface{}, len(userIDs))
+	for i, id := range userIDs {
+		members[i] = id.String()
+	}
 
-	pipe.ZAdd(ctx, leaderboardKey, userMembers...)
+	values, err := r.userRanksScript.Run(ctx, r.client, []string{leaderboardKey, ratingsKey}, members...).StringSlice()
+	if err != nil {
+		return nil, err
+	}
 
+	for i := 0; i+2 < len(values); i += 3 {
+		id, err := uuid.Parse(values[i])
+		if err != nil {
+			continue
+		}
+		score, err := strconv.ParseFloat(values[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		rank, err := strconv.ParseInt(values[i+2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ranked[id] = repository.RankedMember{
+			LeaderboardMember: repository.LeaderboardMember{UserID: id, Rating: int(score)},
+			Rank:              rank,
+		}
+	}
+
+	return ranked, nil
+}
+
+func (r *leaderboardRepository) ScanMembers(ctx context.Context, cursor uint64, count int64) ([]repository.LeaderboardMember, uint64, error) {
+	keys, next, err := r.client.ZScan(ctx, leaderboardKey, cursor, "", count).Result()
+	if err != nil {
+		return nil, 0, err
+	}
 
-	ratingMembers := make([]redis.Z, 0, len(ratingCounts))
-	for rating, count := range ratingCounts {
-		ratingMembers = append(ratingMembers, redis.Z{
-			Score:  float64(rating),
-			Member: strconv.Itoa(rating),
+	// ZSCAN replies with alternating member, score pairs.
+	members := make([]repository.LeaderboardMember, 0, len(keys)/2)
+	for i := 0; i+1 < len(keys); i += 2 {
+		userID, err := uuid.Parse(keys[i])
+		if err != nil {
+			continue
+		}
+		score, err := strconv.ParseFloat(keys[i+1], 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		members = append(members, repository.LeaderboardMember{
+			UserID: userID,
+			Rating: int(score),
 		})
-		pipe.HSet(ctx, ratingCountsKey, strconv.Itoa(rating), count)
 	}
-	pipe.ZAdd(ctx, ratingsKey, ratingMembers...)
 
-	_, err := pipe.Exec(ctx)
-	return err
+	return members, next, nil
+}
+
+func (r *leaderboardRepository) GetRatingCounts(ctx context.Context) (map[int]int64, error) {
+	raw, err := r.client.HGetAll(ctx, ratingCountsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int]int64, len(raw))
+	for k, v := range raw {
+		rating, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		count, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		counts[rating] = count
+	}
+
+	return counts, nil
+}
+
+func (r *leaderboardRepository) GetRatingBuckets(ctx context.Context) ([]int, error) {
+	raw, err := r.client.ZRange(ctx, ratingsKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ratings := make([]int, 0, len(raw))
+	for _, v := range raw {
+		rating, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		ratings = append(ratings, rating)
+	}
+
+	return ratings, nil
+}
+
+func (r *leaderboardRepository) CountByRatings(ctx context.Context, ratings []int) (map[int]int64, error) {
+	counts := make(map[int]int64, len(ratings))
+	if len(ratings) == 0 {
+		return counts, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(ratings))
+	for i, rating := range ratings {
+		value := strconv.Itoa(rating)
+		cmds[i] = pipe.ZCount(ctx, leaderboardKey, value, value)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for i, rating := range ratings {
+		counts[rating] = cmds[i].Val()
+	}
+
+	return counts, nil
+}
+
+func (r *leaderboardRepository) RepairRatingCount(ctx context.Context, rating int) error {
+	return r.repairCountScript.Run(ctx, r.client,
+		[]string{leaderboardKey, ratingsKey, ratingCountsKey},
+		rating,
+	).Err()
+}
+
+func toInterfaces(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+func parseScore(v interface{}) (float64, error) {
+	switch s := v.(type) {
+	case string:
+		return strconv.ParseFloat(s, 64)
+	case float64:
+		return s, nil
+	case int64:
+		return float64(s), nil
+	default:
+		return 0, fmt.Errorf("unexpected score type %T", v)
+	}
 }
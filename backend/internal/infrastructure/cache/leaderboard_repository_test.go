@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+var testRatings = entity.RatingRange{Min: 0, Max: 3000, Default: 1000}
+
+func newTestLeaderboard(t *testing.T) (*leaderboardRepository, redis.UniversalClient) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	repo := NewLeaderboardRepository(client, entity.TeamAggregate{Mode: entity.TeamAggregateSum}, 100)
+	return repo.(*leaderboardRepository), client
+}
+
+func TestWriteScoreModes(t *testing.T) {
+	current := func(rating int) *int { return &rating }
+
+	tests := []struct {
+		name    string
+		current *int
+		write   entity.ScoreWrite
+		want    int
+		changed bool
+	}{
+		{"latest replaces", current(1500), entity.ScoreWrite{Mode: entity.ScoreModeLatest, Value: 1200}, 1200, true},
+		{"latest to zero", current(1500), entity.ScoreWrite{Mode: entity.ScoreModeLatest, Value: 0}, 0, true},
+		{"latest clamps", current(1500), entity.ScoreWrite{Mode: entity.ScoreModeLatest, Value: 9000}, 3000, true},
+		{"latest same rating", current(1500), entity.ScoreWrite{Mode: entity.ScoreModeLatest, Value: 1500}, 1500, false},
+		{"latest new user", nil, entity.ScoreWrite{Mode: entity.ScoreModeLatest, Value: 0}, 0, true},
+		{"best raises", current(1500), entity.ScoreWrite{Mode: entity.ScoreModeBest, Value: 1700}, 1700, true},
+		{"best keeps higher", current(1500), entity.ScoreWrite{Mode: entity.ScoreModeBest, Value: 1400}, 1500, false},
+		{"best zero over zero", current(0), entity.ScoreWrite{Mode: entity.ScoreModeBest, Value: 0}, 0, false},
+		{"best new user", nil, entity.ScoreWrite{Mode: entity.ScoreModeBest, Value: 0}, 0, true},
+		{"sum adds", current(1500), entity.ScoreWrite{Mode: entity.ScoreModeSum, Value: 25}, 1525, true},
+		{"sum subtracts to zero", current(1500), entity.ScoreWrite{Mode: entity.ScoreModeSum, Value: -1500}, 0, true},
+		{"sum clamps at min", current(10), entity.ScoreWrite{Mode: entity.ScoreModeSum, Value: -50}, 0, true},
+		{"sum of zero", current(0), entity.ScoreWrite{Mode: entity.ScoreModeSum, Value: 0}, 0, false},
+		{"sum new user starts at default", nil, entity.ScoreWrite{Mode: entity.ScoreModeSum, Value: 5}, 1005, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo, _ := newTestLeaderboard(t)
+			userID := uuid.New()
+
+			if tt.current != nil {
+				if err := repo.UpdateScore(ctx, userID, *tt.current, entity.ScoreChangeUpdate); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := repo.SeedScoreVersion(ctx, userID, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := repo.WriteScore(ctx, userID, tt.write, testRatings, entity.ScoreChangeUpdate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Rating != tt.want || result.Changed != tt.changed {
+				t.Fatalf("got rating %d changed %v, want %d changed %v", result.Rating, result.Changed, tt.want, tt.changed)
+			}
+			if want := tt.write.Resolve(tt.current, testRatings); want != tt.want {
+				t.Fatalf("ScoreWrite.Resolve = %d, script wrote %d", want, tt.want)
+			}
+			if (result.PreviousRating == nil) != (tt.current == nil) ||
+				(tt.current != nil && *result.PreviousRating != *tt.current) {
+				t.Fatalf("previous rating %v, want %v", result.PreviousRating, tt.current)
+			}
+
+			wantVersion := int64(1)
+			if tt.changed {
+				wantVersion = 2
+			}
+			if result.Version != wantVersion {
+				t.Fatalf("version %d, want %d", result.Version, wantVersion)
+			}
+
+			rating, err := repo.GetUserScore(ctx, userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rating != tt.want {
+				t.Fatalf("stored rating %d, want %d", rating, tt.want)
+			}
+			assertRatingCounts(t, repo, map[int]int64{tt.want: 1})
+		})
+	}
+}
+
+func TestWriteScoreCAS(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestLeaderboard(t)
+	userID := uuid.New()
+
+	if err := repo.UpdateScore(ctx, userID, 1500, entity.ScoreChangeUpdate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.WriteScore(ctx, userID, entity.ScoreWrite{Mode: entity.ScoreModeCAS, Value: 0}, testRatings, entity.ScoreChangeUpdate); err != repository.ErrScoreVersionUnknown {
+		t.Fatalf("untracked version: got %v, want ErrScoreVersionUnknown", err)
+	}
+	if err := repo.SeedScoreVersion(ctx, userID, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := repo.WriteScore(ctx, userID, entity.ScoreWrite{Mode: entity.ScoreModeCAS, Value: 0, ExpectedVersion: 2}, testRatings, entity.ScoreChangeUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Conflict || result.Changed || result.Rating != 1500 || result.Version != 3 {
+		t.Fatalf("stale version: got %+v, want a conflict at 1500, version 3", result)
+	}
+
+	result, err = repo.WriteScore(ctx, userID, entity.ScoreWrite{Mode: entity.ScoreModeCAS, Value: 0, ExpectedVersion: 3}, testRatings, entity.ScoreChangeUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Conflict || !result.Changed || result.Rating != 0 || result.Version != 4 {
+		t.Fatalf("current version: got %+v, want 0 at version 4", result)
+	}
+	assertRatingCounts(t, repo, map[int]int64{0: 1})
+}
+
+func assertRatingCounts(t *testing.T, repo *leaderboardRepository, want map[int]int64) {
+	t.Helper()
+	counts, err := repo.GetRatingCounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != len(want) {
+		t.Fatalf("rating counts %v, want %v", counts, want)
+	}
+	for rating, n := range want {
+		if counts[rating] != n {
+			t.Fatalf("rating counts %v, want %v", counts, want)
+		}
+	}
+
+	buckets, err := repo.GetRatingBuckets(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != len(want) {
+		t.Fatalf("rating buckets %v, want the ratings of %v", buckets, want)
+	}
+	for _, rating := range buckets {
+		if _, ok := want[rating]; !ok {
+			t.Fatalf("rating buckets %v, want the ratings of %v", buckets, want)
+		}
+	}
+}
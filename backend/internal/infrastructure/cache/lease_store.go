@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const leasePrefix = "lease:"
+
+// acquireLeaseScript takes a free lease or extends one the holder already
+// owns, in one step so the lease cannot change hands in between.
+const acquireLeaseScript = `
+local holder = redis.call('GET', KEYS[1])
+if not holder then
+    redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+    return 1
+end
+if holder == ARGV[1] then
+    redis.call('PEXPIRE', KEYS[1], ARGV[2])
+    return 1
+end
+return 0
+`
+
+const releaseLeaseScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+type leaseStore struct {
+	client        redis.UniversalClient
+	acquireScript *redis.Script
+	releaseScript *redis.Script
+}
+
+func NewLeaseStore(client redis.UniversalClient) repository.LeaseStore {
+	return &leaseStore{
+		client:        client,
+		acquireScript: redis.NewScript(acquireLeaseScript),
+		releaseScript: redis.NewScript(releaseLeaseScript),
+	}
+}
+
+func (s *leaseStore) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	owned, err := s.acquireScript.Run(ctx, s.client, []string{leasePrefix + name}, holder, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return owned == 1, nil
+}
+
+func (s *leaseStore) Release(ctx context.Context, name, holder string) error {
+	return s.releaseScript.Run(ctx, s.client, []string{leasePrefix + name}, holder).Err()
+}
+
+func (s *leaseStore) Holder(ctx context.Context, name string) (string, error) {
+	holder, err := s.client.Get(ctx, leasePrefix+name).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return holder, err
+}
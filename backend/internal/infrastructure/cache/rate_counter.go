@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const rateCounterPrefix = "ratelimit:"
+
+// rateHitScript increments a fixed-window counter, starting the window's TTL
+// on the first hit.
+const rateHitScript = `
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+    redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`
+
+type rateCounter struct {
+	client    redis.UniversalClient
+	hitScript *redis.Script
+}
+
+func NewRateCounter(client redis.UniversalClient) repository.RateCounter {
+	return &rateCounter{
+		client:    client,
+		hitScript: redis.NewScript(rateHitScript),
+	}
+}
+
+func (r *rateCounter) Hit(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return r.hitScript.Run(ctx, r.client, []string{rateCounterPrefix + key}, window.Milliseconds()).Int64()
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+// bulkLoadChunkSize bounds the number of users sent in one load script call.
+const bulkLoadChunkSize = 1000
+
+// beginRebuildScript takes the rebuild lock and clears any rebuild keys left
+// behind by an earlier run that died, in one step so no mirrored write can
+// land between the two.
+const beginRebuildScript = `
+local ok = redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2])
+if not ok then
+    return 0
+end
+redis.call('DEL', KEYS[2], KEYS[3], KEYS[4])
+return 1
+`
+
+// loadRebuildScript adds users to the rebuild keys unless a mirrored live
+// write already placed them there, which would be newer than the Postgres
+// row being loaded. It also extends the lock.
+const loadRebuildScript = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+    return redis.error_reply('rebuild lock lost')
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+
+local usersKey = KEYS[2]
+local ratingsKey = KEYS[3]
+local countsKey = KEYS[4]
+
+for i = 3, #ARGV, 2 do
+    local userID = ARGV[i]
+    local rating = tonumber(ARGV[i + 1])
+    if redis.call('ZADD', usersKey, 'NX', rating, userID) == 1 then
+        if redis.call('HINCRBY', countsKey, rating, 1) == 1 then
+            redis.call('ZADD', ratingsKey, rating, rating)
+        end
+    end
+end
+
+return 1
+`
+
+// commitRebuildScript publishes the rebuild keys over the live keys and
+// releases the lock. An empty rebuild leaves an empty board. Score versions
+// are dropped with the old board and reseeded from Postgres on the next
+// versioned write.
+const commitRebuildScript = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+    return redis.error_reply('rebuild lock lost')
+end
+
+for i = 0, 2 do
+    local src = KEYS[5 + i]
+    local dst = KEYS[2 + i]
+    if redis.call('EXISTS', src) == 1 then
+        redis.call('RENAME', src, dst)
+    else
+        redis.call('DEL', dst)
+    end
+end
+
+redis.call('DEL', KEYS[8])
+redis.call('DEL', KEYS[1])
+return 1
+`
+
+const abortRebuildScript = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+    return 0
+end
+redis.call('DEL', KEYS[1], KEYS[2], KEYS[3], KEYS[4])
+return 1
+`
+
+var rebuildKeys = []string{
+	rebuildLockKey,
+	rebuildLeaderboardKey, rebuildRatingsKey, rebuildRatingCountsKey,
+}
+
+var commitKeys = []string{
+	rebuildLockKey,
+	leaderboardKey, ratingsKey, ratingCountsKey,
+	rebuildLeaderboardKey, rebuildRatingsKey, rebuildRatingCountsKey,
+	versionsKey,
+}
+
+func (r *leaderboardRepository) BeginRebuild(ctx context.Context, ttl time.Duration) (string, error) {
+	token := uuid.NewString()
+	ok, err := r.beginRebuildScript.Run(ctx, r.client, rebuildKeys, token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return "", err
+	}
+	if ok == 0 {
+		return "", repository.ErrRebuildInProgress
+	}
+	return token, nil
+}
+
+func (r *leaderboardRepository) LoadRebuildBatch(ctx context.Context, token string, ttl time.Duration, scores map[uuid.UUID]int) error {
+	args := make([]interface{}, 0, 2+2*len(scores))
+	args = append(args, token, ttl.Milliseconds())
+	for userID, rating := range scores {
+		args = append(args, userID.String(), rating)
+	}
+
+	return lockLost(r.loadRebuildScript.Run(ctx, r.client, rebuildKeys, args...).Err())
+}
+
+func (r *leaderboardRepository) CommitRebuild(ctx context.Context, token string) error {
+	return lockLost(r.commitRebuildScript.Run(ctx, r.client, commitKeys, token).Err())
+}
+
+func (r *leaderboardRepository) AbortRebuild(ctx context.Context, token string) error {
+	return r.abortRebuildScript.Run(ctx, r.client, rebuildKeys, token).Err()
+}
+
+// BulkLoad replaces the leaderboard with scores using the same
+// build-aside-and-rename path as a rebuild, so readers never see a partial
+// board.
+func (r *leaderboardRepository) BulkLoad(ctx context.Context, scores map[uuid.UUID]int) error {
+	ttl := time.Minute
+	token, err := r.BeginRebuild(ctx, ttl)
+	if err != nil {
+		return err
+	}
+
+	chunk := make(map[uuid.UUID]int, bulkLoadChunkSize)
+	for userID, rating := range scores {
+		chunk[userID] = rating
+		if len(chunk) == bulkLoadChunkSize {
+			if err := r.LoadRebuildBatch(ctx, token, ttl, chunk); err != nil {
+				r.AbortRebuild(context.Background(), token)
+				return err
+			}
+			chunk = make(map[uuid.UUID]int, bulkLoadChunkSize)
+		}
+	}
+	if len(chunk) > 0 {
+		if err := r.LoadRebuildBatch(ctx, token, ttl, chunk); err != nil {
+			r.AbortRebuild(context.Background(), token)
+			return err
+		}
+	}
+
+	return r.CommitRebuild(ctx, token)
+}
+
+func lockLost(err error) error {
+	if err != nil && strings.Contains(err.Error(), "rebuild lock lost") {
+		return repository.ErrRebuildLockLost
+	}
+	return err
+}
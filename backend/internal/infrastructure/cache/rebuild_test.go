@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+func TestRebuildSwapKeepsMirroredWrites(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestLeaderboard(t)
+	stale, updated, created, removed := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	for userID, rating := range map[uuid.UUID]int{stale: 1000, updated: 1100, removed: 1200} {
+		if err := repo.UpdateScore(ctx, userID, rating, entity.ScoreChangeUpdate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token, err := repo.BeginRebuild(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.BeginRebuild(ctx, time.Minute); err != repository.ErrRebuildInProgress {
+		t.Fatalf("second rebuild: got %v, want ErrRebuildInProgress", err)
+	}
+
+	// Writes during the rebuild reach both boards; readers still see the
+	// live one.
+	if err := repo.UpdateScore(ctx, updated, 1500, entity.ScoreChangeUpdate); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateScore(ctx, created, 900, entity.ScoreChangeCreate); err != nil {
+		t.Fatal(err)
+	}
+
+	// The Postgres snapshot predates those writes and must not undo them.
+	// removed is not in it, so it leaves the board.
+	snapshot := map[uuid.UUID]int{stale: 1000, updated: 1100}
+	if err := repo.LoadRebuildBatch(ctx, token, time.Minute, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	total, err := repo.GetTotalCount(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 {
+		t.Fatalf("live board has %d users before the swap, want 4", total)
+	}
+
+	if err := repo.CommitRebuild(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	scores, err := repo.GetUserScores(ctx, []uuid.UUID{stale, updated, created, removed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uuid.UUID]int{stale: 1000, updated: 1500, created: 900}
+	if len(scores) != len(want) {
+		t.Fatalf("scores after swap %v, want %v", scores, want)
+	}
+	for userID, rating := range want {
+		if scores[userID] != rating {
+			t.Fatalf("scores after swap %v, want %v", scores, want)
+		}
+	}
+	assertRatingCounts(t, repo, map[int]int64{1000: 1, 1500: 1, 900: 1})
+
+	rank, err := repo.GetRank(ctx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rank != 2 {
+		t.Fatalf("rank of 1000 is %d, want 2", rank)
+	}
+
+	if err := repo.CommitRebuild(ctx, token); err != repository.ErrRebuildLockLost {
+		t.Fatalf("second commit: got %v, want ErrRebuildLockLost", err)
+	}
+	if _, err := repo.BeginRebuild(ctx, time.Minute); err != nil {
+		t.Fatalf("rebuild after commit: %v", err)
+	}
+}
+
+func TestAbortRebuildLeavesLiveBoard(t *testing.T) {
+	ctx := context.Background()
+	repo, client := newTestLeaderboard(t)
+	userID := uuid.New()
+
+	if err := repo.UpdateScore(ctx, userID, 1000, entity.ScoreChangeUpdate); err != nil {
+		t.Fatal(err)
+	}
+	token, err := repo.BeginRebuild(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.LoadRebuildBatch(ctx, token, time.Minute, map[uuid.UUID]int{userID: 2000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AbortRebuild(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := client.Exists(ctx, rebuildLockKey, rebuildLeaderboardKey, rebuildRatingsKey, rebuildRatingCountsKey).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("%d rebuild keys left after abort", n)
+	}
+	rating, err := repo.GetUserScore(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rating != 1000 {
+		t.Fatalf("rating %d after abort, want 1000", rating)
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	simulationStatusKey = "{simulation}:status"
+	simulationStopKey   = "{simulation}:stop"
+	// simulationStopTTL outlasts any wait for a run to notice the request.
+	simulationStopTTL = time.Minute
+)
+
+type simulationStateStore struct {
+	client redis.UniversalClient
+}
+
+func NewSimulationStateStore(client redis.UniversalClient) repository.SimulationStateStore {
+	return &simulationStateStore{client: client}
+}
+
+func (s *simulationStateStore) SaveStatus(ctx context.Context, status *entity.SimulationStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, simulationStatusKey, data, 0).Err()
+}
+
+func (s *simulationStateStore) GetStatus(ctx context.Context) (*entity.SimulationStatus, error) {
+	data, err := s.client.Get(ctx, simulationStatusKey).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var status entity.SimulationStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (s *simulationStateStore) RequestStop(ctx context.Context, runID int64) error {
+	return s.client.Set(ctx, simulationStopKey, runID, simulationStopTTL).Err()
+}
+
+func (s *simulationStateStore) StopRequested(ctx context.Context, runID int64) (bool, error) {
+	value, err := s.client.Get(ctx, simulationStopKey).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return value == strconv.FormatInt(runID, 10), nil
+}
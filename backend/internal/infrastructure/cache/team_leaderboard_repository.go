@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	teamsKey          = "{leaderboard}:teams"
+	userTeamsKey      = "{leaderboard}:user_teams"
+	teamTotalsKey     = "{leaderboard}:team_totals"
+	teamAggregateKey  = "{leaderboard}:teams:aggregate"
+	teamMembersPrefix = "{leaderboard}:team:"
+)
+
+// teamKeys is the KEYS list for the team scripts.
+var teamKeys = []string{leaderboardKey, userTeamsKey, teamsKey, teamTotalsKey}
+
+// teamLua is prepended to every script that changes a team member's rating
+// or a team's membership. {leaderboard}:team:<id> holds the rated members of
+// one team and {leaderboard}:team_totals the sum of their ratings, so sum and
+// avg scores cost O(1) and top_k scores O(log N + K) per update. The member
+// keys are built inside the script; they carry the same hash tag as the
+// declared keys and so live in the same cluster slot.
+const teamLua = `
+local function teamMembersKey(teamID)
+    return '{leaderboard}:team:' .. teamID
+end
+
+local function refreshTeam(teamsKey, totalsKey, teamID, mode, k)
+    local membersKey = teamMembersKey(teamID)
+    local n = redis.call('ZCARD', membersKey)
+    local score = 0
+    if n > 0 then
+        if mode == 'top_k' then
+            local top = redis.call('ZREVRANGE', membersKey, 0, k - 1, 'WITHSCORES')
+            local sum = 0
+            for i = 2, #top, 2 do
+                sum = sum + tonumber(top[i])
+            end
+            score = sum / (#top / 2)
+        else
+            score = tonumber(redis.call('HGET', totalsKey, teamID) or '0')
+            if mode == 'avg' then
+                score = score / n
+            end
+        end
+    end
+    redis.call('ZADD', teamsKey, tostring(score), teamID)
+end
+
+local function setTeamRating(teamsKey, totalsKey, teamID, userID, rating, mode, k)
+    local membersKey = teamMembersKey(teamID)
+    local old = redis.call('ZSCORE', membersKey, userID)
+    local delta = rating
+    if old then
+        delta = rating - tonumber(old)
+    end
+    redis.call('ZADD', membersKey, rating, userID)
+    redis.call('HINCRBY', totalsKey, teamID, string.format('%d', delta))
+    refreshTeam(teamsKey, totalsKey, teamID, mode, k)
+end
+
+local function dropTeamRating(teamsKey, totalsKey, teamID, userID, mode, k)
+    local membersKey = teamMembersKey(teamID)
+    local old = redis.call('ZSCORE', membersKey, userID)
+    if old then
+        redis.call('ZREM', membersKey, userID)
+        redis.call('HINCRBY', totalsKey, teamID, string.format('%d', -tonumber(old)))
+    end
+    refreshTeam(teamsKey, totalsKey, teamID, mode, k)
+end
+`
+
+const addTeamMemberScript = `
+local mode = ARGV[1]
+local k = tonumber(ARGV[2])
+local teamID = ARGV[3]
+local userID = ARGV[4]
+
+local previous = redis.call('HGET', KEYS[2], userID)
+if previous and previous ~= teamID then
+    dropTeamRating(KEYS[3], KEYS[4], previous, userID, mode, k)
+end
+redis.call('HSET', KEYS[2], userID, teamID)
+
+local rating = redis.call('ZSCORE', KEYS[1], userID)
+if rating then
+    setTeamRating(KEYS[3], KEYS[4], teamID, userID, tonumber(rating), mode, k)
+else
+    refreshTeam(KEYS[3], KEYS[4], teamID, mode, k)
+end
+
+return 1
+`
+
+const removeTeamMemberScript = `
+local mode = ARGV[1]
+local k = tonumber(ARGV[2])
+local teamID = ARGV[3]
+local userID = ARGV[4]
+
+if redis.call('HGET', KEYS[2], userID) == teamID then
+    redis.call('HDEL', KEYS[2], userID)
+end
+dropTeamRating(KEYS[3], KEYS[4], teamID, userID, mode, k)
+
+return 1
+`
+
+// syncTeamScript rebuilds one team from the live user leaderboard, so it
+// never undoes a rating written since the member list was read.
+const syncTeamScript = `
+local mode = ARGV[1]
+local k = tonumber(ARGV[2])
+local teamID = ARGV[3]
+local membersKey = teamMembersKey(teamID)
+
+local keep = {}
+for i = 4, #ARGV do
+    keep[ARGV[i]] = true
+end
+
+-- Forget members who have left.
+for _, userID in ipairs(redis.call('ZRANGE', membersKey, 0, -1)) do
+    if not keep[userID] and redis.call('HGET', KEYS[2], userID) == teamID then
+        redis.call('HDEL', KEYS[2], userID)
+    end
+end
+redis.call('DEL', membersKey)
+
+local total = 0
+for i = 4, #ARGV do
+    local userID = ARGV[i]
+    local previous = redis.call('HGET', KEYS[2], userID)
+    if previous and previous ~= teamID then
+        dropTeamRating(KEYS[3], KEYS[4], previous, userID, mode, k)
+    end
+    redis.call('HSET', KEYS[2], userID, teamID)
+
+    local rating = redis.call('ZSCORE', KEYS[1], userID)
+    if rating then
+        redis.call('ZADD', membersKey, rating, userID)
+        total = total + tonumber(rating)
+    end
+end
+
+redis.call('HSET', KEYS[4], teamID, string.format('%d', total))
+refreshTeam(KEYS[3], KEYS[4], teamID, mode, k)
+
+return 1
+`
+
+type teamLeaderboardRepository struct {
+	client             redis.UniversalClient
+	aggregate          entity.TeamAggregate
+	addMemberScript    *redis.Script
+	removeMemberScript *redis.Script
+	syncTeamScript     *redis.Script
+}
+
+func NewTeamLeaderboardRepository(client redis.UniversalClient, aggregate entity.TeamAggregate) repository.TeamLeaderboardRepository {
+	return &teamLeaderboardRepository{
+		client:             client,
+		aggregate:          aggregate,
+		addMemberScript:    redis.NewScript(teamLua + addTeamMemberScript),
+		removeMemberScript: redis.NewScript(teamLua + removeTeamMemberScript),
+		syncTeamScript:     redis.NewScript(teamLua + syncTeamScript),
+	}
+}
+
+func (r *teamLeaderboardRepository) AddMember(ctx context.Context, teamID, userID uuid.UUID) error {
+	return r.addMemberScript.Run(ctx, r.client, teamKeys,
+		r.aggregate.Mode, r.aggregate.K, teamID.String(), userID.String(),
+	).Err()
+}
+
+func (r *teamLeaderboardRepository) RemoveMember(ctx context.Context, teamID, userID uuid.UUID) error {
+	return r.removeMemberScript.Run(ctx, r.client, teamKeys,
+		r.aggregate.Mode, r.aggregate.K, teamID.String(), userID.String(),
+	).Err()
+}
+
+func (r *teamLeaderboardRepository) SyncTeam(ctx context.Context, teamID uuid.UUID, userIDs []uuid.UUID) error {
+	args := make([]interface{}, 0, 3+len(userIDs))
+	args = append(args, r.aggregate.Mode, r.aggregate.K, teamID.String())
+	for _, userID := range userIDs {
+		args = append(args, userID.String())
+	}
+	return r.syncTeamScript.Run(ctx, r.client, teamKeys, args...).Err()
+}
+
+func (r *teamLeaderboardRepository) GetTopTeams(ctx context.Context, start, stop int64) ([]repository.TeamStanding, error) {
+	results, err := r.client.ZRevRangeWithScores(ctx, teamsKey, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	standings := make([]repository.TeamStanding, 0, len(results))
+	for _, z := range results {
+		teamID, err := uuid.Parse(z.Member.(string))
+		if err != nil {
+			continue
+		}
+		standings = append(standings, repository.TeamStanding{
+			TeamID: teamID,
+			Score:  z.Score,
+		})
+	}
+
+	return standings, nil
+}
+
+func (r *teamLeaderboardRepository) GetTeamScore(ctx context.Context, teamID uuid.UUID) (float64, bool, error) {
+	score, err := r.client.ZScore(ctx, teamsKey, teamID.String()).Result()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return score, true, nil
+}
+
+func (r *teamLeaderboardRepository) GetTeamRank(ctx context.Context, score float64) (int64, error) {
+	count, err := r.client.ZCount(ctx, teamsKey, "("+strconv.FormatFloat(score, 'f', -1, 64), "+inf").Result()
+	if err != nil {
+		return 0, err
+	}
+	return count + 1, nil
+}
+
+func (r *teamLeaderboardRepository) GetTotalTeams(ctx context.Context) (int64, error) {
+	return r.client.ZCard(ctx, teamsKey).Result()
+}
+
+func (r *teamLeaderboardRepository) GetMemberCounts(ctx context.Context, teamIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(teamIDs))
+	if len(teamIDs) == 0 {
+		return counts, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(teamIDs))
+	for i, teamID := range teamIDs {
+		cmds[i] = pipe.ZCard(ctx, teamMembersPrefix+teamID.String())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for i, teamID := range teamIDs {
+		counts[teamID] = cmds[i].Val()
+	}
+
+	return counts, nil
+}
+
+func (r *teamLeaderboardRepository) GetMembers(ctx context.Context, teamID uuid.UUID) ([]repository.LeaderboardMember, error) {
+	results, err := r.client.ZRevRangeWithScores(ctx, teamMembersPrefix+teamID.String(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]repository.LeaderboardMember, 0, len(results))
+	for _, z := range results {
+		userID, err := uuid.Parse(z.Member.(string))
+		if err != nil {
+			continue
+		}
+		members = append(members, repository.LeaderboardMember{
+			UserID: userID,
+			Rating: int(z.Score),
+		})
+	}
+
+	return members, nil
+}
+
+func (r *teamLeaderboardRepository) GetAggregate(ctx context.Context) (string, error) {
+	aggregate, err := r.client.Get(ctx, teamAggregateKey).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return aggregate, err
+}
+
+func (r *teamLeaderboardRepository) SetAggregate(ctx context.Context, aggregate string) error {
+	return r.client.Set(ctx, teamAggregateKey, aggregate, 0).Err()
+}
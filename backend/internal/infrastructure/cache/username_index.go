@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/text/cases"
+)
+
+// usernamesKey is a sorted set where every member has score 0, so ZRANGEBYLEX
+// orders them bytewise. Members are "folded\x00username\x00userID": the
+// case-folded name to match prefixes on, then what to return. Postgres text
+// cannot hold NUL, so the separator never occurs in a username.
+const usernamesKey = "usernames"
+
+type usernameIndex struct {
+	client redis.UniversalClient
+}
+
+func NewUsernameIndex(client redis.UniversalClient) repository.UsernameIndex {
+	return &usernameIndex{client: client}
+}
+
+func (r *usernameIndex) Add(ctx context.Context, users []repository.UsernameEntry) error {
+	if len(users) == 0 {
+		return nil
+	}
+	members := make([]redis.Z, len(users))
+	for i, u := range users {
+		members[i] = redis.Z{Member: usernameMember(u.UserID, u.Username)}
+	}
+	return r.client.ZAdd(ctx, usernamesKey, members...).Err()
+}
+
+func (r *usernameIndex) Rename(ctx context.Context, userID uuid.UUID, oldName, newName string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, usernamesKey, usernameMember(userID, oldName))
+		pipe.ZAdd(ctx, usernamesKey, redis.Z{Member: usernameMember(userID, newName)})
+		return nil
+	})
+	return err
+}
+
+func (r *usernameIndex) Remove(ctx context.Context, userID uuid.UUID, username string) error {
+	return r.client.ZRem(ctx, usernamesKey, usernameMember(userID, username)).Err()
+}
+
+func (r *usernameIndex) Complete(ctx context.Context, prefix string, offset, limit int64) ([]repository.UsernameEntry, int64, error) {
+	folded := foldUsername(prefix)
+	// 0xff never occurs in UTF-8, so it sorts after every name with the
+	// prefix.
+	members, err := r.client.ZRangeByLex(ctx, usernamesKey, &redis.ZRangeBy{
+		Min:    "[" + folded,
+		Max:    "[" + folded + "\xff",
+		Offset: offset,
+		Count:  limit,
+	}).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]repository.UsernameEntry, 0, len(members))
+	for _, m := range members {
+		parts := strings.SplitN(m, "\x00", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		userID, err := uuid.Parse(parts[2])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, repository.UsernameEntry{UserID: userID, Username: parts[1]})
+	}
+	return entries, int64(len(members)), nil
+}
+
+func (r *usernameIndex) Count(ctx context.Context) (int64, error) {
+	return r.client.ZCard(ctx, usernamesKey).Result()
+}
+
+func usernameMember(userID uuid.UUID, username string) string {
+	return foldUsername(username) + "\x00" + username + "\x00" + userID.String()
+}
+
+// foldUsername applies Unicode case folding, so "STRASSE" and "straße" index
+// alike. A Caser is not safe for concurrent use, hence one per call.
+func foldUsername(s string) string {
+	return cases.Fold().String(s)
+}
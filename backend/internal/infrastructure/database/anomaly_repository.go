@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+type anomalyRepository struct {
+	db *sql.DB
+}
+
+func NewAnomalyRepository(db *sql.DB) repository.AnomalyRepository {
+	return &anomalyRepository{db: db}
+}
+
+const anomalyColumns = `id, user_id, old_rating, new_rating, action, status, matches, created_at, reviewed_at, reviewer, note`
+
+func (r *anomalyRepository) Create(ctx context.Context, a *entity.ScoreAnomaly) error {
+	matches, err := json.Marshal(a.Matches)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO score_anomalies (user_id, old_rating, new_rating, action, status, matches, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	return r.db.QueryRowContext(ctx, query,
+		a.UserID, a.OldRating, a.NewRating, a.Action, a.Status, matches, a.CreatedAt,
+	).Scan(&a.ID)
+}
+
+func (r *anomalyRepository) GetByID(ctx context.Context, id int64) (*entity.ScoreAnomaly, error) {
+	query := `SELECT ` + anomalyColumns + ` FROM score_anomalies WHERE id = $1`
+	a, err := scanAnomaly(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+func (r *anomalyRepository) List(ctx context.Context, status string, limit, offset int) ([]*entity.ScoreAnomaly, int64, error) {
+	var total int64
+	countQuery := `SELECT COUNT(*) FROM score_anomalies WHERE $1 = '' OR status = $1`
+	if err := r.db.QueryRowContext(ctx, countQuery, status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + anomalyColumns + `
+		FROM score_anomalies
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var anomalies []*entity.ScoreAnomaly
+	for rows.Next() {
+		a, err := scanAnomaly(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		anomalies = append(anomalies, a)
+	}
+
+	return anomalies, total, rows.Err()
+}
+
+func (r *anomalyRepository) Resolve(ctx context.Context, id int64, from, to, reviewer, note string, at time.Time) (bool, error) {
+	query := `
+		UPDATE score_anomalies
+		SET status = $3,
+		    reviewer = NULLIF($4, ''),
+		    note = NULLIF($5, ''),
+		    reviewed_at = CASE WHEN $4 = '' THEN NULL ELSE $6::timestamp END
+		WHERE id = $1 AND status = $2
+	`
+	res, err := r.db.ExecContext(ctx, query, id, from, to, reviewer, note, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAnomaly(row rowScanner) (*entity.ScoreAnomaly, error) {
+	a := &entity.ScoreAnomaly{}
+	var matches []byte
+	var reviewer, note sql.NullString
+	err := row.Scan(
+		&a.ID, &a.UserID, &a.OldRating, &a.NewRating, &a.Action, &a.Status,
+		&matches, &a.CreatedAt, &a.ReviewedAt, &reviewer, &note,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(matches, &a.Matches); err != nil {
+		return nil, err
+	}
+	a.Reviewer = reviewer.String
+	a.Note = note.String
+	return a, nil
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+type friendshipRepository struct {
+	db *sql.DB
+}
+
+func NewFriendshipRepository(db *sql.DB) repository.FriendshipRepository {
+	return &friendshipRepository{db: db}
+}
+
+func (r *friendshipRepository) Create(ctx context.Context, f *entity.Friendship) (bool, error) {
+	query := `
+		INSERT INTO friendships (requester_id, addressee_id, status, created_at, responded_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING
+	`
+	res, err := r.db.ExecContext(ctx, query, f.RequesterID, f.AddresseeID, f.Status, f.CreatedAt, f.RespondedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *friendshipRepository) GetBetween(ctx context.Context, userID, otherID uuid.UUID) (*entity.Friendship, error) {
+	query := `
+		SELECT requester_id, addressee_id, status, created_at, responded_at
+		FROM friendships
+		WHERE (requester_id = $1 AND addressee_id = $2)
+		   OR (requester_id = $2 AND addressee_id = $1)
+	`
+	f := &entity.Friendship{}
+	err := r.db.QueryRowContext(ctx, query, userID, otherID).Scan(
+		&f.RequesterID, &f.AddresseeID, &f.Status, &f.CreatedAt, &f.RespondedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (r *friendshipRepository) Accept(ctx context.Context, requesterID, addresseeID uuid.UUID, at time.Time) (bool, error) {
+	query := `
+		UPDATE friendships SET status = $3, responded_at = $4
+		WHERE requester_id = $1 AND addressee_id = $2 AND status = $5
+	`
+	res, err := r.db.ExecContext(ctx, query, requesterID, addresseeID, entity.FriendshipAccepted, at, entity.FriendshipPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *friendshipRepository) Delete(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM friendships
+		WHERE (requester_id = $1 AND addressee_id = $2)
+		   OR (requester_id = $2 AND addressee_id = $1)
+	`
+	res, err := r.db.ExecContext(ctx, query, userID, otherID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *friendshipRepository) ListFriends(ctx context.Context, userID uuid.UUID) ([]*entity.User, error) {
+	query := `
+		SELECT u.id, u.username, u.created_at
+		FROM friendships f
+		JOIN users u ON u.id = CASE WHEN f.requester_id = $1 THEN f.addressee_id ELSE f.requester_id END
+		WHERE (f.requester_id = $1 OR f.addressee_id = $1) AND f.status = $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, entity.FriendshipAccepted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*entity.User
+	for rows.Next() {
+		user := &entity.User{}
+		if err := rows.Scan(&user.ID, &user.Username, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *friendshipRepository) ListIncomingRequests(ctx context.Context, userID uuid.UUID) ([]*entity.Friendship, error) {
+	query := `
+		SELECT requester_id, addressee_id, status, created_at, responded_at
+		FROM friendships
+		WHERE addressee_id = $1 AND status = $2
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, entity.FriendshipPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var friendships []*entity.Friendship
+	for rows.Next() {
+		f := &entity.Friendship{}
+		if err := rows.Scan(&f.RequesterID, &f.AddresseeID, &f.Status, &f.CreatedAt, &f.RespondedAt); err != nil {
+			return nil, err
+		}
+		friendships = append(friendships, f)
+	}
+
+	return friendships, rows.Err()
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+type importRepository struct {
+	db *sql.DB
+}
+
+func NewImportRepository(db *sql.DB) repository.ImportRepository {
+	return &importRepository{db: db}
+}
+
+// Import copies the rows into a temporary staging table with COPY, then
+// resolves duplicates and inserts the remaining rows with a few set-based
+// statements, so the cost does not grow with round trips per row.
+func (r *importRepository) Import(ctx context.Context, policy string, dryRun bool, report *entity.ImportReport, load func(add func(entity.ImportRow) error) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		CREATE TEMP TABLE import_rows (
+			line INT NOT NULL,
+			user_id UUID NOT NULL,
+			username TEXT NOT NULL,
+			rating INT NOT NULL
+		) ON COMMIT DROP
+	`)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("import_rows", "line", "user_id", "username", "rating"))
+	if err != nil {
+		return err
+	}
+	err = load(func(row entity.ImportRow) error {
+		_, err := stmt.ExecContext(ctx, row.Line, row.UserID, row.Username, row.Rating)
+		return err
+	})
+	if err == nil {
+		_, err = stmt.ExecContext(ctx)
+	}
+	if closeErr := stmt.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// Temporary tables are never analyzed automatically.
+	for _, q := range []string{
+		`CREATE INDEX ON import_rows (username)`,
+		`CREATE INDEX ON import_rows (user_id)`,
+		`ANALYZE import_rows`,
+	} {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+
+	// Usernames repeated within the file.
+	duplicates := 0
+	switch policy {
+	case entity.ImportFail:
+		n, err := r.report(ctx, tx, `
+			SELECT a.line, a.username FROM import_rows a
+			WHERE EXISTS (SELECT 1 FROM import_rows b WHERE b.username = a.username AND b.line < a.line)
+			ORDER BY a.line
+		`, report, "username repeats an earlier row")
+		if err != nil {
+			return err
+		}
+		duplicates += n
+	case entity.ImportSkip, entity.ImportOverwrite:
+		// Skip keeps the first row, overwrite the last.
+		cmp, message := ">", "username repeats an earlier row, skipped"
+		if policy == entity.ImportOverwrite {
+			cmp, message = "<", "superseded by a later row for the same username"
+		}
+		n, err := r.report(ctx, tx, `
+			DELETE FROM import_rows a USING import_rows b
+			WHERE a.username = b.username AND a.line `+cmp+` b.line
+			RETURNING a.line, a.username
+		`, report, message)
+		if err != nil {
+			return err
+		}
+		report.Skipped += n
+	}
+
+	// Explicit user IDs must be unique and must not belong to someone else.
+	n, err := r.report(ctx, tx, `
+		DELETE FROM import_rows a USING import_rows b
+		WHERE a.user_id = b.user_id AND a.username <> b.username AND a.line > b.line
+		RETURNING a.line, a.username
+	`, report, "user_id repeats an earlier row")
+	if err != nil {
+		return err
+	}
+	report.Rejected += n
+
+	n, err = r.report(ctx, tx, `
+		DELETE FROM import_rows i USING users u
+		WHERE u.id = i.user_id AND u.username <> i.username
+		RETURNING i.line, i.username
+	`, report, "user_id belongs to another user")
+	if err != nil {
+		return err
+	}
+	report.Rejected += n
+
+	// Usernames that already exist.
+	now := time.Now()
+	switch policy {
+	case entity.ImportFail:
+		n, err := r.report(ctx, tx, `
+			SELECT i.line, i.username FROM import_rows i JOIN users u ON u.username = i.username
+			ORDER BY i.line
+		`, report, "username already exists")
+		if err != nil {
+			return err
+		}
+		duplicates += n
+		if duplicates > 0 {
+			return repository.ErrImportDuplicates
+		}
+	case entity.ImportSkip:
+		n, err := r.report(ctx, tx, `
+			DELETE FROM import_rows i USING users u
+			WHERE u.username = i.username
+			RETURNING i.line, i.username
+		`, report, "username already exists, skipped")
+		if err != nil {
+			return err
+		}
+		report.Skipped += n
+	case entity.ImportOverwrite:
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO user_scores (user_id, rating, updated_at, active_at)
+			SELECT u.id, i.rating, $1, $1 FROM import_rows i JOIN users u ON u.username = i.username
+			ON CONFLICT (user_id)
+			DO UPDATE SET rating = EXCLUDED.rating, updated_at = EXCLUDED.updated_at,
+				active_at = EXCLUDED.active_at, version = user_scores.version + 1
+		`, now)
+		if err != nil {
+			return err
+		}
+		updated, _ := res.RowsAffected()
+		report.Updated += int(updated)
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM import_rows i USING users u WHERE u.username = i.username`); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO users (id, username, created_at)
+		SELECT user_id, username, $1 FROM import_rows
+	`, now)
+	if err != nil {
+		return err
+	}
+	created, _ := res.RowsAffected()
+	report.Created += int(created)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_scores (user_id, rating, updated_at, active_at)
+		SELECT user_id, rating, $1, $1 FROM import_rows
+	`, now)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		return nil
+	}
+	return tx.Commit()
+}
+
+// report runs query, which returns (line, username) rows, and records each
+// row in the report with message. It returns the number of rows.
+func (r *importRepository) report(ctx context.Context, tx *sql.Tx, query string, report *entity.ImportReport, message string) (int, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var line int
+		var username string
+		if err := rows.Scan(&line, &username); err != nil {
+			return n, err
+		}
+		report.AddError(line, username, message)
+		n++
+	}
+	return n, rows.Err()
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/rankq/backend/migrations"
+)
+
+// testDatabaseEnv names a postgres:// URL to run the database tests against.
+// Each test migrates a schema of its own and drops it afterwards.
+const testDatabaseEnv = "RANKQ_TEST_DATABASE_URL"
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDatabaseEnv)
+	}
+	ctx := context.Background()
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.ExecContext(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("%s must be a URL: %v", testDatabaseEnv, err)
+	}
+	q := u.Query()
+	// public stays on the path for extensions such as pg_trgm.
+	q.Set("search_path", schema+",public")
+	u.RawQuery = q.Encode()
+
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Close before the schema is dropped; cleanups run last-in first-out.
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := NewMigrator(db, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestImportDuplicatePolicies(t *testing.T) {
+	// alice exists; the file repeats bob.
+	rows := []entity.ImportRow{
+		{Line: 2, UserID: uuid.New(), Username: "alice", Rating: 1500},
+		{Line: 3, UserID: uuid.New(), Username: "bob", Rating: 1200},
+		{Line: 4, UserID: uuid.New(), Username: "bob", Rating: 1300},
+		{Line: 5, UserID: uuid.New(), Username: "carol", Rating: 900},
+	}
+
+	tests := []struct {
+		policy     string
+		dryRun     bool
+		wantErr    error
+		want       entity.ImportReport
+		wantLines  []int
+		wantScores map[string]int
+	}{
+		{
+			policy:     entity.ImportSkip,
+			want:       entity.ImportReport{Created: 2, Skipped: 2},
+			wantLines:  []int{2, 4},
+			wantScores: map[string]int{"alice": 1000, "bob": 1200, "carol": 900},
+		},
+		{
+			policy:     entity.ImportOverwrite,
+			want:       entity.ImportReport{Created: 2, Updated: 1, Skipped: 1},
+			wantLines:  []int{3},
+			wantScores: map[string]int{"alice": 1500, "bob": 1300, "carol": 900},
+		},
+		{
+			policy:     entity.ImportFail,
+			wantErr:    repository.ErrImportDuplicates,
+			wantLines:  []int{2, 4},
+			wantScores: map[string]int{"alice": 1000},
+		},
+		{
+			policy:     entity.ImportOverwrite,
+			dryRun:     true,
+			want:       entity.ImportReport{Created: 2, Updated: 1, Skipped: 1},
+			wantLines:  []int{3},
+			wantScores: map[string]int{"alice": 1000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s dry_run=%v", tt.policy, tt.dryRun), func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+
+			alice := uuid.New()
+			now := time.Now()
+			if _, err := db.ExecContext(ctx, `INSERT INTO users (id, username, created_at) VALUES ($1, 'alice', $2)`, alice, now); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.ExecContext(ctx, `INSERT INTO user_scores (user_id, rating, updated_at) VALUES ($1, 1000, $2)`, alice, now); err != nil {
+				t.Fatal(err)
+			}
+
+			report := &entity.ImportReport{}
+			err := NewImportRepository(db).Import(ctx, tt.policy, tt.dryRun, report, func(add func(entity.ImportRow) error) error {
+				for _, row := range rows {
+					if err := add(row); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Fatalf("Import: got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil &&
+				(report.Created != tt.want.Created || report.Updated != tt.want.Updated ||
+					report.Skipped != tt.want.Skipped || report.Rejected != tt.want.Rejected) {
+				t.Fatalf("report %+v, want %+v", report, tt.want)
+			}
+
+			lines := make([]int, len(report.Errors))
+			for i, e := range report.Errors {
+				lines[i] = e.Line
+			}
+			sort.Ints(lines)
+			if fmt.Sprint(lines) != fmt.Sprint(tt.wantLines) {
+				t.Fatalf("reported lines %v, want %v (%+v)", lines, tt.wantLines, report.Errors)
+			}
+
+			scores := map[string]int{}
+			rs, err := db.QueryContext(ctx, `SELECT u.username, s.rating FROM users u JOIN user_scores s ON s.user_id = u.id`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Close()
+			for rs.Next() {
+				var username string
+				var rating int
+				if err := rs.Scan(&username, &rating); err != nil {
+					t.Fatal(err)
+				}
+				scores[username] = rating
+			}
+			if err := rs.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(scores) != fmt.Sprint(tt.wantScores) {
+				t.Fatalf("stored scores %v, want %v", scores, tt.wantScores)
+			}
+		})
+	}
+}
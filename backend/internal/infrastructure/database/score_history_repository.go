@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+type scoreHistoryRepository struct {
+	db *sql.DB
+}
+
+func NewScoreHistoryRepository(db *sql.DB) repository.ScoreHistoryRepository {
+	return &scoreHistoryRepository{db: db}
+}
+
+func (r *scoreHistoryRepository) Record(ctx context.Context, change *entity.ScoreChange) error {
+	query := `
+		INSERT INTO score_history (user_id, old_rating, new_rating, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	return r.db.QueryRowContext(ctx, query,
+		change.UserID, change.OldRating, change.NewRating, change.Reason, change.CreatedAt,
+	).Scan(&change.ID)
+}
+
+func (r *scoreHistoryRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]*entity.ScoreChange, error) {
+	query := `
+		SELECT id, user_id, old_rating, new_rating, reason, created_at
+		FROM score_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*entity.ScoreChange
+	for rows.Next() {
+		c := &entity.ScoreChange{}
+		if err := rows.Scan(&c.ID, &c.UserID, &c.OldRating, &c.NewRating, &c.Reason, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/rankq/backend/internal/domain/entity"
 	"github.com/rankq/backend/internal/domain/repository"
 )
@@ -21,19 +24,55 @@ func NewScoreRepository(db *sql.DB) repository.ScoreRepository {
 
 func (r *scoreRepository) Upsert(ctx context.Context, score *entity.UserScore) error {
 	query := `
-		INSERT INTO user_scores (user_id, rating, updated_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO user_scores (user_id, rating, updated_at, active_at)
+		VALUES ($1, $2, $3, $3)
 		ON CONFLICT (user_id)
-		DO UPDATE SET rating = $2, updated_at = $3
+		DO UPDATE SET rating = $2, updated_at = $3, active_at = $3, version = user_scores.version + 1
 	`
 	_, err := r.db.ExecContext(ctx, query, score.UserID, score.Rating, score.UpdatedAt)
 	return err
 }
 
+func (r *scoreRepository) UpsertMany(ctx context.Context, ratings []repository.LeaderboardMember, at time.Time) error {
+	if len(ratings) == 0 {
+		return nil
+	}
+
+	userIDs := make(pq.StringArray, len(ratings))
+	values := make(pq.Int64Array, len(ratings))
+	for i, m := range ratings {
+		userIDs[i] = m.UserID.String()
+		values[i] = int64(m.Rating)
+	}
+
+	query := `
+		INSERT INTO user_scores (user_id, rating, updated_at, active_at)
+		SELECT u.user_id, u.rating, $3, $3
+		FROM unnest($1::uuid[], $2::int[]) AS u(user_id, rating)
+		ON CONFLICT (user_id)
+		DO UPDATE SET rating = EXCLUDED.rating, updated_at = $3, active_at = $3,
+			version = user_scores.version + 1
+	`
+	_, err := r.db.ExecContext(ctx, query, userIDs, values, at)
+	return err
+}
+
+func (r *scoreRepository) UpsertVersioned(ctx context.Context, score *entity.UserScore) error {
+	query := `
+		INSERT INTO user_scores (user_id, rating, version, updated_at, active_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id)
+		DO UPDATE SET rating = $2, version = $3, updated_at = $4, active_at = $4
+		WHERE user_scores.version < $3
+	`
+	_, err := r.db.ExecContext(ctx, query, score.UserID, score.Rating, score.Version, score.UpdatedAt)
+	return err
+}
+
 func (r *scoreRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.UserScore, error) {
-	query := `SELECT user_id, rating, updated_at FROM user_scores WHERE user_id = $1`
+	query := `SELECT user_id, rating, version, updated_at FROM user_scores WHERE user_id = $1`
 	score := &entity.UserScore{}
-	err := r.db.QueryRowContext(ctx, query, userID).Scan(&score.UserID, &score.Rating, &score.UpdatedAt)
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&score.UserID, &score.Rating, &score.Version, &score.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -97,3 +136,159 @@ func (r *scoreRepository) GetAll(ctx context.Context) ([]*entity.UserScore, erro
 
 	return scores, rows.Err()
 }
+
+func (r *scoreRepository) StreamAll(ctx context.Context, batchSize int, fn func(batch []*entity.UserScore) error) error {
+	query := `
+		SELECT user_id, rating, updated_at FROM user_scores
+		WHERE user_id > $1
+		ORDER BY user_id
+		LIMIT $2
+	`
+
+	after := uuid.Nil
+	for {
+		rows, err := r.db.QueryContext(ctx, query, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		batch := make([]*entity.UserScore, 0, batchSize)
+		for rows.Next() {
+			score := &entity.UserScore{}
+			if err := rows.Scan(&score.UserID, &score.Rating, &score.UpdatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, score)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].UserID
+	}
+}
+
+func (r *scoreRepository) StreamInactive(ctx context.Context, activeBefore time.Time, minRating, batchSize int, fn func(batch []*entity.InactiveScore) error) error {
+	query := `
+		SELECT user_id, rating, version, active_at, decayed_at FROM user_scores
+		WHERE active_at < $1 AND rating > $2 AND user_id > $3
+		ORDER BY user_id
+		LIMIT $4
+	`
+
+	after := uuid.Nil
+	for {
+		rows, err := r.db.QueryContext(ctx, query, activeBefore, minRating, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		batch := make([]*entity.InactiveScore, 0, batchSize)
+		for rows.Next() {
+			score := &entity.InactiveScore{}
+			if err := rows.Scan(&score.UserID, &score.Rating, &score.Version, &score.ActiveAt, &score.DecayedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, score)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].UserID
+	}
+}
+
+// StreamStandings pages through the scores inside a read-only repeatable
+// read transaction, so every batch sees the same snapshot. Ranks are dense,
+// matching the live leaderboard.
+func (r *scoreRepository) StreamStandings(ctx context.Context, batchSize int, fn func(batch []entity.ExportEntry) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT s.user_id, u.username, s.rating, s.updated_at
+		FROM user_scores s JOIN users u ON u.id = s.user_id
+		WHERE (s.rating, s.user_id) < ($1, $2)
+		ORDER BY s.rating DESC, s.user_id DESC
+		LIMIT $3
+	`
+
+	afterRating, afterID := math.MaxInt32, uuid.Max
+	var rank int64
+	for {
+		rows, err := tx.QueryContext(ctx, query, afterRating, afterID, batchSize)
+		if err != nil {
+			return err
+		}
+
+		batch := make([]entity.ExportEntry, 0, batchSize)
+		for rows.Next() {
+			var userID uuid.UUID
+			var e entity.ExportEntry
+			if err := rows.Scan(&userID, &e.Username, &e.Rating, &e.UpdatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			if rank == 0 || e.Rating != afterRating {
+				rank++
+			}
+			e.Rank = rank
+			e.UserID = userID.String()
+			afterRating, afterID = e.Rating, userID
+			batch = append(batch, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (r *scoreRepository) ApplyDecay(ctx context.Context, score *entity.InactiveScore, toRating int, version int64, decayedAt, now time.Time) (bool, error) {
+	query := `
+		UPDATE user_scores SET rating = $5, version = $6, updated_at = $7, decayed_at = $8
+		WHERE user_id = $1 AND rating = $2 AND version = $3 AND active_at = $4
+	`
+	res, err := r.db.ExecContext(ctx, query, score.UserID, score.Rating, score.Version, score.ActiveAt, toRating, version, now, decayedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+type simulationRunRepository struct {
+	db *sql.DB
+}
+
+func NewSimulationRunRepository(db *sql.DB) repository.SimulationRunRepository {
+	return &simulationRunRepository{db: db}
+}
+
+const simulationRunColumns = `id, scenario, params, interval_ms, updates_per_tick, seed, record_file,
+	started_at, stopped_at, ticks, updates, errors, last_error`
+
+func (r *simulationRunRepository) Create(ctx context.Context, run *entity.SimulationRun) error {
+	params, err := json.Marshal(run.Params)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO simulation_runs (scenario, params, interval_ms, updates_per_tick, seed, record_file, started_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id
+	`
+	return r.db.QueryRowContext(ctx, query,
+		run.Scenario, params, run.IntervalMs, run.UpdatesPerTick, run.Seed, run.RecordFile, run.StartedAt,
+	).Scan(&run.ID)
+}
+
+func (r *simulationRunRepository) UpdateProgress(ctx context.Context, run *entity.SimulationRun) error {
+	query := `
+		UPDATE simulation_runs
+		SET ticks = $2, updates = $3, errors = $4, last_error = NULLIF($5, ''), stopped_at = $6
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, run.ID, run.Ticks, run.Updates, run.Errors, run.LastError, run.StoppedAt)
+	return err
+}
+
+func (r *simulationRunRepository) GetByID(ctx context.Context, id int64) (*entity.SimulationRun, error) {
+	query := `SELECT ` + simulationRunColumns + ` FROM simulation_runs WHERE id = $1`
+	run, err := scanSimulationRun(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+func (r *simulationRunRepository) List(ctx context.Context, limit, offset int) ([]*entity.SimulationRun, int64, error) {
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM simulation_runs`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + simulationRunColumns + `
+		FROM simulation_runs
+		ORDER BY started_at DESC, id DESC
+		LIMIT $1 OFFSET $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var runs []*entity.SimulationRun
+	for rows.Next() {
+		run, err := scanSimulationRun(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, total, rows.Err()
+}
+
+func scanSimulationRun(row rowScanner) (*entity.SimulationRun, error) {
+	run := &entity.SimulationRun{}
+	var params []byte
+	var recordFile, lastError sql.NullString
+	err := row.Scan(
+		&run.ID, &run.Scenario, &params, &run.IntervalMs, &run.UpdatesPerTick, &run.Seed, &recordFile,
+		&run.StartedAt, &run.StoppedAt, &run.Ticks, &run.Updates, &run.Errors, &lastError,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params, &run.Params); err != nil {
+		return nil, err
+	}
+	run.RecordFile = recordFile.String
+	run.LastError = lastError.String
+	return run, nil
+}
@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+// teamStreamBatchSize is the number of teams fetched per StreamMemberships
+// query, so no connection is held while the callback runs.
+const teamStreamBatchSize = 500
+
+type teamRepository struct {
+	db *sql.DB
+}
+
+func NewTeamRepository(db *sql.DB) repository.TeamRepository {
+	return &teamRepository{db: db}
+}
+
+func (r *teamRepository) Create(ctx context.Context, team *entity.Team) error {
+	query := `INSERT INTO teams (id, name, created_at) VALUES ($1, $2, $3)`
+	_, err := r.db.ExecContext(ctx, query, team.ID, team.Name, team.CreatedAt)
+	return err
+}
+
+func (r *teamRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Team, error) {
+	query := `SELECT id, name, created_at FROM teams WHERE id = $1`
+	return r.getOne(ctx, query, id)
+}
+
+func (r *teamRepository) GetByName(ctx context.Context, name string) (*entity.Team, error) {
+	query := `SELECT id, name, created_at FROM teams WHERE name = $1`
+	return r.getOne(ctx, query, name)
+}
+
+func (r *teamRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.Team, error) {
+	query := `
+		SELECT t.id, t.name, t.created_at
+		FROM team_members m
+		JOIN teams t ON t.id = m.team_id
+		WHERE m.user_id = $1
+	`
+	return r.getOne(ctx, query, userID)
+}
+
+func (r *teamRepository) getOne(ctx context.Context, query string, arg interface{}) (*entity.Team, error) {
+	team := &entity.Team{}
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(&team.ID, &team.Name, &team.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (r *teamRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entity.Team, error) {
+	if len(ids) == 0 {
+		return make(map[uuid.UUID]*entity.Team), nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(
+		`SELECT id, name, created_at FROM teams WHERE id IN (%s)`,
+		strings.Join(placeholders, ","),
+	)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := make(map[uuid.UUID]*entity.Team)
+	for rows.Next() {
+		team := &entity.Team{}
+		if err := rows.Scan(&team.ID, &team.Name, &team.CreatedAt); err != nil {
+			return nil, err
+		}
+		teams[team.ID] = team
+	}
+
+	return teams, rows.Err()
+}
+
+func (r *teamRepository) AddMember(ctx context.Context, teamID, userID uuid.UUID, joinedAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO team_members (user_id, team_id, joined_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+	res, err := r.db.ExecContext(ctx, query, userID, teamID, joinedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *teamRepository) RemoveMember(ctx context.Context, teamID, userID uuid.UUID) (bool, error) {
+	query := `DELETE FROM team_members WHERE user_id = $1 AND team_id = $2`
+	res, err := r.db.ExecContext(ctx, query, userID, teamID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *teamRepository) ListMembers(ctx context.Context, teamID uuid.UUID) ([]*entity.TeamMember, error) {
+	query := `
+		SELECT u.id, u.username, m.joined_at
+		FROM team_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = $1
+		ORDER BY m.joined_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*entity.TeamMember
+	for rows.Next() {
+		m := &entity.TeamMember{}
+		if err := rows.Scan(&m.UserID, &m.Username, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *teamRepository) StreamMemberships(ctx context.Context, fn func(teamID uuid.UUID, userIDs []uuid.UUID) error) error {
+	query := `
+		SELECT t.id, COALESCE(array_agg(m.user_id) FILTER (WHERE m.user_id IS NOT NULL), '{}')
+		FROM teams t
+		LEFT JOIN team_members m ON m.team_id = t.id
+		WHERE t.id > $1
+		GROUP BY t.id
+		ORDER BY t.id
+		LIMIT $2
+	`
+
+	type membership struct {
+		teamID  uuid.UUID
+		userIDs []uuid.UUID
+	}
+
+	after := uuid.Nil
+	for {
+		rows, err := r.db.QueryContext(ctx, query, after, teamStreamBatchSize)
+		if err != nil {
+			return err
+		}
+
+		var batch []membership
+		for rows.Next() {
+			var m membership
+			var raw pq.StringArray
+			if err := rows.Scan(&m.teamID, &raw); err != nil {
+				rows.Close()
+				return err
+			}
+			m.userIDs = make([]uuid.UUID, 0, len(raw))
+			for _, s := range raw {
+				id, err := uuid.Parse(s)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				m.userIDs = append(m.userIDs, id)
+			}
+			batch = append(batch, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, m := range batch {
+			if err := fn(m.teamID, m.userIDs); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < teamStreamBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].teamID
+	}
+}
@@ -3,10 +3,12 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/rankq/backend/internal/domain/entity"
 	"github.com/rankq/backend/internal/domain/repository"
 )
@@ -131,3 +133,67 @@ func (r *userRepository) Count(ctx context.Context) (int64, error) {
 	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
 	return count, err
 }
+
+func (r *userRepository) StreamAll(ctx context.Context, batchSize int, fn func(batch []*entity.User) error) error {
+	query := `
+		SELECT id, username, created_at FROM users
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	after := uuid.Nil
+	for {
+		rows, err := r.db.QueryContext(ctx, query, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		batch := make([]*entity.User, 0, batchSize)
+		for rows.Next() {
+			user := &entity.User{}
+			if err := rows.Scan(&user.ID, &user.Username, &user.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, user)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
+
+func (r *userRepository) Rename(ctx context.Context, id uuid.UUID, username string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE users SET username = $2 WHERE id = $1`, id, username)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return false, repository.ErrUsernameTaken
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
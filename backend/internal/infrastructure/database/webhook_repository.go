@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) repository.WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+const webhookColumns = `id, url, secret, events, top_n, thresholds, created_at`
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at,
+	last_status_code, last_error, redelivery_of, created_at, delivered_at`
+
+func (r *webhookRepository) Create(ctx context.Context, w *entity.Webhook) error {
+	query := `
+		INSERT INTO webhooks (id, url, secret, events, top_n, thresholds, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		w.ID, w.URL, w.Secret, pq.StringArray(w.Events), w.TopN, intArray(w.Thresholds), w.CreatedAt,
+	)
+	return err
+}
+
+func (r *webhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+	w, err := scanWebhook(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return w, err
+}
+
+func (r *webhookRepository) List(ctx context.Context) ([]*entity.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY created_at, id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*entity.Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (r *webhookRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *webhookRepository) CreateDeliveries(ctx context.Context, deliveries []*entity.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt_at, redelivery_of, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, d := range deliveries {
+		if err := stmt.QueryRowContext(ctx,
+			d.WebhookID, d.Event, []byte(d.Payload), d.Status, d.Attempts, d.NextAttemptAt, d.RedeliveryOf, d.CreatedAt,
+		).Scan(&d.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *webhookRepository) GetDelivery(ctx context.Context, id int64) (*entity.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+	d, err := scanDelivery(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]*entity.WebhookDelivery, int64, error) {
+	var total int64
+	countQuery := `SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1 AND ($2 = '' OR status = $2)`
+	if err := r.db.QueryRowContext(ctx, countQuery, webhookID, status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := r.db.QueryContext(ctx, query, webhookID, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var deliveries []*entity.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, total, rows.Err()
+}
+
+func (r *webhookRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]repository.DueDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d SET next_attempt_at = $2
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+			d.last_status_code, d.last_error, d.redelivery_of, d.created_at, d.delivered_at,
+			w.url, w.secret
+	`
+	rows, err := r.db.QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []repository.DueDelivery
+	for rows.Next() {
+		var item repository.DueDelivery
+		item.Delivery, err = scanDelivery(rows, &item.URL, &item.Secret)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, item)
+	}
+
+	return due, rows.Err()
+}
+
+func (r *webhookRepository) RecordAttempt(ctx context.Context, d *entity.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = $5,
+			last_error = NULLIF($6, ''), delivered_at = $7
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt,
+	)
+	return err
+}
+
+func scanWebhook(row rowScanner) (*entity.Webhook, error) {
+	w := &entity.Webhook{}
+	var events pq.StringArray
+	var thresholds pq.Int64Array
+	if err := row.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.TopN, &thresholds, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+	w.Events = []string(events)
+	w.Thresholds = make([]int, len(thresholds))
+	for i, t := range thresholds {
+		w.Thresholds[i] = int(t)
+	}
+	return w, nil
+}
+
+// scanDelivery scans the deliveryColumns followed by any extra columns.
+func scanDelivery(row rowScanner, extra ...interface{}) (*entity.WebhookDelivery, error) {
+	d := &entity.WebhookDelivery{}
+	var payload []byte
+	var statusCode sql.NullInt64
+	var lastError sql.NullString
+	var redeliveryOf sql.NullInt64
+	var deliveredAt sql.NullTime
+
+	dest := []interface{}{
+		&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&statusCode, &lastError, &redeliveryOf, &d.CreatedAt, &deliveredAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	d.Payload = payload
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		d.LastStatusCode = &code
+	}
+	d.LastError = lastError.String
+	if redeliveryOf.Valid {
+		d.RedeliveryOf = &redeliveryOf.Int64
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return d, nil
+}
+
+func intArray(values []int) pq.Int64Array {
+	out := make(pq.Int64Array, len(values))
+	for i, v := range values {
+		out[i] = int64(v)
+	}
+	return out
+}
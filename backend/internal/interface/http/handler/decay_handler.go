@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rankq/backend/internal/application/service"
+)
+
+type DecayHandler struct {
+	decayService *service.DecayService
+}
+
+func NewDecayHandler(decayService *service.DecayService) *DecayHandler {
+	return &DecayHandler{
+		decayService: decayService,
+	}
+}
+
+func (h *DecayHandler) Start(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "true"))
+
+	if err := h.decayService.Trigger(dryRun); err != nil {
+		if err == service.ErrDecayInProgress {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "decay run started", "dry_run": dryRun})
+}
+
+func (h *DecayHandler) Status(c *gin.Context) {
+	running, report, err := h.decayService.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"running": running,
+		"data":    report,
+	})
+}
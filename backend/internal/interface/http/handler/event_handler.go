@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/pkg/events"
+)
+
+type EventHandler struct {
+	eventService *service.EventService
+}
+
+func NewEventHandler(eventService *service.EventService) *EventHandler {
+	return &EventHandler{
+		eventService: eventService,
+	}
+}
+
+// GetEvents returns score events. With group and consumer it reads through
+// a consumer group and the caller acknowledges events via AckEvents;
+// otherwise it reads after the offset in after (or Last-Event-ID). wait
+// turns the request into a long poll. Clients sending
+// Accept: text/event-stream get a Server-Sent Events stream instead.
+func (h *EventHandler) GetEvents(c *gin.Context) {
+	group := c.Query("group")
+	consumer := c.Query("consumer")
+	if (group == "") != (consumer == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group and consumer must be given together"})
+		return
+	}
+
+	count, _ := strconv.ParseInt(c.DefaultQuery("count", "100"), 10, 64)
+	if count < 1 || count > 1000 {
+		count = 100
+	}
+
+	wait, err := time.ParseDuration(c.DefaultQuery("wait", "0s"))
+	if err != nil || wait < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wait duration"})
+		return
+	}
+
+	after := c.Query("after")
+	if after == "" {
+		after = c.GetHeader("Last-Event-ID")
+	}
+	if after == "" {
+		after = "$"
+	}
+	start := c.DefaultQuery("start", "$")
+
+	read := func(ctx context.Context, block time.Duration) ([]events.Event, error) {
+		if group != "" {
+			return h.eventService.ReadGroup(ctx, group, consumer, start, count, block)
+		}
+		evs, next, err := h.eventService.Read(ctx, after, count, block)
+		if err == nil {
+			after = next
+		}
+		return evs, err
+	}
+
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		h.stream(c, read)
+		return
+	}
+
+	// A long poll may outlast the server's write timeout.
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(h.eventService.MaxBlock() + 10*time.Second))
+
+	evs, err := read(c.Request.Context(), wait)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	meta := gin.H{"count": len(evs)}
+	if group == "" {
+		meta["next"] = after
+	}
+	c.JSON(http.StatusOK, gin.H{"data": evs, "meta": meta})
+}
+
+func (h *EventHandler) stream(c *gin.Context, read func(context.Context, time.Duration) ([]events.Event, error)) {
+	ctx := c.Request.Context()
+
+	// The first read surfaces bad parameters as a normal error response.
+	evs, err := read(ctx, 0)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	for {
+		for _, e := range evs {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(c.Writer, "id: %s\nevent: score\ndata: %s\n\n", e.ID, data)
+		}
+		if len(evs) == 0 {
+			// Keeps proxies from closing an idle connection.
+			fmt.Fprint(c.Writer, ": keepalive\n\n")
+		}
+		c.Writer.Flush()
+
+		if ctx.Err() != nil {
+			return
+		}
+		evs, err = read(ctx, h.eventService.MaxBlock())
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(c.Writer, "event: error\ndata: %q\n\n", err.Error())
+				c.Writer.Flush()
+			}
+			return
+		}
+	}
+}
+
+type AckEventsRequest struct {
+	Group string   `json:"group" binding:"required"`
+	IDs   []string `json:"ids" binding:"required,min=1"`
+}
+
+func (h *EventHandler) AckEvents(c *gin.Context) {
+	var req AckEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	acked, err := h.eventService.Ack(c.Request.Context(), req.Group, req.IDs)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"acked": acked})
+}
+
+type SetGroupOffsetRequest struct {
+	Offset string `json:"offset" binding:"required"`
+}
+
+func (h *EventHandler) SetGroupOffset(c *gin.Context) {
+	var req SetGroupOffsetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group := c.Param("group")
+	if err := h.eventService.SetGroupOffset(c.Request.Context(), group, req.Offset); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "group offset set", "group": group, "offset": req.Offset})
+}
+
+func (h *EventHandler) writeError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrInvalidOffset:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/application/service"
+)
+
+type FriendHandler struct {
+	friendService *service.FriendService
+}
+
+func NewFriendHandler(friendService *service.FriendService) *FriendHandler {
+	return &FriendHandler{
+		friendService: friendService,
+	}
+}
+
+type FriendRequestRequest struct {
+	FriendID string `json:"friend_id" binding:"required"`
+}
+
+func (h *FriendHandler) SendRequest(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req FriendRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	friendID, err := uuid.Parse(req.FriendID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid friend id"})
+		return
+	}
+
+	friendship, err := h.friendService.SendRequest(c.Request.Context(), id, friendID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": friendship})
+}
+
+func (h *FriendHandler) ListRequests(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	requests, err := h.friendService.ListIncomingRequests(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": requests})
+}
+
+func (h *FriendHandler) AcceptRequest(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	requesterID, err := uuid.Parse(c.Param("requester_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid requester id"})
+		return
+	}
+
+	friendship, err := h.friendService.AcceptRequest(c.Request.Context(), id, requesterID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": friendship})
+}
+
+func (h *FriendHandler) RemoveFriend(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	friendID, err := uuid.Parse(c.Param("friend_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid friend id"})
+		return
+	}
+
+	if err := h.friendService.RemoveFriendship(c.Request.Context(), id, friendID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "friendship removed"})
+}
+
+func (h *FriendHandler) GetFriendsLeaderboard(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	entries, err := h.friendService.GetFriendsLeaderboard(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": entries,
+		"meta": gin.H{
+			"user_id": id,
+			"total":   len(entries),
+		},
+	})
+}
+
+func (h *FriendHandler) writeError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case service.ErrFriendRequestNotFound, service.ErrFriendshipNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case service.ErrSelfFriendship:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrFriendshipExists:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
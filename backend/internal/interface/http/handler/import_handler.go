@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+)
+
+type ImportHandler struct {
+	importService *service.ImportService
+}
+
+func NewImportHandler(importService *service.ImportService) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+	}
+}
+
+// Import loads users from an uploaded file, sent either as a multipart form
+// field named file or as the raw request body. The format comes from the
+// format query parameter, else the file extension or content type.
+func (h *ImportHandler) Import(c *gin.Context) {
+	policy := c.DefaultQuery("on_duplicate", entity.ImportSkip)
+	if !entity.ValidImportPolicy(policy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrInvalidImportPolicy.Error()})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	// Large uploads can outlast the server's read and write timeouts.
+	rc := http.NewResponseController(c.Writer)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	var body io.Reader = c.Request.Body
+	format := importFormat(c.GetHeader("Content-Type"), "")
+	if strings.HasPrefix(c.GetHeader("Content-Type"), "multipart/form-data") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "missing file field"})
+			return
+		}
+		file, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+		body = file
+		format = importFormat(fh.Header.Get("Content-Type"), fh.Filename)
+	}
+	if f := c.Query("format"); f != "" {
+		format = f
+	}
+
+	report, err := h.importService.Import(c.Request.Context(), body, service.ImportOptions{
+		Format: format,
+		Policy: policy,
+		DryRun: dryRun,
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"data": report})
+	case err == service.ErrInvalidImportFormat || errors.Is(err, service.ErrInvalidImport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "data": report})
+	case err == repository.ErrImportDuplicates:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "data": report})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "data": report})
+	}
+}
+
+// importFormat guesses the format from a file name or content type,
+// defaulting to CSV.
+func importFormat(contentType, filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ndjson", ".jsonl":
+		return service.ExportNDJSON
+	case ".csv":
+		return service.ExportCSV
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case "application/x-ndjson", "application/jsonl", "application/jsonlines":
+			return service.ExportNDJSON
+		}
+	}
+	return service.ExportCSV
+}
@@ -1,14 +1,22 @@
 package handler
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
 )
 
+// exportBatchSize is how many leaderboard entries Export reads per page.
+const exportBatchSize = 1000
+
 type LeaderboardHandler struct {
 	leaderboardService *service.LeaderboardService
 }
@@ -39,9 +47,9 @@ func (h *LeaderboardHandler) GetLeaderboard(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"data": entries,
 		"meta": gin.H{
-			"page":       page,
-			"page_size":  pageSize,
-			"total":      total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
 			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
 		},
 	})
@@ -68,6 +76,29 @@ func (h *LeaderboardHandler) Search(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": results})
 }
 
+// Autocomplete returns ranked users whose username starts with q, ignoring
+// case; limit defaults to 10 and is at most 50.
+func (h *LeaderboardHandler) Autocomplete(c *gin.Context) {
+	prefix := c.Query("q")
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter 'q' is required"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	results, err := h.leaderboardService.Autocomplete(c.Request.Context(), prefix, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
 func (h *LeaderboardHandler) GetUserRank(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
@@ -90,8 +121,40 @@ func (h *LeaderboardHandler) GetUserRank(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": result})
 }
 
+// GetAround returns the user and its neighbours on the leaderboard; n, the
+// number of users on each side, defaults to 5 and is at most 50.
+func (h *LeaderboardHandler) GetAround(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	n, _ := strconv.Atoi(c.DefaultQuery("n", "5"))
+	if n < 0 || n > 50 {
+		n = 5
+	}
+
+	entries, err := h.leaderboardService.GetAround(c.Request.Context(), id, n)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if entries == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries})
+}
+
+// UpdateScoreRequest is a score write. Mode defaults to latest; in sum mode
+// Rating is the number of points to add and may be negative, and in cas mode
+// ExpectedVersion is required. Rating is a pointer so that 0 is accepted.
 type UpdateScoreRequest struct {
-	Rating int `json:"rating" binding:"required,min=100,max=5000"`
+	Rating          *int   `json:"rating"`
+	Mode            string `json:"mode"`
+	ExpectedVersion *int64 `json:"expected_version"`
 }
 
 func (h *LeaderboardHandler) UpdateScore(c *gin.Context) {
@@ -107,17 +170,158 @@ func (h *LeaderboardHandler) UpdateScore(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Rating == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rating is required"})
+		return
+	}
+	rating := *req.Rating
 
-	if err := h.leaderboardService.UpdateScore(c.Request.Context(), id, req.Rating); err != nil {
+	if req.Mode == "" {
+		req.Mode = entity.ScoreModeLatest
+	}
+	if !entity.ValidScoreMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be one of latest, best, sum, cas"})
+		return
+	}
+
+	write := entity.ScoreWrite{Mode: req.Mode, Value: rating}
+	if req.Mode == entity.ScoreModeCAS {
+		if req.ExpectedVersion == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expected_version is required in cas mode"})
+			return
+		}
+		write.ExpectedVersion = *req.ExpectedVersion
+	}
+
+	ratings := h.leaderboardService.Ratings()
+	if req.Mode == entity.ScoreModeSum {
+		if span := ratings.Max - ratings.Min; rating < -span || rating > span {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("rating must be between %d and %d in sum mode", -span, span)})
+			return
+		}
+	} else if !ratings.Contains(rating) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("rating must be between %d and %d", ratings.Min, ratings.Max)})
+		return
+	}
+
+	result, err := h.leaderboardService.UpdateScore(c.Request.Context(), id, write)
+	switch err {
+	case nil:
+	case service.ErrScoreRejected:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      err.Error(),
+			"anomaly_id": result.Anomaly.ID,
+			"matches":    result.Anomaly.Matches,
+		})
+		return
+	case service.ErrScoreQueued:
+		c.JSON(http.StatusAccepted, gin.H{"message": err.Error(), "anomaly_id": result.Anomaly.ID})
+		return
+	case service.ErrVersionConflict:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "data": result})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "score updated"
+	if !result.Changed {
+		message = "score unchanged"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "data": result})
+}
+
+func (h *LeaderboardHandler) GetScoreHistory(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	changes, err := h.leaderboardService.GetScoreHistory(c.Request.Context(), id, limit)
+	if err != nil {
+		if err == service.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "score updated"})
+	c.JSON(http.StatusOK, gin.H{"data": changes})
+}
+
+// Export streams the whole leaderboard as CSV or NDJSON, page by page.
+// Errors after the first page has been sent can only end the stream early.
+func (h *LeaderboardHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", service.ExportCSV)
+	contentType := map[string]string{
+		service.ExportCSV:    "text/csv; charset=utf-8",
+		service.ExportNDJSON: "application/x-ndjson",
+	}[format]
+	if contentType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
+		return
+	}
+
+	now := time.Now().UTC()
+	var w *service.ExportWriter
+	// The response starts with the first page, so that failing to read it
+	// can still be reported as an error.
+	start := func() error {
+		// An export may outlast the server's write timeout.
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="leaderboard-%s.%s"`, now.Format("20060102-150405"), format))
+		c.Status(http.StatusOK)
+
+		var err error
+		w, err = service.NewExportWriter(c.Writer, format, now)
+		return err
+	}
+
+	err := h.leaderboardService.ExportLeaderboard(c.Request.Context(), exportBatchSize, func(entries []entity.ExportEntry) error {
+		if w == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := w.Write(entries); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil && w == nil {
+		err = start()
+	}
+
+	if err != nil {
+		if w == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("export: stopped after %d entries: %v", w.Count(), err)
+		return
+	}
+
+	if err := w.Close(); err != nil {
+		log.Printf("export: %v", err)
+	}
 }
 
 func (h *LeaderboardHandler) Rebuild(c *gin.Context) {
 	if err := h.leaderboardService.RebuildFromPostgres(c.Request.Context()); err != nil {
+		if err == repository.ErrRebuildInProgress {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
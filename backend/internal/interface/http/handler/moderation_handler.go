@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
+)
+
+type ModerationHandler struct {
+	moderationService *service.ModerationService
+}
+
+func NewModerationHandler(moderationService *service.ModerationService) *ModerationHandler {
+	return &ModerationHandler{
+		moderationService: moderationService,
+	}
+}
+
+type DecisionRequest struct {
+	Reviewer string `json:"reviewer" binding:"required"`
+	Note     string `json:"note"`
+}
+
+func (h *ModerationHandler) ListAnomalies(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", entity.AnomalyStatusRejected, entity.AnomalyStatusPending, entity.AnomalyStatusFlagged,
+		entity.AnomalyStatusApproved, entity.AnomalyStatusReverted:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	anomalies, total, err := h.moderationService.ListAnomalies(c.Request.Context(), status, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": anomalies,
+		"meta": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+func (h *ModerationHandler) GetAnomaly(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid anomaly id"})
+		return
+	}
+
+	anomaly, err := h.moderationService.GetAnomaly(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": anomaly})
+}
+
+func (h *ModerationHandler) Approve(c *gin.Context) {
+	h.decide(c, true)
+}
+
+func (h *ModerationHandler) Reject(c *gin.Context) {
+	h.decide(c, false)
+}
+
+func (h *ModerationHandler) decide(c *gin.Context, approve bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid anomaly id"})
+		return
+	}
+
+	var req DecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	anomaly, err := h.moderationService.Decide(c.Request.Context(), id, approve, req.Reviewer, req.Note)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": anomaly})
+}
+
+func (h *ModerationHandler) writeError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrAnomalyNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case service.ErrAnomalyResolved, service.ErrScoreChanged, service.ErrNoPriorRating:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rankq/backend/internal/application/service"
+)
+
+type ReconcileHandler struct {
+	reconcileService *service.ReconcileService
+}
+
+func NewReconcileHandler(reconcileService *service.ReconcileService) *ReconcileHandler {
+	return &ReconcileHandler{
+		reconcileService: reconcileService,
+	}
+}
+
+func (h *ReconcileHandler) Start(c *gin.Context) {
+	repair, _ := strconv.ParseBool(c.DefaultQuery("repair", "false"))
+
+	if err := h.reconcileService.Trigger(repair); err != nil {
+		if err == service.ErrReconcileInProgress {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "reconciliation started", "repair": repair})
+}
+
+func (h *ReconcileHandler) Status(c *gin.Context) {
+	running, report, err := h.reconcileService.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"running": running,
+		"data":    report,
+	})
+}
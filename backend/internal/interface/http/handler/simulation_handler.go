@@ -1,13 +1,26 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rankq/backend/internal/application/service"
 )
 
+const (
+	// minStatusInterval bounds how often StreamStatus may push.
+	minStatusInterval = 100 * time.Millisecond
+	// stopWait is how long Stop waits for a run on another instance.
+	stopWait = 10 * time.Second
+)
+
 type SimulationHandler struct {
 	simulationService *service.SimulationService
 }
@@ -19,41 +32,182 @@ func NewSimulationHandler(simulationService *service.SimulationService) *Simulat
 }
 
 type StartSimulationRequest struct {
-	IntervalMs     int `json:"interval_ms"`
-	UpdatesPerTick int `json:"updates_per_tick"`
+	IntervalMs     int                `json:"interval_ms"`
+	UpdatesPerTick int                `json:"updates_per_tick"`
+	Scenario       string             `json:"scenario"`
+	Params         map[string]float64 `json:"params"`
+	Seed           *int64             `json:"seed"`
+	Record         bool               `json:"record"`
 }
 
 func (h *SimulationHandler) Start(c *gin.Context) {
+	limits := h.simulationService.Limits()
+
+	// A missing body or field takes the configured default.
 	var req StartSimulationRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		req.IntervalMs = 1000
-		req.UpdatesPerTick = 5
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.IntervalMs == 0 {
+		req.IntervalMs = int(limits.DefaultInterval.Milliseconds())
+	}
+	if req.UpdatesPerTick == 0 {
+		req.UpdatesPerTick = limits.DefaultUpdatesPerTick
 	}
 
-	if req.IntervalMs < 100 {
-		req.IntervalMs = 100
+	if minMs := int(limits.MinInterval.Milliseconds()); req.IntervalMs < minMs {
+		req.IntervalMs = minMs
 	}
 	if req.UpdatesPerTick < 1 {
 		req.UpdatesPerTick = 1
 	}
-	if req.UpdatesPerTick > 100 {
-		req.UpdatesPerTick = 100
+	if req.UpdatesPerTick > limits.MaxUpdatesPerTick {
+		req.UpdatesPerTick = limits.MaxUpdatesPerTick
 	}
 
-	h.simulationService.Start(c.Request.Context(), time.Duration(req.IntervalMs)*time.Millisecond, req.UpdatesPerTick)
+	run, err := h.simulationService.Start(c.Request.Context(), service.SimulationParams{
+		Interval:       time.Duration(req.IntervalMs) * time.Millisecond,
+		UpdatesPerTick: req.UpdatesPerTick,
+		Scenario:       req.Scenario,
+		ScenarioParams: req.Params,
+		Seed:           req.Seed,
+		Record:         req.Record,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidScenario), err == service.ErrRecordingDisabled:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err == service.ErrSimulationRunning:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":          "simulation started",
 		"interval_ms":      req.IntervalMs,
 		"updates_per_tick": req.UpdatesPerTick,
+		"scenario":         run.Scenario,
+		"params":           run.Params,
+		"seed":             run.Seed,
+		"run":              run,
 	})
 }
 
+func (h *SimulationHandler) Scenarios(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": service.SimulationScenarios()})
+}
+
+// Stop ends the simulation on whichever instance runs it. If that takes
+// longer than stopWait the stop is still pending and 202 is returned.
 func (h *SimulationHandler) Stop(c *gin.Context) {
-	h.simulationService.Stop()
+	ctx, cancel := context.WithTimeout(c.Request.Context(), stopWait)
+	defer cancel()
+
+	if err := h.simulationService.StopCluster(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusAccepted, gin.H{"message": "simulation stop requested"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "simulation stopped"})
 }
 
 func (h *SimulationHandler) Status(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"running": h.simulationService.IsRunning()})
+	status, err := h.simulationService.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// StreamStatus pushes the status as Server-Sent Events every interval
+// (default 1s) until the client disconnects.
+func (h *SimulationHandler) StreamStatus(c *gin.Context) {
+	interval, err := time.ParseDuration(c.DefaultQuery("interval", "1s"))
+	if err != nil || interval < minStatusInterval {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be a duration of at least " + minStatusInterval.String()})
+		return
+	}
+
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		status, err := h.simulationService.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(c.Writer, "event: error\ndata: %q\n\n", err.Error())
+		} else {
+			data, _ := json.Marshal(status)
+			fmt.Fprintf(c.Writer, "event: status\ndata: %s\n\n", data)
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *SimulationHandler) ListRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	runs, total, err := h.simulationService.ListRuns(c.Request.Context(), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": runs,
+		"meta": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+func (h *SimulationHandler) GetRun(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	run, err := h.simulationService.GetRun(c.Request.Context(), id)
+	if err != nil {
+		if err == service.ErrSimulationRunMissing {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": run})
 }
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// APIKey requires one of keys on the routes for which protected returns
+// true, given the request method and the route pattern. The key is read
+// from "Authorization: Bearer <key>" or the X-API-Key header. With no keys
+// every route is open. It must run before middleware that stores or
+// replays responses, such as Idempotency.
+func APIKey(keys []string, protected func(method, route string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(keys) == 0 || !protected(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		key := c.GetHeader(APIKeyHeader)
+		if auth := c.GetHeader("Authorization"); key == "" && strings.HasPrefix(auth, "Bearer ") {
+			key = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if !validKey(keys, key) {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid API key"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func validKey(keys []string, key string) bool {
+	if key == "" {
+		return false
+	}
+	valid := 0
+	for _, k := range keys {
+		valid |= subtle.ConstantTimeCompare([]byte(k), []byte(key))
+	}
+	return valid == 1
+}
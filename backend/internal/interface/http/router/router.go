@@ -1,6 +1,9 @@
 package router
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/rankq/backend/internal/interface/http/handler"
 	"github.com/rankq/backend/internal/interface/http/middleware"
@@ -11,26 +14,55 @@ type Router struct {
 	userHandler        *handler.UserHandler
 	leaderboardHandler *handler.LeaderboardHandler
 	simulationHandler  *handler.SimulationHandler
+	reconcileHandler   *handler.ReconcileHandler
+	friendHandler      *handler.FriendHandler
+	teamHandler        *handler.TeamHandler
+	decayHandler       *handler.DecayHandler
+	moderationHandler  *handler.ModerationHandler
+	webhookHandler     *handler.WebhookHandler
+	eventHandler       *handler.EventHandler
+	importHandler      *handler.ImportHandler
 }
 
 func NewRouter(
 	userHandler *handler.UserHandler,
 	leaderboardHandler *handler.LeaderboardHandler,
 	simulationHandler *handler.SimulationHandler,
+	reconcileHandler *handler.ReconcileHandler,
+	friendHandler *handler.FriendHandler,
+	teamHandler *handler.TeamHandler,
+	decayHandler *handler.DecayHandler,
+	moderationHandler *handler.ModerationHandler,
+	webhookHandler *handler.WebhookHandler,
+	eventHandler *handler.EventHandler,
+	importHandler *handler.ImportHandler,
 ) *Router {
 	return &Router{
 		userHandler:        userHandler,
 		leaderboardHandler: leaderboardHandler,
 		simulationHandler:  simulationHandler,
+		reconcileHandler:   reconcileHandler,
+		friendHandler:      friendHandler,
+		teamHandler:        teamHandler,
+		decayHandler:       decayHandler,
+		moderationHandler:  moderationHandler,
+		webhookHandler:     webhookHandler,
+		eventHandler:       eventHandler,
+		importHandler:      importHandler,
 	}
 }
 
-func (r *Router) Setup(mode string) *gin.Engine {
+// Setup builds the engine. Admin routes require one of apiKeys, if any are
+// given. Extra middleware runs after CORS and the key check, ahead of every
+// route.
+func (r *Router) Setup(mode string, corsOrigins, apiKeys []string, extra ...gin.HandlerFunc) *gin.Engine {
 	gin.SetMode(mode)
 	r.engine = gin.New()
 	r.engine.Use(gin.Recovery())
 	r.engine.Use(gin.Logger())
-	r.engine.Use(middleware.CORS())
+	r.engine.Use(middleware.CORS(corsOrigins))
+	r.engine.Use(middleware.APIKey(apiKeys, adminRoute))
+	r.engine.Use(extra...)
 
 	r.setupRoutes()
 
@@ -49,21 +81,93 @@ func (r *Router) setupRoutes() {
 		users.POST("", r.userHandler.CreateUser)
 		users.GET("", r.userHandler.ListUsers)
 		users.GET("/:id", r.userHandler.GetUser)
+		users.PATCH("/:id", r.userHandler.RenameUser)
+		users.DELETE("/:id", r.userHandler.DeleteUser)
+		users.POST("/:id/friends/requests", r.friendHandler.SendRequest)
+		users.GET("/:id/friends/requests", r.friendHandler.ListRequests)
+		users.POST("/:id/friends/requests/:requester_id/accept", r.friendHandler.AcceptRequest)
+		users.DELETE("/:id/friends/:friend_id", r.friendHandler.RemoveFriend)
+		users.GET("/:id/team", r.teamHandler.GetUserTeam)
+	}
+
+	teams := api.Group("/teams")
+	{
+		teams.POST("", r.teamHandler.CreateTeam)
+		teams.GET("", r.teamHandler.GetTeamLeaderboard)
+		teams.GET("/:id", r.teamHandler.GetTeam)
+		teams.GET("/:id/members", r.teamHandler.GetTeamMembers)
+		teams.POST("/:id/members", r.teamHandler.JoinTeam)
+		teams.DELETE("/:id/members/:user_id", r.teamHandler.LeaveTeam)
 	}
 
 	leaderboard := api.Group("/leaderboard")
 	{
 		leaderboard.GET("", r.leaderboardHandler.GetLeaderboard)
 		leaderboard.GET("/search", r.leaderboardHandler.Search)
+		leaderboard.GET("/autocomplete", r.leaderboardHandler.Autocomplete)
+		leaderboard.GET("/export", r.leaderboardHandler.Export)
 		leaderboard.GET("/user/:id", r.leaderboardHandler.GetUserRank)
+		leaderboard.GET("/user/:id/around", r.leaderboardHandler.GetAround)
+		leaderboard.GET("/user/:id/friends", r.friendHandler.GetFriendsLeaderboard)
+		leaderboard.GET("/user/:id/history", r.leaderboardHandler.GetScoreHistory)
 		leaderboard.PUT("/user/:id/score", r.leaderboardHandler.UpdateScore)
 		leaderboard.POST("/rebuild", r.leaderboardHandler.Rebuild)
 	}
 
+	events := api.Group("/events")
+	{
+		events.GET("", r.eventHandler.GetEvents)
+		events.POST("/ack", r.eventHandler.AckEvents)
+		events.PUT("/groups/:group", r.eventHandler.SetGroupOffset)
+	}
+
 	simulation := api.Group("/simulation")
 	{
 		simulation.POST("/start", r.simulationHandler.Start)
 		simulation.POST("/stop", r.simulationHandler.Stop)
 		simulation.GET("/status", r.simulationHandler.Status)
+		simulation.GET("/status/stream", r.simulationHandler.StreamStatus)
+		simulation.GET("/scenarios", r.simulationHandler.Scenarios)
+		simulation.GET("/runs", r.simulationHandler.ListRuns)
+		simulation.GET("/runs/:id", r.simulationHandler.GetRun)
+	}
+
+	admin := api.Group("/admin")
+	{
+		admin.POST("/reconcile", r.reconcileHandler.Start)
+		admin.GET("/reconcile", r.reconcileHandler.Status)
+		admin.POST("/teams/sync", r.teamHandler.Sync)
+		admin.POST("/decay", r.decayHandler.Start)
+		admin.GET("/decay", r.decayHandler.Status)
+		admin.GET("/anomalies", r.moderationHandler.ListAnomalies)
+		admin.GET("/anomalies/:id", r.moderationHandler.GetAnomaly)
+		admin.POST("/anomalies/:id/approve", r.moderationHandler.Approve)
+		admin.POST("/anomalies/:id/reject", r.moderationHandler.Reject)
+		admin.POST("/webhooks", r.webhookHandler.CreateWebhook)
+		admin.GET("/webhooks", r.webhookHandler.ListWebhooks)
+		admin.GET("/webhooks/:id", r.webhookHandler.GetWebhook)
+		admin.DELETE("/webhooks/:id", r.webhookHandler.DeleteWebhook)
+		admin.GET("/webhooks/:id/deliveries", r.webhookHandler.ListDeliveries)
+		admin.GET("/webhooks/:id/deliveries/:delivery_id", r.webhookHandler.GetDelivery)
+		admin.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", r.webhookHandler.Redeliver)
+		admin.POST("/import", r.importHandler.Import)
+	}
+}
+
+// adminRoute reports whether a route operates the deployment rather than
+// serving players, and so requires an API key.
+func adminRoute(method, route string) bool {
+	if strings.HasPrefix(route, "/api/v1/admin/") {
+		return true
+	}
+	switch method + " " + route {
+	case http.MethodDelete + " /api/v1/users/:id",
+		http.MethodPost + " /api/v1/leaderboard/rebuild",
+		http.MethodPost + " /api/v1/events/ack",
+		http.MethodPut + " /api/v1/events/groups/:group",
+		http.MethodPost + " /api/v1/simulation/start",
+		http.MethodPost + " /api/v1/simulation/stop":
+		return true
 	}
+	return false
 }
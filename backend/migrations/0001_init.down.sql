@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_scores;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,2 @@
+ALTER TABLE user_scores DROP CONSTRAINT IF EXISTS user_scores_rating_check;
+ALTER TABLE user_scores ADD CONSTRAINT user_scores_rating_check CHECK (rating >= 100 AND rating <= 5000);
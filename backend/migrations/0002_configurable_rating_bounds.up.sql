@@ -0,0 +1,4 @@
+-- Rating bounds are now configured in the application (rating.min/rating.max),
+-- so the schema only guards against negative ratings.
+ALTER TABLE user_scores DROP CONSTRAINT IF EXISTS user_scores_rating_check;
+ALTER TABLE user_scores ADD CONSTRAINT user_scores_rating_check CHECK (rating >= 0);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS score_history;
+DROP INDEX IF EXISTS idx_user_scores_active_at;
+ALTER TABLE user_scores DROP COLUMN IF EXISTS decayed_at;
+ALTER TABLE user_scores DROP COLUMN IF EXISTS active_at;
@@ -0,0 +1,20 @@
+-- active_at tracks the last real score change; decay updates rating and
+-- updated_at but leaves it alone so inactivity keeps accumulating.
+ALTER TABLE user_scores ADD COLUMN IF NOT EXISTS active_at TIMESTAMP;
+UPDATE user_scores SET active_at = updated_at WHERE active_at IS NULL;
+ALTER TABLE user_scores ALTER COLUMN active_at SET DEFAULT NOW();
+ALTER TABLE user_scores ALTER COLUMN active_at SET NOT NULL;
+ALTER TABLE user_scores ADD COLUMN IF NOT EXISTS decayed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_user_scores_active_at ON user_scores (active_at);
+
+CREATE TABLE IF NOT EXISTS score_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_rating INT,
+    new_rating INT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_score_history_user ON score_history (user_id, created_at DESC);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS score_anomalies (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_rating INT,
+    new_rating INT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('reject', 'review', 'flag')),
+    status TEXT NOT NULL CHECK (status IN ('rejected', 'pending', 'flagged', 'approved', 'reverted')),
+    matches JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMP,
+    reviewer TEXT,
+    note TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_score_anomalies_status ON score_anomalies (status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_score_anomalies_user ON score_anomalies (user_id, created_at DESC);
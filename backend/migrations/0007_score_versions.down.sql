@@ -0,0 +1 @@
+ALTER TABLE user_scores DROP COLUMN IF EXISTS version;
@@ -0,0 +1,2 @@
+-- version counts rating changes; conditional score writes compare against it.
+ALTER TABLE user_scores ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
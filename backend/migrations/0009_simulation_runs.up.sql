@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS simulation_runs (
+    id BIGSERIAL PRIMARY KEY,
+    scenario TEXT NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    interval_ms INT NOT NULL,
+    updates_per_tick INT NOT NULL,
+    seed BIGINT NOT NULL,
+    record_file TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    stopped_at TIMESTAMP,
+    ticks BIGINT NOT NULL DEFAULT 0,
+    updates BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_simulation_runs_started ON simulation_runs (started_at DESC);
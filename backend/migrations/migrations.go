@@ -0,0 +1,10 @@
+// Package migrations embeds the versioned SQL migrations so every binary
+// carries the schema it was built against.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,264 @@
+package client
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// Every call in this file needs an API key when the server has keys
+// configured.
+
+// ReconcileState is whether a consistency check is running, with the most
+// recent report.
+type ReconcileState struct {
+	Running bool             `json:"running"`
+	Report  *ReconcileReport `json:"data"`
+}
+
+// DecayState is whether a decay run is in progress, with the most recent
+// report.
+type DecayState struct {
+	Running bool         `json:"running"`
+	Report  *DecayReport `json:"data"`
+}
+
+// Decision is a moderator's verdict on an anomaly.
+type Decision struct {
+	Reviewer string `json:"reviewer"`
+	Note     string `json:"note,omitempty"`
+}
+
+// WebhookCreate subscribes URL to events. A zero TopN takes the server's
+// default of 10; an empty Secret makes the server generate one.
+type WebhookCreate struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`
+	Events     []string `json:"events"`
+	TopN       int      `json:"top_n,omitempty"`
+	Thresholds []int    `json:"thresholds,omitempty"`
+}
+
+// ImportOptions controls an import. Format is ExportCSV (the default) or
+// ExportNDJSON; OnDuplicate is skip (the default), overwrite or fail.
+type ImportOptions struct {
+	Format      string
+	OnDuplicate string
+	DryRun      bool
+}
+
+// StartReconcile starts a consistency check in the background; one
+// already running is a 409.
+func (c *Client) StartReconcile(ctx context.Context, repair bool) error {
+	query := url.Values{"repair": {strconv.FormatBool(repair)}}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/reconcile", query: query}, nil)
+	return err
+}
+
+func (c *Client) ReconcileStatus(ctx context.Context) (*ReconcileState, error) {
+	var state ReconcileState
+	if err := c.get(ctx, "/admin/reconcile", nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SyncTeams rebuilds every team score from Postgres membership.
+func (c *Client) SyncTeams(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/teams/sync", idempotent: true}, nil)
+	return err
+}
+
+// StartDecay starts a decay run in the background; one already running is
+// a 409.
+func (c *Client) StartDecay(ctx context.Context, dryRun bool) error {
+	query := url.Values{"dry_run": {strconv.FormatBool(dryRun)}}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/decay", query: query}, nil)
+	return err
+}
+
+func (c *Client) DecayStatus(ctx context.Context) (*DecayState, error) {
+	var state DecayState
+	if err := c.get(ctx, "/admin/decay", nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ListAnomalies returns a page of anomalies, newest first, optionally only
+// those with status.
+func (c *Client) ListAnomalies(ctx context.Context, status string, page, pageSize int) (*Page[ScoreAnomaly], error) {
+	query := pageQuery(page, pageSize)
+	if status != "" {
+		query.Set("status", status)
+	}
+	var resp Page[ScoreAnomaly]
+	if err := c.get(ctx, "/admin/anomalies", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) IterAnomalies(ctx context.Context, status string, pageSize int) iter.Seq2[ScoreAnomaly, error] {
+	return pages(ctx, func(ctx context.Context, page int) (*Page[ScoreAnomaly], error) {
+		return c.ListAnomalies(ctx, status, page, pageSize)
+	})
+}
+
+func (c *Client) GetAnomaly(ctx context.Context, id int64) (*ScoreAnomaly, error) {
+	var resp struct {
+		Data ScoreAnomaly `json:"data"`
+	}
+	if err := c.get(ctx, "/admin/anomalies/"+strconv.FormatInt(id, 10), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// ApproveAnomaly applies a queued update or acknowledges a flagged one.
+func (c *Client) ApproveAnomaly(ctx context.Context, id int64, d Decision) (*ScoreAnomaly, error) {
+	return c.decide(ctx, id, "approve", d)
+}
+
+// RejectAnomaly discards a queued update or reverts a flagged one.
+func (c *Client) RejectAnomaly(ctx context.Context, id int64, d Decision) (*ScoreAnomaly, error) {
+	return c.decide(ctx, id, "reject", d)
+}
+
+func (c *Client) decide(ctx context.Context, id int64, verdict string, d Decision) (*ScoreAnomaly, error) {
+	var resp struct {
+		Data ScoreAnomaly `json:"data"`
+	}
+	req := request{
+		method: http.MethodPost,
+		path:   "/admin/anomalies/" + strconv.FormatInt(id, 10) + "/" + verdict,
+		body:   d,
+	}
+	if _, err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// CreateWebhook subscribes a URL. The returned webhook carries the signing
+// secret, which the server returns only here.
+func (c *Client) CreateWebhook(ctx context.Context, create WebhookCreate) (*Webhook, error) {
+	var resp struct {
+		Data   Webhook `json:"data"`
+		Secret string  `json:"secret"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/webhooks", body: create}, &resp); err != nil {
+		return nil, err
+	}
+	resp.Data.Secret = resp.Secret
+	return &resp.Data, nil
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var resp struct {
+		Data []Webhook `json:"data"`
+	}
+	if err := c.get(ctx, "/admin/webhooks", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (c *Client) GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	var resp struct {
+		Data Webhook `json:"data"`
+	}
+	if err := c.get(ctx, "/admin/webhooks/"+id.String(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// DeleteWebhook deletes a webhook with its delivery log.
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/admin/webhooks/" + id.String(), idempotent: true}, nil)
+	return err
+}
+
+// ListDeliveries returns a page of a webhook's deliveries, newest first,
+// optionally only those with status.
+func (c *Client) ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, page, pageSize int) (*Page[WebhookDelivery], error) {
+	query := pageQuery(page, pageSize)
+	if status != "" {
+		query.Set("status", status)
+	}
+	var resp Page[WebhookDelivery]
+	if err := c.get(ctx, "/admin/webhooks/"+webhookID.String()+"/deliveries", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) IterDeliveries(ctx context.Context, webhookID uuid.UUID, status string, pageSize int) iter.Seq2[WebhookDelivery, error] {
+	return pages(ctx, func(ctx context.Context, page int) (*Page[WebhookDelivery], error) {
+		return c.ListDeliveries(ctx, webhookID, status, page, pageSize)
+	})
+}
+
+func (c *Client) GetDelivery(ctx context.Context, webhookID uuid.UUID, deliveryID int64) (*WebhookDelivery, error) {
+	var resp struct {
+		Data WebhookDelivery `json:"data"`
+	}
+	path := "/admin/webhooks/" + webhookID.String() + "/deliveries/" + strconv.FormatInt(deliveryID, 10)
+	if err := c.get(ctx, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// Redeliver queues the payload of a delivery again and returns the new
+// delivery.
+func (c *Client) Redeliver(ctx context.Context, webhookID uuid.UUID, deliveryID int64) (*WebhookDelivery, error) {
+	var resp struct {
+		Data WebhookDelivery `json:"data"`
+	}
+	req := request{
+		method: http.MethodPost,
+		path:   "/admin/webhooks/" + webhookID.String() + "/deliveries/" + strconv.FormatInt(deliveryID, 10) + "/redeliver",
+	}
+	if _, err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// Import uploads users from r. The report is returned with an error too
+// when the server produced one, e.g. for a 409 under OnDuplicate fail.
+// Imports are never retried, and only ctx bounds them.
+func (c *Client) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	query := url.Values{"dry_run": {strconv.FormatBool(opts.DryRun)}}
+	contentType := "text/csv"
+	if opts.Format != "" {
+		query.Set("format", opts.Format)
+		if opts.Format == ExportNDJSON {
+			contentType = "application/x-ndjson"
+		}
+	}
+	if opts.OnDuplicate != "" {
+		query.Set("on_duplicate", opts.OnDuplicate)
+	}
+
+	var resp struct {
+		Data *ImportReport `json:"data"`
+	}
+	h := *c.http
+	h.Timeout = 0
+	req := request{method: http.MethodPost, path: "/admin/import", query: query, raw: r, contentType: contentType}
+	httpResp, err := c.send(ctx, &h, req, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	err = decode(httpResp, &resp)
+	return resp.Data, err
+}
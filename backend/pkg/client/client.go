@@ -0,0 +1,282 @@
+// Package client is a typed Go client for the RankQ HTTP API under /api/v1.
+// Methods decode the data/meta envelope into the entity types the server
+// uses, return *Error for non-2xx answers and retry idempotent calls that
+// fail with a transient error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	// APIKey is sent as a bearer token. Admin routes require it when the
+	// server has API keys configured.
+	APIKey string
+	// HTTPClient defaults to a client with a 30s timeout.
+	HTTPClient *http.Client
+	// MaxRetries is how often an idempotent call is retried after a
+	// transport error, 429, 502, 503 or 504. Defaults to 3; negative
+	// disables retries.
+	MaxRetries int
+	// MinBackoff is the first wait between retries, which doubles with every
+	// attempt up to MaxBackoff. Each wait is drawn at random below the
+	// limit, and a Retry-After header overrides it. Default 100ms and 5s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+type Client struct {
+	baseURL string
+	http    *http.Client
+	opts    Options
+}
+
+// New returns a client for the server at baseURL, e.g.
+// http://localhost:8080.
+func New(baseURL string, opts Options) *Client {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(5*time.Second, opts.MinBackoff)
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    opts.HTTPClient,
+		opts:    opts,
+	}
+}
+
+// Error is a non-2xx answer. Message is the error field of the body; Body
+// holds the whole body.
+type Error struct {
+	StatusCode int
+	Message    string
+	Body       []byte
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("rankq: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("rankq: %d %s", e.StatusCode, e.Message)
+}
+
+// StatusCode returns the HTTP status of an *Error in err's chain, or 0.
+func StatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+func IsNotFound(err error) bool { return StatusCode(err) == http.StatusNotFound }
+func IsConflict(err error) bool { return StatusCode(err) == http.StatusConflict }
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes writes sent with ctx carry key as their
+// Idempotency-Key header. The server then answers a repeat with the stored
+// response, so such writes are retried like idempotent calls.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// request is one API call. Path is relative to /api/v1.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	// raw is sent as is instead of body; such calls are never retried.
+	raw         io.Reader
+	contentType string
+	// accept defaults to application/json.
+	accept string
+	// idempotent marks calls that are safe to repeat.
+	idempotent bool
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	_, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query, idempotent: true}, out)
+	return err
+}
+
+// do sends req, retrying it if allowed, and decodes a 2xx body into out.
+// The body of an error is decoded into out as well, since some errors carry
+// data. It returns the status of the last response.
+func (c *Client) do(ctx context.Context, req request, out any) (int, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return 0, err
+		}
+	}
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	retry := req.raw == nil && (req.idempotent || key != "")
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, c.http, req, body, key)
+		if err == nil && (!retry || !retryable(resp.StatusCode) || attempt >= c.opts.MaxRetries) {
+			defer resp.Body.Close()
+			return resp.StatusCode, decode(resp, out)
+		}
+		if err != nil && (!retry || ctx.Err() != nil || attempt >= c.opts.MaxRetries) {
+			return 0, err
+		}
+
+		wait := c.backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp); ok {
+				wait = min(d, c.opts.MaxBackoff)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, h *http.Client, req request, body []byte, key string) (*http.Response, error) {
+	u := c.baseURL + "/api/v1" + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+
+	var r io.Reader = req.raw
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if req.accept != "" {
+		httpReq.Header.Set("Accept", req.accept)
+	} else {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	switch {
+	case req.contentType != "":
+		httpReq.Header.Set("Content-Type", req.contentType)
+	case body != nil:
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.opts.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.opts.APIKey)
+	}
+	if key != "" && req.method != http.MethodGet {
+		httpReq.Header.Set("Idempotency-Key", key)
+	}
+	return h.Do(httpReq)
+}
+
+// open sends a GET and returns the response for the caller to read, such
+// as an export or an event stream. Reading may take longer than the
+// HTTPClient's timeout, so only ctx bounds it.
+func (c *Client) open(ctx context.Context, path string, query url.Values, accept string) (*http.Response, error) {
+	h := *c.http
+	h.Timeout = 0
+	resp, err := c.send(ctx, &h, request{method: http.MethodGet, path: path, query: query, accept: accept}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, decode(resp, nil)
+	}
+	if accept != "" && !strings.HasPrefix(resp.Header.Get("Content-Type"), accept) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("rankq: unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	return resp, nil
+}
+
+func decode(resp *http.Response, out any) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		apiErr := &Error{StatusCode: resp.StatusCode, Body: data}
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &body) == nil {
+			apiErr.Message = body.Error
+		}
+		if out != nil {
+			json.Unmarshal(data, out)
+		}
+		return apiErr
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("rankq: decoding response: %w", err)
+	}
+	return nil
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff is the wait before retry attempt+1: a random duration below
+// MinBackoff doubled attempt times, capped at MaxBackoff.
+func (c *Client) backoff(attempt int) time.Duration {
+	limit := c.opts.MaxBackoff
+	if attempt < 30 {
+		limit = min(c.opts.MinBackoff<<attempt, c.opts.MaxBackoff)
+	}
+	return time.Duration(rand.Int64N(int64(limit)) + 1)
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// Health reports whether the server answers its health check.
+func (c *Client) Health(ctx context.Context) error {
+	return c.get(ctx, "/health", nil, nil)
+}
@@ -0,0 +1,556 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/internal/domain/repository"
+	"github.com/rankq/backend/internal/infrastructure/cache"
+	"github.com/rankq/backend/internal/interface/http/handler"
+	"github.com/rankq/backend/internal/interface/http/middleware"
+	"github.com/rankq/backend/internal/interface/http/router"
+	"github.com/rankq/backend/pkg/client"
+	"github.com/redis/go-redis/v9"
+)
+
+const testAPIKey = "test-key-0123456789"
+
+// newServer serves the real router with Redis-backed leaderboard and
+// idempotency stores on miniredis and in-memory Postgres repositories.
+// Routes whose services need more than that are registered but not wired.
+// wrap, if set, sees every request before the router.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	gin.DefaultWriter = io.Discard
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	aggregate := entity.TeamAggregate{Mode: entity.TeamAggregateSum}
+	leaderboardRepo := cache.NewLeaderboardRepository(rdb, aggregate, 1000)
+	teamBoardRepo := cache.NewTeamLeaderboardRepository(rdb, aggregate)
+	usernameIndex := cache.NewUsernameIndex(rdb)
+	users := newUserRepo()
+	scores := &scoreRepo{scores: map[uuid.UUID]*entity.UserScore{}}
+	history := &historyRepo{}
+	ratings := entity.RatingRange{Min: 0, Max: 5000, Default: 1000}
+
+	userService := service.NewUserService(users, scores, leaderboardRepo, teamRepo{}, teamBoardRepo, usernameIndex, ratings)
+	leaderboardService := service.NewLeaderboardService(users, scores, leaderboardRepo, usernameIndex, history, nil, nil, ratings, service.RebuildOptions{})
+
+	r := router.NewRouter(
+		handler.NewUserHandler(userService),
+		handler.NewLeaderboardHandler(leaderboardService),
+		handler.NewSimulationHandler(nil),
+		handler.NewReconcileHandler(nil),
+		handler.NewFriendHandler(nil),
+		handler.NewTeamHandler(nil),
+		handler.NewDecayHandler(nil),
+		handler.NewModerationHandler(nil),
+		handler.NewWebhookHandler(nil),
+		handler.NewEventHandler(nil),
+		handler.NewImportHandler(nil),
+	)
+	idempotency := middleware.Idempotency(cache.NewIdempotencyStore(rdb), time.Hour, time.Minute)
+	var h http.Handler = r.Setup(gin.TestMode, []string{"*"}, []string{testAPIKey}, idempotency)
+	if wrap != nil {
+		h = wrap(h)
+	}
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newClient(srv *httptest.Server, apiKey string) *client.Client {
+	return client.New(srv.URL, client.Options{
+		APIKey:     apiKey,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+	})
+}
+
+func TestUsersAndLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(newServer(t, nil), "")
+
+	if err := c.Health(ctx); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+
+	alice, err := c.CreateUser(ctx, "alice", 1500)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	bob, err := c.CreateUser(ctx, "bob", 1200)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	carol, err := c.CreateUser(ctx, "carol", 0)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	got, err := c.GetUser(ctx, bob.ID)
+	if err != nil || got.Username != "bob" {
+		t.Fatalf("GetUser = %+v, %v; want bob", got, err)
+	}
+
+	page, err := c.GetLeaderboard(ctx, 1, 2)
+	if err != nil {
+		t.Fatalf("GetLeaderboard: %v", err)
+	}
+	if len(page.Data) != 2 || page.Data[0].Username != "alice" || page.Data[1].Username != "bob" {
+		t.Errorf("page 1 = %+v; want alice, bob", page.Data)
+	}
+	if page.Meta.Total != 3 || page.Meta.TotalPages != 2 {
+		t.Errorf("meta = %+v; want 3 users on 2 pages", page.Meta)
+	}
+
+	rank, err := c.GetUserRank(ctx, carol.ID)
+	if err != nil {
+		t.Fatalf("GetUserRank: %v", err)
+	}
+	if rank.Rating != 1000 || rank.Rank != 3 || rank.Version == nil {
+		t.Errorf("carol = %+v; want default rating 1000 at rank 3 with a version", rank)
+	}
+
+	out, err := c.UpdateScore(ctx, carol.ID, client.ScoreUpdate{Rating: 2000})
+	if err != nil {
+		t.Fatalf("UpdateScore: %v", err)
+	}
+	if !out.Result.Changed || out.Result.Rank != 1 || out.Queued {
+		t.Errorf("outcome = %+v; want a change to rank 1", out.Result)
+	}
+
+	stale := out.Result.Version - 1
+	out, err = c.UpdateScore(ctx, carol.ID, client.ScoreUpdate{Rating: 100, Mode: client.ScoreModeCAS, ExpectedVersion: &stale})
+	if !client.IsConflict(err) {
+		t.Fatalf("stale CAS err = %v; want 409", err)
+	}
+	if out == nil || out.Result == nil || out.Result.Rating != 2000 {
+		t.Errorf("conflict outcome = %+v; want the current rating 2000", out)
+	}
+
+	around, err := c.GetAround(ctx, alice.ID, 1)
+	if err != nil {
+		t.Fatalf("GetAround: %v", err)
+	}
+	if names := usernames(around); names != "carol,alice,bob" {
+		t.Errorf("around alice = %s; want carol,alice,bob", names)
+	}
+
+	results, err := c.Search(ctx, "AL", 10)
+	if err != nil || len(results) != 1 || results[0].Username != "alice" || results[0].Rank != 2 {
+		t.Errorf("Search = %+v, %v; want alice at rank 2", results, err)
+	}
+
+	if _, err := c.RenameUser(ctx, bob.ID, "Alina"); err != nil {
+		t.Fatalf("RenameUser: %v", err)
+	}
+	if _, err := c.RenameUser(ctx, carol.ID, "alice"); !client.IsConflict(err) {
+		t.Errorf("RenameUser to a taken name err = %v; want 409", err)
+	}
+	results, err = c.Autocomplete(ctx, "aL", 10)
+	if err != nil {
+		t.Fatalf("Autocomplete: %v", err)
+	}
+	if len(results) != 2 || results[0].Username != "alice" || results[0].Rank != 2 ||
+		results[1].Username != "Alina" || results[1].Rank != 3 {
+		t.Errorf("Autocomplete = %+v; want alice at rank 2 and Alina at rank 3", results)
+	}
+	if results, err := c.Autocomplete(ctx, "bo", 10); err != nil || len(results) != 0 {
+		t.Errorf("Autocomplete(bo) = %+v, %v; want nothing after the rename", results, err)
+	}
+
+	changes, err := c.GetScoreHistory(ctx, carol.ID, 10)
+	if err != nil || len(changes) != 1 || changes[0].NewRating != 2000 {
+		t.Errorf("GetScoreHistory = %+v, %v; want one change to 2000", changes, err)
+	}
+
+	scenarios, err := c.SimulationScenarios(ctx)
+	if err != nil || len(scenarios) == 0 {
+		t.Errorf("SimulationScenarios = %v, %v; want the built-in scenarios", scenarios, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t, nil)
+	c := newClient(srv, "")
+
+	_, err := c.GetUser(ctx, uuid.New())
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "user not found" {
+		t.Fatalf("GetUser(unknown) err = %v; want 404 user not found", err)
+	}
+	if !client.IsNotFound(err) {
+		t.Errorf("IsNotFound(%v) = false", err)
+	}
+
+	user, err := c.CreateUser(ctx, "dave", 1000)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := c.CreateUser(ctx, "dave", 1000); !client.IsConflict(err) {
+		t.Errorf("duplicate CreateUser err = %v; want 409", err)
+	}
+	if _, err := c.UpdateScore(ctx, user.ID, client.ScoreUpdate{Rating: 99999}); client.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("out-of-range UpdateScore err = %v; want 400", err)
+	}
+	if out, err := c.UpdateScore(ctx, user.ID, client.ScoreUpdate{Rating: 0, Mode: client.ScoreModeSum}); err != nil || out.Result.Changed {
+		t.Errorf("zero sum UpdateScore = %+v, %v; want an unchanged score", out, err)
+	}
+
+	if err := c.DeleteUser(ctx, user.ID); client.StatusCode(err) != http.StatusUnauthorized {
+		t.Fatalf("DeleteUser without a key err = %v; want 401", err)
+	}
+	admin := newClient(srv, testAPIKey)
+	if err := admin.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := c.GetUserRank(ctx, user.ID); !client.IsNotFound(err) {
+		t.Errorf("GetUserRank after delete err = %v; want 404", err)
+	}
+}
+
+func TestIterators(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(newServer(t, nil), "")
+
+	const n = 25
+	for i := range n {
+		if _, err := c.CreateUser(ctx, fmt.Sprintf("player%02d", i), 1000+i*10); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+
+	var ranks []int64
+	for entry, err := range c.IterLeaderboard(ctx, 10) {
+		if err != nil {
+			t.Fatalf("IterLeaderboard: %v", err)
+		}
+		ranks = append(ranks, entry.Rank)
+	}
+	if len(ranks) != n || ranks[0] != 1 || ranks[n-1] != n {
+		t.Errorf("IterLeaderboard ranks = %v; want 1..%d", ranks, n)
+	}
+
+	seen := map[string]bool{}
+	for user, err := range c.IterUsers(ctx, 7) {
+		if err != nil {
+			t.Fatalf("IterUsers: %v", err)
+		}
+		seen[user.Username] = true
+	}
+	if len(seen) != n {
+		t.Errorf("IterUsers saw %d users; want %d", len(seen), n)
+	}
+
+	count := 0
+	for range c.IterLeaderboard(ctx, 10) {
+		if count++; count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Errorf("stopped after %d entries; want 3", count)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+
+	// While failures is positive, requests fail with 503 before reaching the
+	// router.
+	var failures, requests atomic.Int32
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if failures.Add(-1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := newClient(srv, "")
+	fail := func(n int32) {
+		failures.Store(n)
+		requests.Store(0)
+	}
+
+	fail(2)
+	if _, err := c.GetLeaderboard(ctx, 1, 10); err != nil {
+		t.Fatalf("GetLeaderboard after two 503s: %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("GetLeaderboard sent %d requests; want 3", got)
+	}
+
+	fail(10)
+	if _, err := c.GetLeaderboard(ctx, 1, 10); client.StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("GetLeaderboard err = %v; want 503 once retries run out", err)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("GetLeaderboard sent %d requests; want 4", got)
+	}
+
+	fail(1)
+	if _, err := c.CreateUser(ctx, "erin", 1000); client.StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("CreateUser err = %v; want 503 without a retry", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("CreateUser sent %d requests; want 1", got)
+	}
+
+	fail(1)
+	user, err := c.CreateUser(client.WithIdempotencyKey(ctx, "create-erin"), "erin", 1000)
+	if err != nil {
+		t.Fatalf("CreateUser with an idempotency key: %v", err)
+	}
+	again, err := c.CreateUser(client.WithIdempotencyKey(ctx, "create-erin"), "erin", 1000)
+	if err != nil || again.ID != user.ID {
+		t.Errorf("repeated CreateUser = %v, %v; want the stored user %s", again, err, user.ID)
+	}
+
+	fail(1)
+	if _, err := c.UpdateScore(ctx, user.ID, client.ScoreUpdate{Rating: 5, Mode: client.ScoreModeSum}); client.StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("sum UpdateScore err = %v; want 503 without a retry", err)
+	}
+	if rank, err := c.GetUserRank(ctx, user.ID); err != nil || rank.Rating != 1000 {
+		t.Errorf("rating = %v, %v; want 1000 untouched", rank, err)
+	}
+
+	fail(10)
+	slow := client.New(srv.URL, client.Options{MinBackoff: time.Hour, MaxBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := slow.GetLeaderboard(ctx, 1, 10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetLeaderboard err = %v; want the context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("backoff ignored the context for %v", elapsed)
+	}
+}
+
+func usernames(entries []client.LeaderboardEntry) string {
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Username
+	}
+	return strings.Join(names, ",")
+}
+
+type userRepo struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]*entity.User
+	order []uuid.UUID
+}
+
+func newUserRepo() *userRepo {
+	return &userRepo{users: map[uuid.UUID]*entity.User{}}
+}
+
+func (r *userRepo) Create(_ context.Context, user *entity.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = user
+	r.order = append(r.order, user.ID)
+	return nil
+}
+
+func (r *userRepo) GetByID(_ context.Context, id uuid.UUID) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.users[id], nil
+}
+
+func (r *userRepo) GetByUsername(_ context.Context, username string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *userRepo) GetByIDs(_ context.Context, ids []uuid.UUID) (map[uuid.UUID]*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := make(map[uuid.UUID]*entity.User, len(ids))
+	for _, id := range ids {
+		if u, ok := r.users[id]; ok {
+			found[id] = u
+		}
+	}
+	return found, nil
+}
+
+func (r *userRepo) Search(_ context.Context, query string, limit int) ([]*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []*entity.User
+	for _, id := range r.order {
+		if u := r.users[id]; strings.Contains(strings.ToLower(u.Username), strings.ToLower(query)) && len(found) < limit {
+			found = append(found, u)
+		}
+	}
+	return found, nil
+}
+
+func (r *userRepo) List(_ context.Context, limit, offset int) ([]*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []*entity.User{}
+	for i := offset; i < len(r.order) && len(list) < limit; i++ {
+		list = append(list, r.users[r.order[i]])
+	}
+	return list, nil
+}
+
+func (r *userRepo) Count(context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return int64(len(r.order)), nil
+}
+
+func (r *userRepo) Delete(_ context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return false, nil
+	}
+	delete(r.users, id)
+	for i, other := range r.order {
+		if other == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return true, nil
+}
+
+func (r *userRepo) StreamAll(_ context.Context, _ int, fn func([]*entity.User) error) error {
+	r.mu.Lock()
+	batch := make([]*entity.User, 0, len(r.order))
+	for _, id := range r.order {
+		batch = append(batch, r.users[id])
+	}
+	r.mu.Unlock()
+	return fn(batch)
+}
+
+func (r *userRepo) Rename(_ context.Context, id uuid.UUID, username string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return false, nil
+	}
+	renamed := *u
+	renamed.Username = username
+	r.users[id] = &renamed
+	return true, nil
+}
+
+// scoreRepo implements the ScoreRepository methods the user and
+// leaderboard services call on the request path.
+type scoreRepo struct {
+	repository.ScoreRepository
+	mu     sync.Mutex
+	scores map[uuid.UUID]*entity.UserScore
+}
+
+func (r *scoreRepo) Upsert(_ context.Context, score *entity.UserScore) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *score
+	if old, ok := r.scores[score.UserID]; ok {
+		stored.Version = old.Version + 1
+	}
+	r.scores[score.UserID] = &stored
+	return nil
+}
+
+func (r *scoreRepo) UpsertVersioned(_ context.Context, score *entity.UserScore) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.scores[score.UserID]; !ok || old.Version < score.Version {
+		stored := *score
+		r.scores[score.UserID] = &stored
+	}
+	return nil
+}
+
+func (r *scoreRepo) GetByUserID(_ context.Context, userID uuid.UUID) (*entity.UserScore, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.scores[userID], nil
+}
+
+func (r *scoreRepo) GetByUserIDs(_ context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*entity.UserScore, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := make(map[uuid.UUID]*entity.UserScore, len(userIDs))
+	for _, id := range userIDs {
+		if s, ok := r.scores[id]; ok {
+			found[id] = s
+		}
+	}
+	return found, nil
+}
+
+type historyRepo struct {
+	mu      sync.Mutex
+	changes []*entity.ScoreChange
+}
+
+func (r *historyRepo) Record(_ context.Context, change *entity.ScoreChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change.ID = int64(len(r.changes) + 1)
+	r.changes = append(r.changes, change)
+	return nil
+}
+
+func (r *historyRepo) ListByUser(_ context.Context, userID uuid.UUID, limit int) ([]*entity.ScoreChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []*entity.ScoreChange
+	for _, c := range r.changes {
+		if c.UserID == userID {
+			found = append(found, c)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ID > found[j].ID })
+	return found[:min(limit, len(found))], nil
+}
+
+// teamRepo has no teams.
+type teamRepo struct {
+	repository.TeamRepository
+}
+
+func (teamRepo) GetByUserID(context.Context, uuid.UUID) (*entity.Team, error) {
+	return nil, nil
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// EventQuery selects score events. With Group and Consumer, events are
+// read through a consumer group and must be acknowledged with AckEvents;
+// Start is where a new group begins. Otherwise events after the offset
+// After are read ("$", the default, for new events only). Wait makes the
+// read a long poll, capped by the server; it must stay below the timeout
+// of the client's HTTPClient.
+type EventQuery struct {
+	After    string
+	Group    string
+	Consumer string
+	Start    string
+	Count    int
+	Wait     time.Duration
+}
+
+func (q EventQuery) values() url.Values {
+	v := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	set("after", q.After)
+	set("group", q.Group)
+	set("consumer", q.Consumer)
+	set("start", q.Start)
+	if q.Count > 0 {
+		v.Set("count", strconv.Itoa(q.Count))
+	}
+	if q.Wait > 0 {
+		v.Set("wait", q.Wait.String())
+	}
+	return v
+}
+
+// EventBatch is the result of one read. Next is the offset to continue
+// from; it is empty for group reads.
+type EventBatch struct {
+	Events []Event
+	Next   string
+}
+
+func (c *Client) GetEvents(ctx context.Context, q EventQuery) (*EventBatch, error) {
+	var resp struct {
+		Data []Event `json:"data"`
+		Meta struct {
+			Next string `json:"next"`
+		} `json:"meta"`
+	}
+	// Group reads move the group's offset, so repeating one would skip
+	// events until they are claimed again.
+	req := request{method: http.MethodGet, path: "/events", query: q.values(), idempotent: q.Group == ""}
+	if _, err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &EventBatch{Events: resp.Data, Next: resp.Meta.Next}, nil
+}
+
+// StreamEvents reads events as Server-Sent Events and calls fn with each
+// until ctx is cancelled, the server ends the stream or fn fails. Wait is
+// ignored. The stream is not resumed: to continue after an error, call it
+// again with After set to the last event's ID.
+func (c *Client) StreamEvents(ctx context.Context, q EventQuery, fn func(Event) error) error {
+	q.Wait = 0
+	resp, err := c.open(ctx, "/events", q.values(), "text/event-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(ev sseEvent) error {
+		switch ev.event {
+		case "score":
+			var e Event
+			if err := json.Unmarshal([]byte(ev.data), &e); err != nil {
+				return err
+			}
+			return fn(e)
+		case "error":
+			var msg string
+			json.Unmarshal([]byte(ev.data), &msg)
+			return errors.New("rankq: event stream: " + msg)
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// AckEvents acknowledges group events and returns how many were pending.
+func (c *Client) AckEvents(ctx context.Context, group string, ids []string) (int64, error) {
+	var resp struct {
+		Acked int64 `json:"acked"`
+	}
+	req := request{
+		method:     http.MethodPost,
+		path:       "/events/ack",
+		body:       map[string]any{"group": group, "ids": ids},
+		idempotent: true,
+	}
+	if _, err := c.do(ctx, req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Acked, nil
+}
+
+// SetGroupOffset moves a consumer group to offset; "0" replays the
+// retained stream and "$" skips to new events.
+func (c *Client) SetGroupOffset(ctx context.Context, group, offset string) error {
+	req := request{
+		method:     http.MethodPut,
+		path:       "/events/groups/" + url.PathEscape(group),
+		body:       map[string]string{"offset": offset},
+		idempotent: true,
+	}
+	_, err := c.do(ctx, req, nil)
+	return err
+}
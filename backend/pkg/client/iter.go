@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"iter"
+)
+
+// pages yields the items of a page-numbered list from page 1 on, fetching
+// one page at a time. It stops after the last page or the first error,
+// which it yields with a zero item. Items that move between pages while
+// the list is walked can be skipped or seen twice.
+func pages[T any](ctx context.Context, fetch func(ctx context.Context, page int) (*Page[T], error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page := 1; ; page++ {
+			p, err := fetch(ctx, page)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range p.Data {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if len(p.Data) == 0 || int64(page) >= p.Meta.TotalPages {
+				return
+			}
+		}
+	}
+}
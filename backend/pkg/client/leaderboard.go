@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// Export formats.
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+// ScoreUpdate is a score write. Mode defaults to ScoreModeLatest. In
+// ScoreModeSum Rating is added to the current rating; in ScoreModeCAS
+// ExpectedVersion is required.
+type ScoreUpdate struct {
+	Rating          int    `json:"rating"`
+	Mode            string `json:"mode,omitempty"`
+	ExpectedVersion *int64 `json:"expected_version,omitempty"`
+}
+
+// ScoreOutcome is how the server handled a score write. Result is set when
+// the write was applied or left the score unchanged, and on a version
+// conflict, where it holds the current rating and version. AnomalyID is set
+// when an anomaly rule queued or rejected the write.
+type ScoreOutcome struct {
+	Result    *ScoreWriteResult
+	Queued    bool
+	AnomalyID int64
+}
+
+func (c *Client) GetLeaderboard(ctx context.Context, page, pageSize int) (*Page[LeaderboardEntry], error) {
+	var resp Page[LeaderboardEntry]
+	if err := c.get(ctx, "/leaderboard", pageQuery(page, pageSize), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// IterLeaderboard yields the leaderboard from the top, pageSize entries
+// per request.
+func (c *Client) IterLeaderboard(ctx context.Context, pageSize int) iter.Seq2[LeaderboardEntry, error] {
+	return pages(ctx, func(ctx context.Context, page int) (*Page[LeaderboardEntry], error) {
+		return c.GetLeaderboard(ctx, page, pageSize)
+	})
+}
+
+// Search finds up to limit users whose username contains query.
+func (c *Client) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	var resp struct {
+		Data []SearchResult `json:"data"`
+	}
+	q := url.Values{"q": {query}, "limit": {strconv.Itoa(limit)}}
+	if err := c.get(ctx, "/leaderboard/search", q, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Autocomplete returns up to limit ranked users (at most 50) whose username
+// starts with prefix, ignoring case, in username order.
+func (c *Client) Autocomplete(ctx context.Context, prefix string, limit int) ([]SearchResult, error) {
+	var resp struct {
+		Data []SearchResult `json:"data"`
+	}
+	q := url.Values{"q": {prefix}, "limit": {strconv.Itoa(limit)}}
+	if err := c.get(ctx, "/leaderboard/autocomplete", q, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Export streams the whole leaderboard as ExportCSV or ExportNDJSON. The
+// caller must close the returned body. Only ctx bounds the download, not the
+// HTTPClient's timeout.
+func (c *Client) Export(ctx context.Context, format string) (io.ReadCloser, error) {
+	resp, err := c.open(ctx, "/leaderboard/export", url.Values{"format": {format}}, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// GetUserRank returns the user's rating, rank and score version; a user
+// missing from the leaderboard is a 404.
+func (c *Client) GetUserRank(ctx context.Context, userID uuid.UUID) (*SearchResult, error) {
+	var resp struct {
+		Data SearchResult `json:"data"`
+	}
+	if err := c.get(ctx, "/leaderboard/user/"+userID.String(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// GetAround returns the user's entry with up to n entries above and below
+// it (at most 50).
+func (c *Client) GetAround(ctx context.Context, userID uuid.UUID, n int) ([]LeaderboardEntry, error) {
+	var resp struct {
+		Data []LeaderboardEntry `json:"data"`
+	}
+	query := url.Values{"n": {strconv.Itoa(n)}}
+	if err := c.get(ctx, "/leaderboard/user/"+userID.String()+"/around", query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// GetFriendsLeaderboard ranks userID among its friends.
+func (c *Client) GetFriendsLeaderboard(ctx context.Context, userID uuid.UUID) ([]FriendLeaderboardEntry, error) {
+	var resp struct {
+		Data []FriendLeaderboardEntry `json:"data"`
+	}
+	if err := c.get(ctx, "/leaderboard/user/"+userID.String()+"/friends", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// GetScoreHistory returns up to limit rating changes (at most 500), newest
+// first.
+func (c *Client) GetScoreHistory(ctx context.Context, userID uuid.UUID, limit int) ([]ScoreChange, error) {
+	var resp struct {
+		Data []ScoreChange `json:"data"`
+	}
+	query := url.Values{"limit": {strconv.Itoa(limit)}}
+	if err := c.get(ctx, "/leaderboard/user/"+userID.String()+"/history", query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// UpdateScore writes a score. A write queued for review is not an error. A
+// rejected write returns a 422 *Error and a version conflict a 409 *Error,
+// each along with the outcome. Writes in ScoreModeSum are only retried
+// under WithIdempotencyKey, since repeating one adds the points again.
+func (c *Client) UpdateScore(ctx context.Context, userID uuid.UUID, update ScoreUpdate) (*ScoreOutcome, error) {
+	var resp struct {
+		Data      *ScoreWriteResult `json:"data"`
+		AnomalyID int64             `json:"anomaly_id"`
+	}
+	req := request{
+		method:     http.MethodPut,
+		path:       "/leaderboard/user/" + userID.String() + "/score",
+		body:       update,
+		idempotent: update.Mode != ScoreModeSum,
+	}
+	status, err := c.do(ctx, req, &resp)
+	if err != nil && status != http.StatusConflict && status != http.StatusUnprocessableEntity {
+		return nil, err
+	}
+	return &ScoreOutcome{
+		Result:    resp.Data,
+		Queued:    status == http.StatusAccepted,
+		AnomalyID: resp.AnomalyID,
+	}, err
+}
+
+// Rebuild reloads the Redis leaderboard from Postgres. It needs an API key
+// and can outlast the timeout of the client's HTTPClient on a large board.
+func (c *Client) Rebuild(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/leaderboard/rebuild"}, nil)
+	return err
+}
+
+func pageQuery(page, pageSize int) url.Values {
+	return url.Values{"page": {strconv.Itoa(page)}, "page_size": {strconv.Itoa(pageSize)}}
+}
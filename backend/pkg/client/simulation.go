@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SimulationStart configures a simulation run. Zero fields take the
+// server's defaults; Seed makes a run reproducible.
+type SimulationStart struct {
+	Scenario       string
+	Params         map[string]float64
+	Interval       time.Duration
+	UpdatesPerTick int
+	Seed           *int64
+	// Record writes a record file for replay, if the server allows it.
+	Record bool
+}
+
+// StartSimulation starts a run on the instance that receives the request.
+// It needs an API key; a run already active anywhere is a 409.
+func (c *Client) StartSimulation(ctx context.Context, start SimulationStart) (*SimulationRun, error) {
+	body := map[string]any{
+		"scenario":         start.Scenario,
+		"params":           start.Params,
+		"interval_ms":      start.Interval.Milliseconds(),
+		"updates_per_tick": start.UpdatesPerTick,
+		"record":           start.Record,
+	}
+	if start.Seed != nil {
+		body["seed"] = *start.Seed
+	}
+
+	var resp struct {
+		Run SimulationRun `json:"run"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/simulation/start", body: body}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Run, nil
+}
+
+// StopSimulation stops the run on whichever instance executes it. It
+// reports false if the run had not stopped when the server stopped
+// waiting; it then stops at the runner's next sync. It needs an API key.
+func (c *Client) StopSimulation(ctx context.Context) (bool, error) {
+	status, err := c.do(ctx, request{method: http.MethodPost, path: "/simulation/stop", idempotent: true}, nil)
+	if err != nil {
+		return false, err
+	}
+	return status != http.StatusAccepted, nil
+}
+
+// SimulationStatus returns the live status of the running or last run, as
+// seen from any instance.
+func (c *Client) SimulationStatus(ctx context.Context) (*SimulationStatus, error) {
+	var status SimulationStatus
+	if err := c.get(ctx, "/simulation/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// StreamSimulationStatus calls fn with the status every interval (at least
+// 100ms; 0 for the server's default of 1s) until ctx is cancelled or fn
+// fails.
+func (c *Client) StreamSimulationStatus(ctx context.Context, interval time.Duration, fn func(SimulationStatus) error) error {
+	query := url.Values{}
+	if interval > 0 {
+		query.Set("interval", interval.String())
+	}
+	resp, err := c.open(ctx, "/simulation/status/stream", query, "text/event-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(ev sseEvent) error {
+		switch ev.event {
+		case "status":
+			var status SimulationStatus
+			if err := json.Unmarshal([]byte(ev.data), &status); err != nil {
+				return err
+			}
+			return fn(status)
+		case "error":
+			var msg string
+			json.Unmarshal([]byte(ev.data), &msg)
+			return errors.New("rankq: status stream: " + msg)
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// SimulationScenarios lists the scenarios with their parameter defaults.
+func (c *Client) SimulationScenarios(ctx context.Context) ([]SimulationScenario, error) {
+	var resp struct {
+		Data []SimulationScenario `json:"data"`
+	}
+	if err := c.get(ctx, "/simulation/scenarios", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// ListSimulationRuns returns a page of past runs, newest first.
+func (c *Client) ListSimulationRuns(ctx context.Context, page, pageSize int) (*Page[SimulationRun], error) {
+	var resp Page[SimulationRun]
+	if err := c.get(ctx, "/simulation/runs", pageQuery(page, pageSize), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) IterSimulationRuns(ctx context.Context, pageSize int) iter.Seq2[SimulationRun, error] {
+	return pages(ctx, func(ctx context.Context, page int) (*Page[SimulationRun], error) {
+		return c.ListSimulationRuns(ctx, page, pageSize)
+	})
+}
+
+func (c *Client) GetSimulationRun(ctx context.Context, id int64) (*SimulationRun, error) {
+	var resp struct {
+		Data SimulationRun `json:"data"`
+	}
+	if err := c.get(ctx, "/simulation/runs/"+strconv.FormatInt(id, 10), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
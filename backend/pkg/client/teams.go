@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+func (c *Client) CreateTeam(ctx context.Context, name string) (*Team, error) {
+	var resp struct {
+		Data Team `json:"data"`
+	}
+	req := request{method: http.MethodPost, path: "/teams", body: map[string]string{"name": name}}
+	if _, err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// GetTeamLeaderboard returns a page of teams by score.
+func (c *Client) GetTeamLeaderboard(ctx context.Context, page, pageSize int) (*Page[TeamLeaderboardEntry], error) {
+	var resp Page[TeamLeaderboardEntry]
+	if err := c.get(ctx, "/teams", pageQuery(page, pageSize), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// IterTeamLeaderboard yields the team leaderboard from the top, pageSize
+// teams per request.
+func (c *Client) IterTeamLeaderboard(ctx context.Context, pageSize int) iter.Seq2[TeamLeaderboardEntry, error] {
+	return pages(ctx, func(ctx context.Context, page int) (*Page[TeamLeaderboardEntry], error) {
+		return c.GetTeamLeaderboard(ctx, page, pageSize)
+	})
+}
+
+func (c *Client) GetTeam(ctx context.Context, id uuid.UUID) (*TeamSummary, error) {
+	var resp struct {
+		Data TeamSummary `json:"data"`
+	}
+	if err := c.get(ctx, "/teams/"+id.String(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// GetTeamMembers returns the members by rating, with whether each counts
+// towards the team score.
+func (c *Client) GetTeamMembers(ctx context.Context, id uuid.UUID) ([]TeamMemberEntry, error) {
+	var resp struct {
+		Data []TeamMemberEntry `json:"data"`
+	}
+	if err := c.get(ctx, "/teams/"+id.String()+"/members", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// JoinTeam adds userID to a team; a user already in a team is a 409.
+func (c *Client) JoinTeam(ctx context.Context, teamID, userID uuid.UUID) error {
+	req := request{
+		method: http.MethodPost,
+		path:   "/teams/" + teamID.String() + "/members",
+		body:   map[string]string{"user_id": userID.String()},
+	}
+	_, err := c.do(ctx, req, nil)
+	return err
+}
+
+func (c *Client) LeaveTeam(ctx context.Context, teamID, userID uuid.UUID) error {
+	req := request{
+		method:     http.MethodDelete,
+		path:       "/teams/" + teamID.String() + "/members/" + userID.String(),
+		idempotent: true,
+	}
+	_, err := c.do(ctx, req, nil)
+	return err
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"github.com/rankq/backend/internal/application/service"
+	"github.com/rankq/backend/internal/domain/entity"
+	"github.com/rankq/backend/pkg/events"
+)
+
+// The API returns the server's own entity types; they are aliased here so
+// callers outside this module can name them.
+type (
+	User                   = entity.User
+	LeaderboardEntry       = entity.LeaderboardEntry
+	SearchResult           = entity.SearchResult
+	ScoreWriteResult       = entity.ScoreWriteResult
+	ScoreChange            = entity.ScoreChange
+	ExportEntry            = entity.ExportEntry
+	Friendship             = entity.Friendship
+	FriendLeaderboardEntry = entity.FriendLeaderboardEntry
+	Team                   = entity.Team
+	TeamSummary            = entity.TeamSummary
+	TeamLeaderboardEntry   = entity.TeamLeaderboardEntry
+	TeamMemberEntry        = entity.TeamMemberEntry
+	SimulationRun          = entity.SimulationRun
+	SimulationStatus       = entity.SimulationStatus
+	SimulationScenario     = service.SimulationScenarioInfo
+	ReconcileReport        = entity.ReconcileReport
+	DecayReport            = entity.DecayReport
+	ScoreAnomaly           = entity.ScoreAnomaly
+	AnomalyMatch           = entity.AnomalyMatch
+	Webhook                = entity.Webhook
+	WebhookDelivery        = entity.WebhookDelivery
+	ImportReport           = entity.ImportReport
+	Event                  = events.Event
+)
+
+// Score write modes.
+const (
+	ScoreModeLatest = entity.ScoreModeLatest
+	ScoreModeBest   = entity.ScoreModeBest
+	ScoreModeSum    = entity.ScoreModeSum
+	ScoreModeCAS    = entity.ScoreModeCAS
+)
+
+// PageMeta is the meta block of page-numbered lists.
+type PageMeta struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"total_pages"`
+}
+
+// Page is one page of a page-numbered list.
+type Page[T any] struct {
+	Data []T      `json:"data"`
+	Meta PageMeta `json:"meta"`
+}
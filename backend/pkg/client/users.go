@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// UserList is one slice of the user list.
+type UserList struct {
+	Data []User `json:"data"`
+	Meta struct {
+		Limit  int   `json:"limit"`
+		Offset int   `json:"offset"`
+		Total  int64 `json:"total"`
+	} `json:"meta"`
+}
+
+// CreateUser creates a user. An initialRating of 0 takes the server's
+// default rating.
+func (c *Client) CreateUser(ctx context.Context, username string, initialRating int) (*User, error) {
+	var resp struct {
+		Data User `json:"data"`
+	}
+	body := map[string]any{"username": username, "initial_rating": initialRating}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/users", body: body}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+func (c *Client) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
+	var resp struct {
+		Data User `json:"data"`
+	}
+	if err := c.get(ctx, "/users/"+id.String(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// ListUsers returns up to limit users (at most 100) after skipping offset.
+func (c *Client) ListUsers(ctx context.Context, limit, offset int) (*UserList, error) {
+	var resp UserList
+	query := url.Values{"limit": {strconv.Itoa(limit)}, "offset": {strconv.Itoa(offset)}}
+	if err := c.get(ctx, "/users", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// IterUsers yields every user, reading pageSize at a time.
+func (c *Client) IterUsers(ctx context.Context, pageSize int) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		for offset := 0; ; {
+			list, err := c.ListUsers(ctx, pageSize, offset)
+			if err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, u := range list.Data {
+				if !yield(u, nil) {
+					return
+				}
+			}
+			offset += len(list.Data)
+			if len(list.Data) == 0 || int64(offset) >= list.Meta.Total {
+				return
+			}
+		}
+	}
+}
+
+// RenameUser changes a user's username; one taken by another user is a 409.
+func (c *Client) RenameUser(ctx context.Context, id uuid.UUID, username string) (*User, error) {
+	var resp struct {
+		Data User `json:"data"`
+	}
+	req := request{
+		method:     http.MethodPatch,
+		path:       "/users/" + id.String(),
+		body:       map[string]string{"username": username},
+		idempotent: true,
+	}
+	if _, err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// DeleteUser deletes a user with its scores, history and friendships.
+// It needs an API key.
+func (c *Client) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/users/" + id.String(), idempotent: true}, nil)
+	return err
+}
+
+// SendFriendRequest asks friendID to become userID's friend. If friendID
+// already asked userID, the two become friends.
+func (c *Client) SendFriendRequest(ctx context.Context, userID, friendID uuid.UUID) (*Friendship, error) {
+	var resp struct {
+		Data Friendship `json:"data"`
+	}
+	req := request{
+		method: http.MethodPost,
+		path:   "/users/" + userID.String() + "/friends/requests",
+		body:   map[string]string{"friend_id": friendID.String()},
+	}
+	if _, err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// ListFriendRequests returns the pending requests sent to userID.
+func (c *Client) ListFriendRequests(ctx context.Context, userID uuid.UUID) ([]Friendship, error) {
+	var resp struct {
+		Data []Friendship `json:"data"`
+	}
+	if err := c.get(ctx, "/users/"+userID.String()+"/friends/requests", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (c *Client) AcceptFriendRequest(ctx context.Context, userID, requesterID uuid.UUID) (*Friendship, error) {
+	var resp struct {
+		Data Friendship `json:"data"`
+	}
+	req := request{
+		method: http.MethodPost,
+		path:   "/users/" + userID.String() + "/friends/requests/" + requesterID.String() + "/accept",
+	}
+	if _, err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// RemoveFriend ends a friendship, or declines or cancels a pending request.
+func (c *Client) RemoveFriend(ctx context.Context, userID, friendID uuid.UUID) error {
+	req := request{
+		method:     http.MethodDelete,
+		path:       "/users/" + userID.String() + "/friends/" + friendID.String(),
+		idempotent: true,
+	}
+	_, err := c.do(ctx, req, nil)
+	return err
+}
+
+// GetUserTeam returns the team userID belongs to; a user without a team
+// is a 404.
+func (c *Client) GetUserTeam(ctx context.Context, userID uuid.UUID) (*TeamSummary, error) {
+	var resp struct {
+		Data TeamSummary `json:"data"`
+	}
+	if err := c.get(ctx, "/users/"+userID.String()+"/team", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
@@ -1,64 +1,473 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/goccy/go-yaml"
 	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
 )
 
+// ConfigFileEnv names the environment variable holding the path of an
+// optional YAML or TOML config file. Values from the environment always
+// override values from the file.
+const ConfigFileEnv = "RANKQ_CONFIG"
+
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	Redis       RedisConfig       `yaml:"redis" toml:"redis"`
+	Rating      RatingConfig      `yaml:"rating" toml:"rating"`
+	Simulation  SimulationConfig  `yaml:"simulation" toml:"simulation"`
+	Reconcile   ReconcileConfig   `yaml:"reconcile" toml:"reconcile"`
+	Rebuild     RebuildConfig     `yaml:"rebuild" toml:"rebuild"`
+	Teams       TeamsConfig       `yaml:"teams" toml:"teams"`
+	Decay       DecayConfig       `yaml:"decay" toml:"decay"`
+	Anomaly     AnomalyConfig     `yaml:"anomaly" toml:"anomaly"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks" toml:"webhooks"`
+	Events      EventsConfig      `yaml:"events" toml:"events"`
+	Cluster     ClusterConfig     `yaml:"cluster" toml:"cluster"`
 }
 
 type ServerConfig struct {
-	Port string
-	Mode string
+	Port            string   `yaml:"port" toml:"port"`
+	Mode            string   `yaml:"mode" toml:"mode"`
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	CORSOrigins     []string `yaml:"cors_origins" toml:"cors_origins"`
+	// APIKeys guard the admin routes; with none, they are open.
+	APIKeys []string `yaml:"api_keys" toml:"api_keys"`
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host            string   `yaml:"host" toml:"host"`
+	Port            string   `yaml:"port" toml:"port"`
+	User            string   `yaml:"user" toml:"user"`
+	Password        string   `yaml:"password" toml:"password"`
+	DBName          string   `yaml:"name" toml:"name"`
+	SSLMode         string   `yaml:"sslmode" toml:"sslmode"`
+	AutoMigrate     bool     `yaml:"auto_migrate" toml:"auto_migrate"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
 }
 
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
 type RedisConfig struct {
-	Host     string
-	Port     string
-	Password string
-	DB       int
+	// Mode selects standalone (Host/Port), sentinel (Addrs are Sentinel
+	// nodes, MasterName required) or cluster (Addrs are seed nodes).
+	Mode             string         `yaml:"mode" toml:"mode"`
+	Host             string         `yaml:"host" toml:"host"`
+	Port             string         `yaml:"port" toml:"port"`
+	Addrs            []string       `yaml:"addrs" toml:"addrs"`
+	MasterName       string         `yaml:"master_name" toml:"master_name"`
+	Username         string         `yaml:"username" toml:"username"`
+	Password         string         `yaml:"password" toml:"password"`
+	SentinelUsername string         `yaml:"sentinel_username" toml:"sentinel_username"`
+	SentinelPassword string         `yaml:"sentinel_password" toml:"sentinel_password"`
+	DB               int            `yaml:"db" toml:"db"`
+	PoolSize         int            `yaml:"pool_size" toml:"pool_size"`
+	MinIdleConns     int            `yaml:"min_idle_conns" toml:"min_idle_conns"`
+	DialTimeout      Duration       `yaml:"dial_timeout" toml:"dial_timeout"`
+	ReadTimeout      Duration       `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout     Duration       `yaml:"write_timeout" toml:"write_timeout"`
+	TLS              RedisTLSConfig `yaml:"tls" toml:"tls"`
 }
 
-func Load() (*Config, error) {
-	_ = godotenv.Load()
+type RedisTLSConfig struct {
+	Enabled            bool   `yaml:"enabled" toml:"enabled"`
+	CAFile             string `yaml:"ca_file" toml:"ca_file"`
+	CertFile           string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile            string `yaml:"key_file" toml:"key_file"`
+	ServerName         string `yaml:"server_name" toml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" toml:"insecure_skip_verify"`
+}
+
+type RatingConfig struct {
+	Min     int `yaml:"min" toml:"min"`
+	Max     int `yaml:"max" toml:"max"`
+	Default int `yaml:"default" toml:"default"`
+}
+
+type SimulationConfig struct {
+	MinInterval           Duration `yaml:"min_interval" toml:"min_interval"`
+	DefaultInterval       Duration `yaml:"default_interval" toml:"default_interval"`
+	DefaultUpdatesPerTick int      `yaml:"default_updates_per_tick" toml:"default_updates_per_tick"`
+	MaxUpdatesPerTick     int      `yaml:"max_updates_per_tick" toml:"max_updates_per_tick"`
+	// RecordDir is where recorded runs are written; empty disables
+	// recording.
+	RecordDir string `yaml:"record_dir" toml:"record_dir"`
+}
+
+type ReconcileConfig struct {
+	Interval    Duration `yaml:"interval" toml:"interval"`
+	Repair      bool     `yaml:"repair" toml:"repair"`
+	BatchSize   int      `yaml:"batch_size" toml:"batch_size"`
+	GracePeriod Duration `yaml:"grace_period" toml:"grace_period"`
+}
+
+type RebuildConfig struct {
+	BatchSize int      `yaml:"batch_size" toml:"batch_size"`
+	LockTTL   Duration `yaml:"lock_ttl" toml:"lock_ttl"`
+}
 
-	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
+type TeamsConfig struct {
+	// Aggregate is how member ratings combine into a team score: sum, avg or
+	// top_k (the average of the TopK highest ratings).
+	Aggregate string `yaml:"aggregate" toml:"aggregate"`
+	TopK      int    `yaml:"top_k" toml:"top_k"`
+}
+
+type DecayConfig struct {
+	// Interval between scheduled decay runs; 0 disables the schedule.
+	Interval      Duration `yaml:"interval" toml:"interval"`
+	DryRun        bool     `yaml:"dry_run" toml:"dry_run"`
+	InactiveAfter Duration `yaml:"inactive_after" toml:"inactive_after"`
+	// Mode is fixed (Amount points per day) or percent (Amount percent of the
+	// rating per day).
+	Mode      string  `yaml:"mode" toml:"mode"`
+	Amount    float64 `yaml:"amount" toml:"amount"`
+	Floor     int     `yaml:"floor" toml:"floor"`
+	BatchSize int     `yaml:"batch_size" toml:"batch_size"`
+}
+
+// AnomalyConfig sets up the rules checked before client score updates. A
+// zero limit disables its rule; each action is reject, review or flag.
+type AnomalyConfig struct {
+	MaxDelta            int     `yaml:"max_delta" toml:"max_delta"`
+	MaxDeltaAction      string  `yaml:"max_delta_action" toml:"max_delta_action"`
+	MaxUpdatesPerMinute int     `yaml:"max_updates_per_minute" toml:"max_updates_per_minute"`
+	UpdateRateAction    string  `yaml:"update_rate_action" toml:"update_rate_action"`
+	ZScoreThreshold     float64 `yaml:"zscore_threshold" toml:"zscore_threshold"`
+	ZScoreWindow        int     `yaml:"zscore_window" toml:"zscore_window"`
+	ZScoreMinSamples    int     `yaml:"zscore_min_samples" toml:"zscore_min_samples"`
+	ZScoreAction        string  `yaml:"zscore_action" toml:"zscore_action"`
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long a completed response is replayed for its key.
+	TTL Duration `yaml:"ttl" toml:"ttl"`
+	// LockTTL bounds how long a key stays reserved by a request that never
+	// finishes, e.g. because the process died.
+	LockTTL Duration `yaml:"lock_ttl" toml:"lock_ttl"`
+}
+
+// WebhooksConfig controls webhook delivery. A failed delivery is retried
+// after Backoff, doubling each attempt up to MaxBackoff, until MaxAttempts.
+type WebhooksConfig struct {
+	MaxAttempts  int      `yaml:"max_attempts" toml:"max_attempts"`
+	Backoff      Duration `yaml:"backoff" toml:"backoff"`
+	MaxBackoff   Duration `yaml:"max_backoff" toml:"max_backoff"`
+	Timeout      Duration `yaml:"timeout" toml:"timeout"`
+	PollInterval Duration `yaml:"poll_interval" toml:"poll_interval"`
+	Workers      int      `yaml:"workers" toml:"workers"`
+}
+
+// ClusterConfig identifies this API instance among its replicas. LeaseTTL
+// is how long the leader's lease, and the lease of the instance running the
+// simulation, outlive their last renewal.
+type ClusterConfig struct {
+	// NodeID defaults to the host name and process ID.
+	NodeID   string   `yaml:"node_id" toml:"node_id"`
+	LeaseTTL Duration `yaml:"lease_ttl" toml:"lease_ttl"`
+}
+
+type EventsConfig struct {
+	// MaxLen is roughly how many score events the stream keeps; 0 stops
+	// publishing them.
+	MaxLen int `yaml:"max_len" toml:"max_len"`
+	// ClaimIdle is how long a delivered but unacknowledged event waits before
+	// another consumer in its group may take it over.
+	ClaimIdle Duration `yaml:"claim_idle" toml:"claim_idle"`
+	// MaxBlock caps how long GET /events waits for new events.
+	MaxBlock Duration `yaml:"max_block" toml:"max_block"`
+}
 
+// Duration is a time.Duration that reads and writes as "5s" in config files.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// Default returns the configuration used when neither a file nor the
+// environment sets a value.
+func Default() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Mode: getEnv("GIN_MODE", "debug"),
+			Port:            "8080",
+			Mode:            "debug",
+			ReadTimeout:     Duration{10 * time.Second},
+			WriteTimeout:    Duration{30 * time.Second},
+			IdleTimeout:     Duration{60 * time.Second},
+			ShutdownTimeout: Duration{5 * time.Second},
+			CORSOrigins:     []string{"*"},
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
-			DBName:   getEnv("DB_NAME", "rankq"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Host:            "localhost",
+			Port:            "5432",
+			User:            "postgres",
+			Password:        "postgres",
+			DBName:          "rankq",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration{30 * time.Minute},
 		},
 		Redis: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnv("REDIS_PORT", "6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       redisDB,
+			Mode:         RedisModeStandalone,
+			Host:         "localhost",
+			Port:         "6379",
+			PoolSize:     20,
+			MinIdleConns: 2,
+			DialTimeout:  Duration{5 * time.Second},
+			ReadTimeout:  Duration{3 * time.Second},
+			WriteTimeout: Duration{3 * time.Second},
+		},
+		Rating: RatingConfig{
+			Min:     100,
+			Max:     5000,
+			Default: 1000,
+		},
+		Simulation: SimulationConfig{
+			MinInterval:           Duration{100 * time.Millisecond},
+			DefaultInterval:       Duration{time.Second},
+			DefaultUpdatesPerTick: 5,
+			MaxUpdatesPerTick:     100,
+			RecordDir:             "simulation-runs",
+		},
+		Reconcile: ReconcileConfig{
+			Interval:    Duration{time.Hour},
+			BatchSize:   1000,
+			GracePeriod: Duration{10 * time.Second},
+		},
+		Rebuild: RebuildConfig{
+			BatchSize: 5000,
+			LockTTL:   Duration{time.Minute},
+		},
+		Teams: TeamsConfig{
+			Aggregate: "sum",
+			TopK:      5,
+		},
+		Decay: DecayConfig{
+			InactiveAfter: Duration{14 * 24 * time.Hour},
+			Mode:          "fixed",
+			Amount:        10,
+			Floor:         1000,
+			BatchSize:     1000,
 		},
-	}, nil
+		Anomaly: AnomalyConfig{
+			MaxDelta:            1000,
+			MaxDeltaAction:      "flag",
+			MaxUpdatesPerMinute: 60,
+			UpdateRateAction:    "flag",
+			ZScoreThreshold:     4,
+			ZScoreWindow:        20,
+			ZScoreMinSamples:    5,
+			ZScoreAction:        "flag",
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:     Duration{24 * time.Hour},
+			LockTTL: Duration{time.Minute},
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:  8,
+			Backoff:      Duration{10 * time.Second},
+			MaxBackoff:   Duration{time.Hour},
+			Timeout:      Duration{10 * time.Second},
+			PollInterval: Duration{time.Second},
+			Workers:      4,
+		},
+		Events: EventsConfig{
+			MaxLen:    1000000,
+			ClaimIdle: Duration{time.Minute},
+			MaxBlock:  Duration{30 * time.Second},
+		},
+		Cluster: ClusterConfig{
+			LeaseTTL: Duration{15 * time.Second},
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the file named by
+// RANKQ_CONFIG (if any) and the environment, then validates it.
+func Load() (*Config, error) {
+	return LoadFile(os.Getenv(ConfigFileEnv))
+}
+
+// LoadFile is like Load but reads the config file at path. An empty path
+// skips the file.
+func LoadFile(path string) (*Config, error) {
+	_ = godotenv.Load()
+
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.decodeFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	verr := &ValidationError{}
+	cfg.applyEnv(verr)
+	cfg.validate(verr)
+	if len(verr.Fields) > 0 {
+		return nil, verr
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) decodeFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(bytes.NewReader(data), yaml.DisallowUnknownField()).Decode(c)
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			err = fmt.Errorf("unknown fields:\n%s", strict.String())
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q (want .yaml, .yml or .toml)", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) applyEnv(verr *ValidationError) {
+	envString("SERVER_PORT", &c.Server.Port)
+	envString("GIN_MODE", &c.Server.Mode)
+	envDuration(verr, "SERVER_READ_TIMEOUT", &c.Server.ReadTimeout)
+	envDuration(verr, "SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	envDuration(verr, "SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	envDuration(verr, "SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	envList("CORS_ALLOWED_ORIGINS", &c.Server.CORSOrigins)
+	envList("API_KEYS", &c.Server.APIKeys)
+
+	envString("DB_HOST", &c.Database.Host)
+	envString("DB_PORT", &c.Database.Port)
+	envString("DB_USER", &c.Database.User)
+	envString("DB_PASSWORD", &c.Database.Password)
+	envString("DB_NAME", &c.Database.DBName)
+	envString("DB_SSLMODE", &c.Database.SSLMode)
+	envBool(verr, "DB_AUTO_MIGRATE", &c.Database.AutoMigrate)
+	envInt(verr, "DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	envInt(verr, "DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	envDuration(verr, "DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+
+	envString("REDIS_MODE", &c.Redis.Mode)
+	envString("REDIS_HOST", &c.Redis.Host)
+	envString("REDIS_PORT", &c.Redis.Port)
+	envList("REDIS_ADDRS", &c.Redis.Addrs)
+	envString("REDIS_MASTER_NAME", &c.Redis.MasterName)
+	envString("REDIS_USERNAME", &c.Redis.Username)
+	envString("REDIS_PASSWORD", &c.Redis.Password)
+	envString("REDIS_SENTINEL_USERNAME", &c.Redis.SentinelUsername)
+	envString("REDIS_SENTINEL_PASSWORD", &c.Redis.SentinelPassword)
+	envInt(verr, "REDIS_DB", &c.Redis.DB)
+	envInt(verr, "REDIS_POOL_SIZE", &c.Redis.PoolSize)
+	envInt(verr, "REDIS_MIN_IDLE_CONNS", &c.Redis.MinIdleConns)
+	envDuration(verr, "REDIS_DIAL_TIMEOUT", &c.Redis.DialTimeout)
+	envDuration(verr, "REDIS_READ_TIMEOUT", &c.Redis.ReadTimeout)
+	envDuration(verr, "REDIS_WRITE_TIMEOUT", &c.Redis.WriteTimeout)
+	envBool(verr, "REDIS_TLS_ENABLED", &c.Redis.TLS.Enabled)
+	envString("REDIS_TLS_CA_FILE", &c.Redis.TLS.CAFile)
+	envString("REDIS_TLS_CERT_FILE", &c.Redis.TLS.CertFile)
+	envString("REDIS_TLS_KEY_FILE", &c.Redis.TLS.KeyFile)
+	envString("REDIS_TLS_SERVER_NAME", &c.Redis.TLS.ServerName)
+	envBool(verr, "REDIS_TLS_INSECURE_SKIP_VERIFY", &c.Redis.TLS.InsecureSkipVerify)
+
+	envInt(verr, "RATING_MIN", &c.Rating.Min)
+	envInt(verr, "RATING_MAX", &c.Rating.Max)
+	envInt(verr, "RATING_DEFAULT", &c.Rating.Default)
+
+	envDuration(verr, "SIMULATION_MIN_INTERVAL", &c.Simulation.MinInterval)
+	envDuration(verr, "SIMULATION_DEFAULT_INTERVAL", &c.Simulation.DefaultInterval)
+	envInt(verr, "SIMULATION_DEFAULT_UPDATES_PER_TICK", &c.Simulation.DefaultUpdatesPerTick)
+	envInt(verr, "SIMULATION_MAX_UPDATES_PER_TICK", &c.Simulation.MaxUpdatesPerTick)
+	envString("SIMULATION_RECORD_DIR", &c.Simulation.RecordDir)
+
+	envDuration(verr, "RECONCILE_INTERVAL", &c.Reconcile.Interval)
+	envBool(verr, "RECONCILE_REPAIR", &c.Reconcile.Repair)
+	envInt(verr, "RECONCILE_BATCH_SIZE", &c.Reconcile.BatchSize)
+	envDuration(verr, "RECONCILE_GRACE_PERIOD", &c.Reconcile.GracePeriod)
+
+	envInt(verr, "REBUILD_BATCH_SIZE", &c.Rebuild.BatchSize)
+	envDuration(verr, "REBUILD_LOCK_TTL", &c.Rebuild.LockTTL)
+
+	envString("TEAM_AGGREGATE", &c.Teams.Aggregate)
+	envInt(verr, "TEAM_TOP_K", &c.Teams.TopK)
+
+	envDuration(verr, "DECAY_INTERVAL", &c.Decay.Interval)
+	envBool(verr, "DECAY_DRY_RUN", &c.Decay.DryRun)
+	envDuration(verr, "DECAY_INACTIVE_AFTER", &c.Decay.InactiveAfter)
+	envString("DECAY_MODE", &c.Decay.Mode)
+	envFloat(verr, "DECAY_AMOUNT", &c.Decay.Amount)
+	envInt(verr, "DECAY_FLOOR", &c.Decay.Floor)
+	envInt(verr, "DECAY_BATCH_SIZE", &c.Decay.BatchSize)
+
+	envInt(verr, "ANOMALY_MAX_DELTA", &c.Anomaly.MaxDelta)
+	envString("ANOMALY_MAX_DELTA_ACTION", &c.Anomaly.MaxDeltaAction)
+	envInt(verr, "ANOMALY_MAX_UPDATES_PER_MINUTE", &c.Anomaly.MaxUpdatesPerMinute)
+	envString("ANOMALY_UPDATE_RATE_ACTION", &c.Anomaly.UpdateRateAction)
+	envFloat(verr, "ANOMALY_ZSCORE_THRESHOLD", &c.Anomaly.ZScoreThreshold)
+	envInt(verr, "ANOMALY_ZSCORE_WINDOW", &c.Anomaly.ZScoreWindow)
+	envInt(verr, "ANOMALY_ZSCORE_MIN_SAMPLES", &c.Anomaly.ZScoreMinSamples)
+	envString("ANOMALY_ZSCORE_ACTION", &c.Anomaly.ZScoreAction)
+
+	envDuration(verr, "IDEMPOTENCY_TTL", &c.Idempotency.TTL)
+	envDuration(verr, "IDEMPOTENCY_LOCK_TTL", &c.Idempotency.LockTTL)
+
+	envInt(verr, "WEBHOOK_MAX_ATTEMPTS", &c.Webhooks.MaxAttempts)
+	envDuration(verr, "WEBHOOK_BACKOFF", &c.Webhooks.Backoff)
+	envDuration(verr, "WEBHOOK_MAX_BACKOFF", &c.Webhooks.MaxBackoff)
+	envDuration(verr, "WEBHOOK_TIMEOUT", &c.Webhooks.Timeout)
+	envDuration(verr, "WEBHOOK_POLL_INTERVAL", &c.Webhooks.PollInterval)
+	envInt(verr, "WEBHOOK_WORKERS", &c.Webhooks.Workers)
+
+	envInt(verr, "EVENTS_MAX_LEN", &c.Events.MaxLen)
+	envDuration(verr, "EVENTS_CLAIM_IDLE", &c.Events.ClaimIdle)
+	envDuration(verr, "EVENTS_MAX_BLOCK", &c.Events.MaxBlock)
+
+	envString("CLUSTER_NODE_ID", &c.Cluster.NodeID)
+	envDuration(verr, "CLUSTER_LEASE_TTL", &c.Cluster.LeaseTTL)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -67,3 +476,85 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func envString(key string, dst *string) {
+	*dst = getEnv(key, *dst)
+}
+
+func envList(key string, dst *[]string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
+
+func envInt(verr *ValidationError, key string, dst *int) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		verr.add(key, "must be an integer, got %q", value)
+		return
+	}
+	*dst = v
+}
+
+func envFloat(verr *ValidationError, key string, dst *float64) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		verr.add(key, "must be a number, got %q", value)
+		return
+	}
+	*dst = v
+}
+
+func envBool(verr *ValidationError, key string, dst *bool) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		verr.add(key, "must be a boolean, got %q", value)
+		return
+	}
+	*dst = v
+}
+
+func envDuration(verr *ValidationError, key string, dst *Duration) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		verr.add(key, "must be a duration such as 5s or 250ms, got %q", value)
+		return
+	}
+	dst.Duration = v
+}
+
+// Encode renders the configuration as yaml or toml.
+func (c *Config) Encode(format string) ([]byte, error) {
+	switch format {
+	case "yaml", "yml":
+		return yaml.Marshal(c)
+	case "toml":
+		return toml.Marshal(c)
+	default:
+		return nil, fmt.Errorf("unsupported format %q (want yaml or toml)", format)
+	}
+}
@@ -0,0 +1,308 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every invalid field found while loading the
+// configuration, so operators can fix them all in one pass.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		problems[i] = fmt.Sprintf("%s: %s", f.Field, f.Message)
+	}
+	return "invalid configuration:\n  " + strings.Join(problems, "\n  ")
+}
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *Config) validate(verr *ValidationError) {
+	validPort(verr, "server.port", c.Server.Port)
+	switch c.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		verr.add("server.mode", "must be one of debug, release, test; got %q", c.Server.Mode)
+	}
+	if c.Server.ReadTimeout.Duration <= 0 {
+		verr.add("server.read_timeout", "must be positive")
+	}
+	if c.Server.WriteTimeout.Duration <= 0 {
+		verr.add("server.write_timeout", "must be positive")
+	}
+	if c.Server.IdleTimeout.Duration <= 0 {
+		verr.add("server.idle_timeout", "must be positive")
+	}
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		verr.add("server.shutdown_timeout", "must be positive")
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		verr.add("server.cors_origins", "must list at least one origin (use \"*\" to allow any)")
+	}
+	for _, key := range c.Server.APIKeys {
+		if len(key) < 16 {
+			verr.add("server.api_keys", "every key must be at least 16 characters")
+			break
+		}
+	}
+
+	if c.Database.Host == "" {
+		verr.add("database.host", "is required")
+	}
+	validPort(verr, "database.port", c.Database.Port)
+	if c.Database.User == "" {
+		verr.add("database.user", "is required")
+	}
+	if c.Database.DBName == "" {
+		verr.add("database.name", "is required")
+	}
+	switch c.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		verr.add("database.sslmode", "unknown sslmode %q", c.Database.SSLMode)
+	}
+	if c.Database.MaxOpenConns < 1 {
+		verr.add("database.max_open_conns", "must be at least 1")
+	}
+	if c.Database.MaxIdleConns < 0 {
+		verr.add("database.max_idle_conns", "must not be negative")
+	} else if c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		verr.add("database.max_idle_conns", "must not exceed max_open_conns (%d)", c.Database.MaxOpenConns)
+	}
+	if c.Database.ConnMaxLifetime.Duration < 0 {
+		verr.add("database.conn_max_lifetime", "must not be negative")
+	}
+
+	switch c.Redis.Mode {
+	case RedisModeStandalone:
+		if c.Redis.Host == "" {
+			verr.add("redis.host", "is required in standalone mode")
+		}
+		validPort(verr, "redis.port", c.Redis.Port)
+	case RedisModeSentinel:
+		if len(c.Redis.Addrs) == 0 {
+			verr.add("redis.addrs", "must list the Sentinel nodes in sentinel mode")
+		}
+		if c.Redis.MasterName == "" {
+			verr.add("redis.master_name", "is required in sentinel mode")
+		}
+	case RedisModeCluster:
+		if len(c.Redis.Addrs) == 0 {
+			verr.add("redis.addrs", "must list at least one seed node in cluster mode")
+		}
+		if c.Redis.DB != 0 {
+			verr.add("redis.db", "must be 0 in cluster mode")
+		}
+	default:
+		verr.add("redis.mode", "must be one of standalone, sentinel, cluster; got %q", c.Redis.Mode)
+	}
+	if c.Redis.DB < 0 || c.Redis.DB > 15 {
+		verr.add("redis.db", "must be between 0 and 15")
+	}
+	if c.Redis.PoolSize < 1 {
+		verr.add("redis.pool_size", "must be at least 1")
+	}
+	if c.Redis.MinIdleConns < 0 {
+		verr.add("redis.min_idle_conns", "must not be negative")
+	} else if c.Redis.MinIdleConns > c.Redis.PoolSize {
+		verr.add("redis.min_idle_conns", "must not exceed pool_size (%d)", c.Redis.PoolSize)
+	}
+	if c.Redis.DialTimeout.Duration <= 0 {
+		verr.add("redis.dial_timeout", "must be positive")
+	}
+	if c.Redis.ReadTimeout.Duration < 0 {
+		verr.add("redis.read_timeout", "must not be negative")
+	}
+	if c.Redis.WriteTimeout.Duration < 0 {
+		verr.add("redis.write_timeout", "must not be negative")
+	}
+	if (c.Redis.TLS.CertFile == "") != (c.Redis.TLS.KeyFile == "") {
+		verr.add("redis.tls", "cert_file and key_file must be set together")
+	}
+	if !c.Redis.TLS.Enabled && (c.Redis.TLS.CAFile != "" || c.Redis.TLS.CertFile != "") {
+		verr.add("redis.tls.enabled", "must be true when TLS files are configured")
+	}
+
+	if c.Rating.Min < 0 {
+		verr.add("rating.min", "must not be negative")
+	}
+	if c.Rating.Max <= c.Rating.Min {
+		verr.add("rating.max", "must be greater than rating.min (%d)", c.Rating.Min)
+	}
+	if c.Rating.Default < c.Rating.Min || c.Rating.Default > c.Rating.Max {
+		verr.add("rating.default", "must be between rating.min and rating.max")
+	}
+
+	if c.Simulation.MinInterval.Duration <= 0 {
+		verr.add("simulation.min_interval", "must be positive")
+	}
+	if c.Simulation.DefaultInterval.Duration < c.Simulation.MinInterval.Duration {
+		verr.add("simulation.default_interval", "must not be below simulation.min_interval (%s)", c.Simulation.MinInterval)
+	}
+	if c.Simulation.MaxUpdatesPerTick < 1 {
+		verr.add("simulation.max_updates_per_tick", "must be at least 1")
+	}
+	if c.Simulation.DefaultUpdatesPerTick < 1 || c.Simulation.DefaultUpdatesPerTick > c.Simulation.MaxUpdatesPerTick {
+		verr.add("simulation.default_updates_per_tick", "must be between 1 and simulation.max_updates_per_tick")
+	}
+
+	if c.Reconcile.Interval.Duration < 0 {
+		verr.add("reconcile.interval", "must not be negative (0 disables the schedule)")
+	}
+	if c.Reconcile.BatchSize < 1 || c.Reconcile.BatchSize > 10000 {
+		verr.add("reconcile.batch_size", "must be between 1 and 10000")
+	}
+	if c.Reconcile.GracePeriod.Duration < 0 {
+		verr.add("reconcile.grace_period", "must not be negative")
+	}
+
+	if c.Rebuild.BatchSize < 1 || c.Rebuild.BatchSize > 50000 {
+		verr.add("rebuild.batch_size", "must be between 1 and 50000")
+	}
+	if c.Rebuild.LockTTL.Duration < time.Second {
+		verr.add("rebuild.lock_ttl", "must be at least 1s")
+	}
+
+	switch c.Teams.Aggregate {
+	case "sum", "avg", "top_k":
+	default:
+		verr.add("teams.aggregate", "must be one of sum, avg, top_k; got %q", c.Teams.Aggregate)
+	}
+	if c.Teams.TopK < 1 {
+		verr.add("teams.top_k", "must be at least 1")
+	}
+
+	if c.Decay.Interval.Duration < 0 {
+		verr.add("decay.interval", "must not be negative (0 disables the schedule)")
+	}
+	if c.Decay.InactiveAfter.Duration < time.Hour {
+		verr.add("decay.inactive_after", "must be at least 1h")
+	}
+	switch c.Decay.Mode {
+	case "fixed":
+		if c.Decay.Amount <= 0 {
+			verr.add("decay.amount", "must be positive")
+		}
+	case "percent":
+		if c.Decay.Amount <= 0 || c.Decay.Amount >= 100 {
+			verr.add("decay.amount", "must be between 0 and 100 (exclusive) in percent mode")
+		}
+	default:
+		verr.add("decay.mode", "must be one of fixed, percent; got %q", c.Decay.Mode)
+	}
+	if c.Decay.Floor < c.Rating.Min || c.Decay.Floor > c.Rating.Max {
+		verr.add("decay.floor", "must be between rating.min and rating.max")
+	}
+	if c.Decay.BatchSize < 1 || c.Decay.BatchSize > 10000 {
+		verr.add("decay.batch_size", "must be between 1 and 10000")
+	}
+
+	if c.Anomaly.MaxDelta < 0 {
+		verr.add("anomaly.max_delta", "must not be negative (0 disables the rule)")
+	}
+	anomalyAction(verr, "anomaly.max_delta_action", c.Anomaly.MaxDeltaAction)
+	if c.Anomaly.MaxUpdatesPerMinute < 0 {
+		verr.add("anomaly.max_updates_per_minute", "must not be negative (0 disables the rule)")
+	}
+	anomalyAction(verr, "anomaly.update_rate_action", c.Anomaly.UpdateRateAction)
+	if c.Anomaly.ZScoreThreshold < 0 {
+		verr.add("anomaly.zscore_threshold", "must not be negative (0 disables the rule)")
+	}
+	if c.Anomaly.ZScoreMinSamples < 2 {
+		verr.add("anomaly.zscore_min_samples", "must be at least 2")
+	}
+	if c.Anomaly.ZScoreWindow < c.Anomaly.ZScoreMinSamples || c.Anomaly.ZScoreWindow > 1000 {
+		verr.add("anomaly.zscore_window", "must be between anomaly.zscore_min_samples and 1000")
+	}
+	anomalyAction(verr, "anomaly.zscore_action", c.Anomaly.ZScoreAction)
+
+	if c.Idempotency.TTL.Duration < time.Minute {
+		verr.add("idempotency.ttl", "must be at least 1m")
+	}
+	if c.Idempotency.LockTTL.Duration < time.Second {
+		verr.add("idempotency.lock_ttl", "must be at least 1s")
+	}
+
+	if c.Webhooks.MaxAttempts < 1 || c.Webhooks.MaxAttempts > 50 {
+		verr.add("webhooks.max_attempts", "must be between 1 and 50")
+	}
+	if c.Webhooks.Backoff.Duration < time.Second {
+		verr.add("webhooks.backoff", "must be at least 1s")
+	}
+	if c.Webhooks.MaxBackoff.Duration < c.Webhooks.Backoff.Duration {
+		verr.add("webhooks.max_backoff", "must not be less than webhooks.backoff")
+	}
+	if c.Webhooks.Timeout.Duration < time.Second {
+		verr.add("webhooks.timeout", "must be at least 1s")
+	}
+	if c.Webhooks.PollInterval.Duration < 100*time.Millisecond {
+		verr.add("webhooks.poll_interval", "must be at least 100ms")
+	}
+	if c.Webhooks.Workers < 1 || c.Webhooks.Workers > 64 {
+		verr.add("webhooks.workers", "must be between 1 and 64")
+	}
+
+	if c.Events.MaxLen < 0 {
+		verr.add("events.max_len", "must not be negative (0 disables the stream)")
+	}
+	if c.Events.ClaimIdle.Duration < time.Second {
+		verr.add("events.claim_idle", "must be at least 1s")
+	}
+	if c.Events.MaxBlock.Duration < time.Second || c.Events.MaxBlock.Duration > 5*time.Minute {
+		verr.add("events.max_block", "must be between 1s and 5m")
+	}
+
+	if c.Cluster.LeaseTTL.Duration < 3*time.Second || c.Cluster.LeaseTTL.Duration > 5*time.Minute {
+		verr.add("cluster.lease_ttl", "must be between 3s and 5m")
+	}
+}
+
+func anomalyAction(verr *ValidationError, field, action string) {
+	switch action {
+	case "reject", "review", "flag":
+	default:
+		verr.add(field, "must be one of reject, review, flag; got %q", action)
+	}
+}
+
+func validPort(verr *ValidationError, field, port string) {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		verr.add(field, "must be a port number between 1 and 65535, got %q", port)
+	}
+}
+
+// Redacted returns a copy of the configuration with secrets masked, suitable
+// for printing or logging.
+func (c *Config) Redacted() *Config {
+	out := *c
+	out.Server.CORSOrigins = append([]string(nil), c.Server.CORSOrigins...)
+	out.Server.APIKeys = nil
+	for range c.Server.APIKeys {
+		out.Server.APIKeys = append(out.Server.APIKeys, "********")
+	}
+	if out.Database.Password != "" {
+		out.Database.Password = "********"
+	}
+	out.Redis.Addrs = append([]string(nil), c.Redis.Addrs...)
+	if out.Redis.Password != "" {
+		out.Redis.Password = "********"
+	}
+	if out.Redis.SentinelPassword != "" {
+		out.Redis.SentinelPassword = "********"
+	}
+	return &out
+}
@@ -0,0 +1,175 @@
+package events
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type ConsumerOptions struct {
+	// Group is the consumer group; consumers sharing it split the stream.
+	Group string
+	// Name identifies this consumer within the group. It should survive
+	// restarts so the consumer picks up its own unacknowledged events.
+	Name string
+	// Start is where a newly created group begins: "$" (the default) for
+	// new events only, "0" for everything still in the stream, or an ID.
+	// It is ignored when the group already exists.
+	Start string
+	// Count is the number of events read per call. Defaults to 100.
+	Count int64
+	// Block is how long a read waits for new events. Defaults to 5s.
+	Block time.Duration
+	// ClaimIdle is how long an event may stay unacknowledged by another
+	// consumer before this one takes it over. Defaults to 1m.
+	ClaimIdle time.Duration
+}
+
+// Consumer tails the score event stream in a consumer group. An event is
+// acknowledged only after the handler returns nil, so every event is handled
+// at least once; handlers must tolerate duplicates.
+type Consumer struct {
+	client redis.UniversalClient
+	opts   ConsumerOptions
+}
+
+func NewConsumer(client redis.UniversalClient, opts ConsumerOptions) *Consumer {
+	if opts.Start == "" {
+		opts.Start = "$"
+	}
+	if opts.Count < 1 {
+		opts.Count = 100
+	}
+	if opts.Block <= 0 {
+		opts.Block = 5 * time.Second
+	}
+	if opts.ClaimIdle <= 0 {
+		opts.ClaimIdle = time.Minute
+	}
+	return &Consumer{client: client, opts: opts}
+}
+
+// Seek moves the group so its next new event is the one after offset,
+// creating the group if needed. "0" replays the whole stream and "$" skips
+// to new events. Pending events are not affected.
+func (c *Consumer) Seek(ctx context.Context, offset string) error {
+	err := c.client.XGroupSetID(ctx, Stream, c.opts.Group, offset).Err()
+	if IsNoGroup(err) {
+		return c.create(ctx, offset)
+	}
+	return err
+}
+
+// Run hands events to handle until ctx is cancelled. A handler error does
+// not stop it: the event stays pending and comes back after ClaimIdle. Run
+// first replays this consumer's own pending events, then alternates between
+// taking over events abandoned by other consumers and reading new ones.
+func (c *Consumer) Run(ctx context.Context, handle func(context.Context, Event) error) error {
+	if err := c.create(ctx, c.opts.Start); err != nil {
+		return err
+	}
+
+	// Our own pending list first: events delivered before a restart.
+	after := "0"
+	for {
+		msgs, err := c.read(ctx, after, -1)
+		if err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+		c.handle(ctx, msgs, handle)
+		after = msgs[len(msgs)-1].ID
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		claimed, _, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   Stream,
+			Group:    c.opts.Group,
+			Consumer: c.opts.Name,
+			MinIdle:  c.opts.ClaimIdle,
+			Start:    "0-0",
+			Count:    c.opts.Count,
+		}).Result()
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		c.handle(ctx, claimed, handle)
+
+		msgs, err := c.read(ctx, ">", c.opts.Block)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		c.handle(ctx, msgs, handle)
+	}
+}
+
+func (c *Consumer) read(ctx context.Context, id string, block time.Duration) ([]redis.XMessage, error) {
+	streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.opts.Group,
+		Consumer: c.opts.Name,
+		Streams:  []string{Stream, id},
+		Count:    c.opts.Count,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil || len(streams) == 0 {
+		return nil, err
+	}
+	return streams[0].Messages, nil
+}
+
+func (c *Consumer) handle(ctx context.Context, msgs []redis.XMessage, handle func(context.Context, Event) error) {
+	for _, msg := range msgs {
+		// Entries trimmed from the stream while pending come back empty.
+		if msg.Values == nil {
+			c.ack(ctx, msg.ID)
+			continue
+		}
+
+		event, err := Parse(msg)
+		if err != nil {
+			log.Printf("events: skipping malformed entry: %v", err)
+			c.ack(ctx, msg.ID)
+			continue
+		}
+		if err := handle(ctx, event); err != nil {
+			log.Printf("events: handler failed for %s, will retry: %v", msg.ID, err)
+			continue
+		}
+		c.ack(ctx, msg.ID)
+	}
+}
+
+func (c *Consumer) ack(ctx context.Context, id string) {
+	if err := c.client.XAck(ctx, Stream, c.opts.Group, id).Err(); err != nil {
+		log.Printf("events: failed to ack %s: %v", id, err)
+	}
+}
+
+func (c *Consumer) create(ctx context.Context, start string) error {
+	err := c.client.XGroupCreateMkStream(ctx, Stream, c.opts.Group, start).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// IsNoGroup reports whether err is Redis saying a consumer group does not
+// exist.
+func IsNoGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
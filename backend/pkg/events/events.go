@@ -0,0 +1,90 @@
+// Package events reads the RankQ score event stream. Every rating change is
+// appended to a Redis Stream; Consumer tails it through a consumer group
+// with at-least-once delivery.
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Stream is the key of the score event stream. It carries the leaderboard
+// hash tag so the API can append to it from its score scripts on Redis
+// Cluster.
+const Stream = "{leaderboard}:events"
+
+// Event is one rating change. OldRating and OldRank are nil when the user
+// had no rating before. Source is what caused the change: update,
+// moderation, decay, create, simulation or reconcile.
+type Event struct {
+	// ID is the stream entry ID. It orders events and is the offset to
+	// resume or replay from.
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	OldRating *int      `json:"old_rating"`
+	NewRating int       `json:"new_rating"`
+	OldRank   *int64    `json:"old_rank"`
+	NewRank   int64     `json:"new_rank"`
+	Source    string    `json:"source"`
+	At        time.Time `json:"at"`
+}
+
+// Parse decodes a stream entry.
+func Parse(msg redis.XMessage) (Event, error) {
+	e := Event{ID: msg.ID}
+
+	field := func(name string) (string, bool) {
+		v, ok := msg.Values[name].(string)
+		return v, ok
+	}
+
+	var ok bool
+	if e.UserID, ok = field("user_id"); !ok {
+		return e, fmt.Errorf("event %s: missing user_id", msg.ID)
+	}
+	e.Source, _ = field("source")
+
+	var err error
+	if e.NewRating, err = intField(msg, "new_rating"); err != nil {
+		return e, err
+	}
+	newRank, err := intField(msg, "new_rank")
+	if err != nil {
+		return e, err
+	}
+	e.NewRank = int64(newRank)
+
+	if _, ok := field("old_rating"); ok {
+		oldRating, err := intField(msg, "old_rating")
+		if err != nil {
+			return e, err
+		}
+		oldRank, err := intField(msg, "old_rank")
+		if err != nil {
+			return e, err
+		}
+		e.OldRating = &oldRating
+		rank := int64(oldRank)
+		e.OldRank = &rank
+	}
+
+	at, err := intField(msg, "at")
+	if err != nil {
+		return e, err
+	}
+	e.At = time.UnixMilli(int64(at)).UTC()
+
+	return e, nil
+}
+
+func intField(msg redis.XMessage, name string) (int, error) {
+	v, _ := msg.Values[name].(string)
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("event %s: invalid %s %q", msg.ID, name, v)
+	}
+	return n, nil
+}